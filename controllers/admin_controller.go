@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"log"
 	"pethub_api/middleware"
 	"pethub_api/models"
 
@@ -25,6 +26,19 @@ func RegisterAdmin(c *fiber.Ctx) error {
 		})
 	}
 
+	// Anyone may create the first admin; after that only admins add more
+	var adminCount int64
+	if err := middleware.DBConn.Model(&models.AdminAccount{}).Count(&adminCount).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database error",
+		})
+	}
+	if adminCount > 0 && middleware.GetRoleFromJWT(c) != middleware.RoleAdmin {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Only an admin can register another admin",
+		})
+	}
+
 	// Check if username exists
 	var existingAdmin models.AdminAccount
 	result := middleware.DBConn.Where("username = ?", requestBody.Username).First(&existingAdmin)
@@ -61,6 +75,44 @@ func RegisterAdmin(c *fiber.Ctx) error {
 	})
 }
 
+func LoginAdmin(c *fiber.Ctx) error {
+	requestBody := struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}{}
+
+	if err := c.BodyParser(&requestBody); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+
+	var admin models.AdminAccount
+	if err := middleware.DBConn.Where("username = ?", requestBody.Username).First(&admin).Error; err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid username or password",
+		})
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(requestBody.Password)); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid username or password",
+		})
+	}
+
+	token, err := middleware.GenerateJWT(admin.AdminID, middleware.RoleAdmin)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error generating token",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Login successful",
+		"token":   token,
+		"data":    fiber.Map{"admin_id": admin.AdminID, "username": admin.Username},
+	})
+}
+
 //BLOCK OR UNBLOCK ACCOUNTS
 
 // UPDATE SHELTER STATUS
@@ -273,6 +325,12 @@ func UpdateRegistrationStatus(c *fiber.Ctx) error {
 			"error":   err.Error(),
 		})
 	}
+	if err := middleware.PublishEvent(middleware.DBConn, ShelterRegistrationEvent{
+		ShelterID: shelter.ShelterID,
+		RegStatus: request.RegStatus,
+	}); err != nil {
+		log.Println("Registration status: failed to notify shelter:", err)
+	}
 
 	return c.JSON(fiber.Map{
 		"message": "Shelter registration status updated",
@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestAdminAccountRejectsBadBody(t *testing.T) {
+	app := fiber.New()
+	app.Post("/admin/login", LoginAdmin)
+	app.Post("/admin/register", RegisterAdmin)
+
+	for _, path := range []string{"/admin/login", "/admin/register"} {
+		t.Run(path, func(t *testing.T) {
+			req := httptest.NewRequest("POST", path, strings.NewReader("{"))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != fiber.StatusBadRequest {
+				t.Fatalf("status = %d, want 400", resp.StatusCode)
+			}
+		})
+	}
+}
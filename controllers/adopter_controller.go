@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"strconv"
 	"strings"
 	"time"
@@ -45,6 +44,13 @@ func RegisterAdopter(c *fiber.Ctx) error {
 		})
 	}
 
+	contactNumber, err := normalizeContactNumber(requestBody.ContactNumber)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
 	// Check if username exists
 	var existingUser models.AdopterAccount
 	result := middleware.DBConn.Where("username = ?", requestBody.Username).First(&existingUser)
@@ -88,7 +94,7 @@ func RegisterAdopter(c *fiber.Ctx) error {
 		Age:           requestBody.Age,
 		Sex:           requestBody.Sex,
 		Address:       requestBody.Address,
-		ContactNumber: requestBody.ContactNumber,
+		ContactNumber: contactNumber,
 		Email:         requestBody.Email,
 		Occupation:    requestBody.Occupation,
 		CivilStatus:   requestBody.CivilStatus,
@@ -156,7 +162,7 @@ func LoginAdopter(c *fiber.Ctx) error {
 	}
 
 	// Generate JWT
-	token, err := middleware.GenerateJWT(adopterAccount.AdopterID)
+	token, err := middleware.GenerateJWT(adopterAccount.AdopterID, middleware.RoleAdopter)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
 			RetCode: "500",
@@ -177,21 +183,35 @@ func LoginAdopter(c *fiber.Ctx) error {
 }
 
 func GetAllAdopters(c *fiber.Ctx) error {
-	// Fetch all adopter accounts
-	var adopterAccounts []models.AdopterAccount
-	accountResult := middleware.DBConn.Find(&adopterAccounts)
-
-	if accountResult.Error != nil {
+	// Page through adopter accounts
+	adopterAccounts, pagination, retCode, err := paginateList[models.AdopterAccount](c, middleware.DBConn, listSpec{
+		IDColumn: "adopteraccount.adopter_id",
+		Sorts: map[string]string{
+			"username":   "adopteraccount.username",
+			"created_at": "adopteraccount.created_at",
+			"adopter_id": "adopteraccount.adopter_id",
+		},
+		DefaultSort: "-created_at",
+		Filters: map[string]listFilter{
+			"username": {Column: "username", Contains: true},
+			"status":   {Column: "status"},
+		},
+	})
+	if err != nil {
 		return c.JSON(response.AdopterResponseModel{
-			RetCode: "400",
-			Message: "Failed to fetch adopter info",
+			RetCode: retCode,
+			Message: err.Error(),
 			Data:    nil,
 		})
 	}
 
-	// Fetch all adopter info
+	// Fetch the adopter info for this page
+	adopterIDs := make([]uint, len(adopterAccounts))
+	for i, account := range adopterAccounts {
+		adopterIDs[i] = account.AdopterID
+	}
 	var adopterInfos []models.AdopterInfo
-	infoResult := middleware.DBConn.Preload("AdopterMedia").Find(&adopterInfos)
+	infoResult := middleware.DBConn.Preload("AdopterMedia").Where("adopter_id IN ?", adopterIDs).Find(&adopterInfos)
 
 	if infoResult.Error != nil {
 		return c.JSON(response.AdopterResponseModel{
@@ -216,8 +236,9 @@ func GetAllAdopters(c *fiber.Ctx) error {
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"message": "Adopters retrieved successfully",
-		"data":    adopters,
+		"message":    "Adopters retrieved successfully",
+		"data":       adopters,
+		"pagination": pagination,
 	})
 }
 
@@ -503,6 +524,48 @@ func CreateAdoption(c *fiber.Ctx) error {
 	home7, _ := getBase64("home_image7")
 	home8, _ := getBase64("home_image8")
 
+	var pet models.PetInfo
+	if err := middleware.DBConn.First(&pet, petID).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{"message": "Pet not found"})
+	}
+	// Check if the pet is already adopted or unavailable
+	if pet.Status == "adopted" {
+		return c.Status(400).JSON(fiber.Map{"message": "Pet has already been adopted"})
+	}
+	if pet.Status == "archived" {
+		return c.Status(400).JSON(fiber.Map{"message": ErrPetNotAcceptingAdoption.Error()})
+	}
+	// The application goes to the pet's own shelter, whose form applies
+	if uint(shelterID) != pet.ShelterID {
+		return c.Status(400).JSON(fiber.Map{"message": "Pet does not belong to this shelter"})
+	}
+
+	// Validate answers against the shelter's active questionnaire, if it has one
+	questionnaire, err := findActiveQuestionnaire(middleware.DBConn, pet.ShelterID, pet.PetType)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Failed to load questionnaire", "error": err.Error()})
+	}
+	var answers []models.ApplicationAnswer
+	var questionnaireID uint
+	if questionnaire != nil {
+		answers, err = collectAnswers(c, questionnaire)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"message": err.Error()})
+		}
+		questionnaireID = questionnaire.QuestionnaireID
+	}
+
+	tx := middleware.DBConn.Begin()
+
+	// Reject duplicates and enforce the per-adopter cap before writing anything
+	if err := checkCanApply(tx, uint(adopterID), uint(petID)); err != nil {
+		tx.Rollback()
+		if errors.Is(err, ErrDuplicateApplication) || errors.Is(err, ErrTooManyApplications) {
+			return c.Status(409).JSON(fiber.Map{"message": err.Error()})
+		}
+		return c.Status(500).JSON(fiber.Map{"message": "Failed to check existing applications", "error": err.Error()})
+	}
+
 	// Save photos
 	photos := models.ApplicationPhotos{
 		AdopterIDType:  c.FormValue("adopter_id_type"),
@@ -519,13 +582,14 @@ func CreateAdoption(c *fiber.Ctx) error {
 		HomeImage8:     home8,
 	}
 
-	if err := middleware.DBConn.Debug().Create(&photos).Error; err != nil {
+	if err := tx.Debug().Create(&photos).Error; err != nil {
+		tx.Rollback()
 		return c.Status(500).JSON(fiber.Map{"message": "Failed to save application photos", "error": err.Error()})
 	}
 
 	// Create adoption record
 	adoption := models.AdoptionSubmission{
-		ShelterID:           uint(shelterID),
+		ShelterID:           pet.ShelterID,
 		PetID:               uint(petID),
 		AdopterID:           uint(adopterID),
 		AltFName:            c.FormValue("alt_f_name"),
@@ -544,37 +608,57 @@ func CreateAdoption(c *fiber.Ctx) error {
 		Status:              "pending",
 		CreatedAt:           time.Now(),
 		ImageID:             photos.ImageID,
+		QuestionnaireID:     questionnaireID,
 	}
 
-	if err := middleware.DBConn.Debug().Create(&adoption).Error; err != nil {
+	if err := tx.Debug().Create(&adoption).Error; err != nil {
+		tx.Rollback()
+		// The partial unique index catches concurrent submissions that slipped past checkCanApply
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return c.Status(409).JSON(fiber.Map{"message": ErrDuplicateApplication.Error()})
+		}
 		return c.Status(500).JSON(fiber.Map{"message": "Failed to save adoption submission", "error": err.Error()})
 	}
 
-	var pet models.PetInfo
-	if err := middleware.DBConn.First(&pet, petID).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{"message": "Pet not found"})
+	if len(answers) > 0 {
+		for i := range answers {
+			answers[i].ApplicationID = adoption.ApplicationID
+		}
+		if err := tx.Create(&answers).Error; err != nil {
+			tx.Rollback()
+			return c.Status(500).JSON(fiber.Map{"message": "Failed to save questionnaire answers", "error": err.Error()})
+		}
+		adoption.Answers = answers
 	}
-	// Check if the pet is already adopted or unavailable
-	if pet.Status == "adopted" {
-		return c.Status(400).JSON(fiber.Map{"message": "Pet has already been adopted"})
+
+	if err := publishApplicationStatus(tx, adoption, ""); err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{"message": "Failed to notify adopter", "error": err.Error()})
 	}
 
 	// Count current adoption submissions for this pet
 	var adoptionCount int64
-	if err := middleware.DBConn.Model(&models.AdoptionSubmission{}).
-		Where("pet_id = ?", petID).
+	if err := tx.Model(&models.AdoptionSubmission{}).
+		Where("pet_id = ? AND status IN ?", petID, activeApplicationStatuses).
 		Count(&adoptionCount).Error; err != nil {
+		tx.Rollback()
 		return c.Status(500).JSON(fiber.Map{"message": "Failed to count adoptions"})
 	}
 
-	// If 3 or more adoptions, set pet status to "pending"
-	if adoptionCount >= 3 {
-		pet.Status = "unavailable"
-		if err := middleware.DBConn.Save(&pet).Error; err != nil {
+	// If 3 or more adoptions, set pet status to "unavailable"; a pet held for
+	// an interview stays pending
+	if adoptionCount >= 3 && pet.Status == models.PetAvailable {
+		change := byAdopter(uint(adopterID), "Reached 3 active applications").forApplication(adoption.ApplicationID)
+		if err := setPetStatus(tx, &pet, models.PetUnavailable, change); err != nil {
+			tx.Rollback()
 			return c.Status(500).JSON(fiber.Map{"message": "Failed to update pet status"})
 		}
 	}
 
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"message": "Failed to save adoption submission", "error": err.Error()})
+	}
+
 	return c.Status(201).JSON(fiber.Map{
 		"message":  "Adoption submitted successfully",
 		"adoption": adoption,
@@ -618,11 +702,25 @@ func UpdateAdopterDetails(c *fiber.Ctx) error {
 		updateData["address"] = updateRequest.Address
 	}
 	if updateRequest.ContactNumber != "" {
-		updateData["contact_number"] = updateRequest.ContactNumber
+		contactNumber, err := normalizeContactNumber(updateRequest.ContactNumber)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		updateData["contact_number"] = contactNumber
 	}
 	if updateRequest.Email != "" {
 		updateData["email"] = updateRequest.Email
 	}
+	if updateRequest.Timezone != "" {
+		if err := validateTimezoneField(updateRequest.Timezone); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		updateData["timezone"] = updateRequest.Timezone
+	}
 
 	// Debugging log
 	fmt.Printf("Update Data: %+v\n", updateData)
@@ -634,11 +732,20 @@ func UpdateAdopterDetails(c *fiber.Ctx) error {
 	}
 
 	// Update adopter info fields
+	previousEmail := adopterInfo.Email
 	if err := middleware.DBConn.Model(&adopterInfo).Updates(updateData).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": "Failed to update adopter info",
 		})
 	}
+	// A new address has to be verified again
+	if updateRequest.Email != "" && !strings.EqualFold(updateRequest.Email, previousEmail) {
+		if err := markEmailUnverified(middleware.DBConn, adopterInfo.AdopterID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Failed to reset email verification",
+			})
+		}
+	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "Adopter details updated successfully",
@@ -710,136 +817,6 @@ func UploadAdopterMedia(c *fiber.Ctx) error {
 	})
 }
 
-func GetAdoptionNotifications(c *fiber.Ctx) error {
-	adopterIDStr := c.Params("adopter_id")
-	adopterID, err := strconv.Atoi(adopterIDStr)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid adopter ID",
-		})
-	}
-
-	// Step 1: Fetch all applications for this adopter
-	var applications []models.AdoptionSubmission
-	if err := middleware.DBConn.Preload("Pet").
-		Where("adopter_id = ?", adopterID).
-		Order("created_at DESC").
-		Find(&applications).Error; err != nil {
-		log.Println("Error fetching applications:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch adoption applications",
-		})
-	}
-
-	// Adjustment: If no applications, return empty notifications
-	if len(applications) == 0 {
-		return c.JSON(fiber.Map{
-			"notifications": []models.Notification{},
-		})
-	}
-
-	// Step 2: Generate notification if needed
-	for _, app := range applications {
-		var notifType, notifStatus, notifCategory, notifTitle string
-
-		if app.Status == "application_reject" || app.Status == "interview_reject" || app.Status == "approved_reject" {
-			notifType = "rejected"
-			notifStatus = "rejected"
-			notifCategory = "rejected"
-			switch app.Status {
-			case "application_reject":
-				notifTitle = "Application Rejected"
-			case "interview_reject":
-				notifTitle = "Interview Rejected"
-			case "approved_reject":
-				notifTitle = "Approval Rejected"
-			}
-		} else {
-			switch app.Status {
-			case "pending":
-				notifType = "application"
-				notifStatus = "pending"
-				notifCategory = "inprogress"
-				notifTitle = "Application Pending"
-			case "in queue":
-				notifType = "application"
-				notifStatus = "in queue"
-				notifCategory = "inprogress"
-				notifTitle = "Application In Queue"
-			case "interview":
-				notifType = "interview"
-				notifStatus = "interview"
-				notifCategory = "inprogress"
-				notifTitle = "Interview Scheduled"
-			case "approved":
-				notifType = "approved"
-				notifStatus = "approved"
-				notifCategory = "approved"
-				notifTitle = "Application Approved"
-			case "completed":
-				notifType = "completed"
-				notifStatus = "completed"
-				notifCategory = "completed"
-				notifTitle = "Adoption Completed"
-			default:
-				notifType = "application"
-				notifStatus = app.Status
-				notifCategory = "inprogress"
-				notifTitle = "Application Update"
-			}
-		}
-
-		message := fmt.Sprintf("Your adoption application for %s is now '%s'.", app.Pet.PetName, strings.Title(app.Status))
-
-		// Check if notification already exists (no deleted filter)
-		var existingNotif models.Notification
-		err := middleware.DBConn.
-			Where("adopter_id = ? AND pet_id = ? AND status = ?",
-				adopterID, app.PetID, notifStatus).
-			First(&existingNotif).Error
-
-		if err == nil {
-			continue // Notification already exists
-		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-			log.Println("Error checking existing notification:", err)
-			continue
-		}
-
-		// Create new notification
-		notif := models.Notification{
-			AdopterID: uint(adopterID),
-			PetID:     uint(app.PetID),
-			Title:     notifTitle,
-			Message:   message,
-			Type:      notifType,
-			Status:    notifStatus,
-			Category:  notifCategory,
-			IsRead:    false,
-			CreatedAt: time.Now(),
-		}
-
-		if err := middleware.DBConn.Create(&notif).Error; err != nil {
-			log.Println("Error creating notification:", err)
-		}
-	}
-
-	// Step 3: Return all notifications for this adopter (no deleted filter)
-	var notifications []models.Notification
-	if err := middleware.DBConn.
-		Where("adopter_id = ?", adopterID).
-		Order("created_at DESC").
-		Find(&notifications).Error; err != nil {
-		log.Println("Error fetching notifications:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch notifications",
-		})
-	}
-
-	return c.JSON(fiber.Map{
-		"notifications": notifications,
-	})
-}
-
 func EditAdopterProfileOLD(c *fiber.Ctx) error {
 	adopterID := c.Params("id")
 
@@ -907,13 +884,40 @@ func EditAdopterProfile(c *fiber.Ctx) error {
 	adopterInfo.FirstName = c.FormValue("first_name")
 	adopterInfo.LastName = c.FormValue("last_name")
 	adopterInfo.Address = c.FormValue("address")
-	adopterInfo.ContactNumber = c.FormValue("contact_number")
+	contactNumber, err := normalizeContactNumber(c.FormValue("contact_number"))
+	if err != nil {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "400",
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	adopterInfo.ContactNumber = contactNumber
+	previousEmail := adopterInfo.Email
 	adopterInfo.Email = c.FormValue("email")
 	adopterInfo.Occupation = c.FormValue("occupation")
 	adopterInfo.CivilStatus = c.FormValue("civil_status")
 	adopterInfo.SocialMedia = c.FormValue("social_media")
+	if err := validateTimezoneField(c.FormValue("timezone")); err != nil {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "400",
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	adopterInfo.Timezone = c.FormValue("timezone")
 
 	middleware.DBConn.Debug().Where("adopter_id = ?", adopterId).Updates(&adopterInfo)
+	// A new address has to be verified again
+	if adopterInfo.Email != "" && !strings.EqualFold(adopterInfo.Email, previousEmail) {
+		if err := markEmailUnverified(middleware.DBConn, uint(adopterId)); err != nil {
+			return c.JSON(response.AdopterResponseModel{
+				RetCode: "500",
+				Message: "Failed to reset email verification",
+				Data:    nil,
+			})
+		}
+	}
 
 	// Load or initialize media record
 	var adopterMedia models.AdopterMedia
@@ -949,83 +953,6 @@ func EditAdopterProfile(c *fiber.Ctx) error {
 	})
 }
 
-func SetNotificationReadStatus(c *fiber.Ctx) error {
-	notificationID := c.Params("id")
-
-	var body struct {
-		IsRead bool `json:"is_read"`
-	}
-	if err := c.BodyParser(&body); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
-	}
-
-	var notif models.Notification
-	if err := middleware.DBConn.First(&notif, notificationID).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Notification not found",
-		})
-	}
-
-	notif.IsRead = body.IsRead
-	if err := middleware.DBConn.Save(&notif).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to update notification",
-		})
-	}
-
-	status := "read"
-	if !body.IsRead {
-		status = "unread"
-	}
-	return c.JSON(fiber.Map{
-		"message": fmt.Sprintf("Notification marked as %s", status),
-	})
-}
-
-func CountUnreadNotifications(c *fiber.Ctx) error {
-	adopterID := c.Params("adopter_id")
-
-	var count int64
-	if err := middleware.DBConn.Model(&models.Notification{}).
-		Where("adopter_id = ? AND is_read = false", adopterID).
-		Count(&count).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to count unread notifications",
-		})
-	}
-
-	return c.JSON(fiber.Map{
-		"unread_count": count,
-	})
-}
-
-func GetNotificationByID(c *fiber.Ctx) error {
-	notificationID := c.Params("id")
-
-	var notif models.Notification
-	if err := middleware.DBConn.First(&notif, notificationID).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Notification not found",
-		})
-	}
-
-	// Mark as read if not already
-	if !notif.IsRead {
-		notif.IsRead = true
-		if err := middleware.DBConn.Save(&notif).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to mark notification as read",
-			})
-		}
-	}
-
-	return c.JSON(fiber.Map{
-		"notification": notif,
-	})
-}
-
 func GetAdopterProfile(c *fiber.Ctx) error {
 	adopterID := c.Params("id")
 
@@ -1043,26 +970,3 @@ func GetAdopterProfile(c *fiber.Ctx) error {
 		"adopter_profile": profile.AdopterProfile,
 	})
 }
-
-func DeleteAllNotifications(c *fiber.Ctx) error {
-	adopterIDStr := c.Params("adopter_id")
-	adopterID, err := strconv.Atoi(adopterIDStr)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid adopter ID",
-		})
-	}
-
-	// Hard delete all notifications for this adopter
-	if err := middleware.DBConn.
-		Where("adopter_id = ?", adopterID).
-		Delete(&models.Notification{}).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to delete notifications",
-		})
-	}
-
-	return c.JSON(fiber.Map{
-		"message": "All notifications deleted successfully",
-	})
-}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+
+	"pethub_api/middleware"
+	"pethub_api/models"
+
+	"gorm.io/gorm"
+)
+
+// Application statuses that still hold a place in the pet's pipeline.
+// Kept in sync with the partial unique index created in middleware.ConnectDB.
+var activeApplicationStatuses = []string{"pending", "in queue", "interview", "approved"}
+
+// Application statuses set when a shelter turns an application down.
+var rejectedApplicationStatuses = []string{"application_reject", "interview_reject", "approved_reject", "rejected"}
+
+// isTerminalApplicationStatus reports whether an application with status is
+// finished: completed, rejected or expired.
+func isTerminalApplicationStatus(status string) bool {
+	if status == "completed" || status == "expired" {
+		return true
+	}
+	for _, rejected := range rejectedApplicationStatuses {
+		if status == rejected {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	ErrDuplicateApplication    = errors.New("adopter already has an active application for this pet")
+	ErrTooManyApplications     = errors.New("adopter has reached the maximum number of active applications")
+	ErrPetNotAcceptingAdoption = errors.New("pet is not accepting applications")
+)
+
+// maxActiveApplications reads MAX_ACTIVE_APPLICATIONS; zero or unset means no cap.
+func maxActiveApplications() int64 {
+	limit, err := strconv.ParseInt(middleware.GetEnv("MAX_ACTIVE_APPLICATIONS"), 10, 64)
+	if err != nil || limit < 0 {
+		return 0
+	}
+	return limit
+}
+
+// checkCanApply enforces one active application per adopter and pet, and the
+// optional cap on concurrent active applications per adopter.
+func checkCanApply(tx *gorm.DB, adopterID, petID uint) error {
+	var existing int64
+	if err := tx.Model(&models.AdoptionSubmission{}).
+		Where("adopter_id = ? AND pet_id = ? AND status IN ?", adopterID, petID, activeApplicationStatuses).
+		Count(&existing).Error; err != nil {
+		return err
+	}
+	if existing > 0 {
+		return ErrDuplicateApplication
+	}
+
+	if limit := maxActiveApplications(); limit > 0 {
+		var active int64
+		if err := tx.Model(&models.AdoptionSubmission{}).
+			Where("adopter_id = ? AND status IN ?", adopterID, activeApplicationStatuses).
+			Count(&active).Error; err != nil {
+			return err
+		}
+		if active >= limit {
+			return ErrTooManyApplications
+		}
+	}
+	return nil
+}
@@ -0,0 +1,685 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"pethub_api/middleware"
+	"pethub_api/models"
+	"pethub_api/models/response"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrSlotFull = errors.New("The selected interview slot is fully booked")
+
+const maxSlotListDays = 60
+
+// InterviewSlot is one bookable slot and how many seats are left in it.
+type InterviewSlot struct {
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Capacity  int       `json:"capacity"`
+	Booked    int       `json:"booked"`
+	Available int       `json:"available"`
+}
+
+// clockOn returns the HH:MM clock time on the given day.
+func clockOn(day time.Time, clock string) time.Time {
+	t, _ := time.Parse("15:04", clock)
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, day.Location())
+}
+
+func validateAvailability(window models.ShelterAvailability) error {
+	if window.Weekday < 0 || window.Weekday > 6 {
+		return errors.New("weekday must be between 0 (Sunday) and 6 (Saturday)")
+	}
+	start, err := time.Parse("15:04", window.StartTime)
+	if err != nil {
+		return errors.New("start_time must use HH:MM")
+	}
+	end, err := time.Parse("15:04", window.EndTime)
+	if err != nil {
+		return errors.New("end_time must use HH:MM")
+	}
+	if window.SlotMinutes < 5 || window.SlotMinutes > 480 {
+		return errors.New("slot_minutes must be between 5 and 480")
+	}
+	if end.Sub(start) < time.Duration(window.SlotMinutes)*time.Minute {
+		return errors.New("end_time must leave room for at least one slot after start_time")
+	}
+	if window.Capacity < 1 {
+		return errors.New("capacity must be at least 1")
+	}
+	return nil
+}
+
+func loadAvailability(db *gorm.DB, shelterID uint) ([]models.ShelterAvailability, map[string]bool, error) {
+	var windows []models.ShelterAvailability
+	if err := db.Where("shelter_id = ?", shelterID).Order("weekday, start_time").Find(&windows).Error; err != nil {
+		return nil, nil, err
+	}
+	var blackouts []models.ShelterBlackoutDate
+	if err := db.Where("shelter_id = ?", shelterID).Find(&blackouts).Error; err != nil {
+		return nil, nil, err
+	}
+	blackoutDays := make(map[string]bool, len(blackouts))
+	for _, b := range blackouts {
+		blackoutDays[b.Date.Format("2006-01-02")] = true
+	}
+	return windows, blackoutDays, nil
+}
+
+// slotWindow finds the availability window in which start is the beginning
+// of a slot, or nil if start is not a published slot. Windows are read on the
+// shelter's wall clock in loc.
+func slotWindow(windows []models.ShelterAvailability, blackoutDays map[string]bool, start time.Time, loc *time.Location) *models.ShelterAvailability {
+	start = start.In(loc)
+	if blackoutDays[start.Format("2006-01-02")] {
+		return nil
+	}
+	for i, window := range windows {
+		if int(start.Weekday()) != window.Weekday {
+			continue
+		}
+		windowStart := clockOn(start, window.StartTime)
+		slot := time.Duration(window.SlotMinutes) * time.Minute
+		if start.Before(windowStart) || start.Add(slot).After(clockOn(start, window.EndTime)) {
+			continue
+		}
+		if start.Sub(windowStart)%slot == 0 {
+			return &windows[i]
+		}
+	}
+	return nil
+}
+
+// listInterviewSlots expands the weekly windows into slots for the given
+// number of days starting at from (YYYY-MM-DD in the shelter's timezone, or
+// today when empty), leaving out blackout dates and past slots.
+func listInterviewSlots(db *gorm.DB, shelterID uint, fromDate string, days int) ([]InterviewSlot, error) {
+	windows, blackoutDays, err := loadAvailability(db, shelterID)
+	if err != nil {
+		return nil, err
+	}
+	loc := mustLocation(shelterTimezone(db, shelterID))
+	now := time.Now()
+	from := time.Date(now.In(loc).Year(), now.In(loc).Month(), now.In(loc).Day(), 0, 0, 0, 0, loc)
+	if fromDate != "" {
+		if from, err = time.ParseInLocation("2006-01-02", fromDate, loc); err != nil {
+			return nil, err
+		}
+	}
+	until := from.AddDate(0, 0, days)
+
+	var counts []struct {
+		SlotStart time.Time
+		Total     int
+	}
+	if err := db.Model(&models.InterviewSlotBooking{}).
+		Select("slot_start, count(*) AS total").
+		Where("shelter_id = ? AND slot_start >= ? AND slot_start < ?", shelterID, from, until).
+		Group("slot_start").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	booked := make(map[int64]int, len(counts))
+	for _, row := range counts {
+		booked[row.SlotStart.Unix()] = row.Total
+	}
+
+	slots := []InterviewSlot{}
+	for day := from; day.Before(until); day = day.AddDate(0, 0, 1) {
+		if blackoutDays[day.Format("2006-01-02")] {
+			continue
+		}
+		for _, window := range windows {
+			if int(day.Weekday()) != window.Weekday {
+				continue
+			}
+			slot := time.Duration(window.SlotMinutes) * time.Minute
+			end := clockOn(day, window.EndTime)
+			for start := clockOn(day, window.StartTime); !start.Add(slot).After(end); start = start.Add(slot) {
+				if !start.After(now) {
+					continue
+				}
+				taken := booked[start.Unix()]
+				slots = append(slots, InterviewSlot{
+					Start:     start,
+					End:       start.Add(slot),
+					Capacity:  window.Capacity,
+					Booked:    taken,
+					Available: max(window.Capacity-taken, 0),
+				})
+			}
+		}
+	}
+	return slots, nil
+}
+
+// reserveInterviewSlot takes a seat in the published slot the interview falls
+// on. Interviews set outside the published slots are not tracked. Seats are
+// claimed with ON CONFLICT DO NOTHING so a lost race moves on to the next seat
+// instead of aborting the transaction.
+func reserveInterviewSlot(db *gorm.DB, interview models.ScheduleInterview) error {
+	if err := releaseInterviewSlot(db, interview.ApplicationID); err != nil {
+		return err
+	}
+
+	windows, blackoutDays, err := loadAvailability(db, interview.ShelterID)
+	if err != nil {
+		return err
+	}
+	start := interview.InterviewAt
+	window := slotWindow(windows, blackoutDays, start, mustLocation(shelterTimezone(db, interview.ShelterID)))
+	if window == nil {
+		return nil
+	}
+
+	for seat := 1; seat <= window.Capacity; seat++ {
+		result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.InterviewSlotBooking{
+			ShelterID:     interview.ShelterID,
+			SlotStart:     start,
+			Seat:          seat,
+			ApplicationID: interview.ApplicationID,
+			CreatedAt:     time.Now(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 1 {
+			return nil
+		}
+	}
+	return ErrSlotFull
+}
+
+// releaseInterviewSlot frees the seat held by an application, if any.
+func releaseInterviewSlot(db *gorm.DB, applicationID uint) error {
+	return db.Where("application_id = ?", applicationID).Delete(&models.InterviewSlotBooking{}).Error
+}
+
+// SetShelterAvailability replaces the shelter's weekly interview hours.
+func SetShelterAvailability(c *fiber.Ctx) error {
+	shelterID, err := strconv.ParseUint(c.Params("shelter_id"), 10, 32)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid shelter ID",
+			Data:    nil,
+		})
+	}
+	if !callerIsShelter(c, uint(shelterID)) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "You can only change your own shelter's interview hours",
+			Data:    nil,
+		})
+	}
+
+	var body struct {
+		Windows []models.ShelterAvailability `json:"windows"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		})
+	}
+	for i := range body.Windows {
+		window := &body.Windows[i]
+		if window.Capacity == 0 {
+			window.Capacity = 1
+		}
+		if err := validateAvailability(*window); err != nil {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "400",
+				Message: fmt.Sprintf("windows[%d]: %s", i, err.Error()),
+				Data:    nil,
+			})
+		}
+		window.AvailabilityID = 0
+		window.ShelterID = uint(shelterID)
+		window.CreatedAt = time.Now()
+	}
+
+	tx := middleware.DBConn.Begin()
+	if err := tx.Where("shelter_id = ?", shelterID).Delete(&models.ShelterAvailability{}).Error; err != nil {
+		tx.Rollback()
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to update availability",
+			Data:    err.Error(),
+		})
+	}
+	if len(body.Windows) > 0 {
+		if err := tx.Create(&body.Windows).Error; err != nil {
+			tx.Rollback()
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "500",
+				Message: "Failed to update availability",
+				Data:    err.Error(),
+			})
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to update availability",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Availability updated",
+		Data:    body.Windows,
+	})
+}
+
+func GetShelterAvailability(c *fiber.Ctx) error {
+	windows := []models.ShelterAvailability{}
+	if err := middleware.DBConn.Where("shelter_id = ?", c.Params("shelter_id")).
+		Order("weekday, start_time").
+		Find(&windows).Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching availability",
+			Data:    err.Error(),
+		})
+	}
+
+	blackouts := []models.ShelterBlackoutDate{}
+	if err := middleware.DBConn.Where("shelter_id = ? AND date >= CURRENT_DATE", c.Params("shelter_id")).
+		Order("date").
+		Find(&blackouts).Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching blackout dates",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data: fiber.Map{
+			"windows":        windows,
+			"blackout_dates": blackouts,
+		},
+	})
+}
+
+func AddBlackoutDate(c *fiber.Ctx) error {
+	shelterID, err := strconv.ParseUint(c.Params("shelter_id"), 10, 32)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid shelter ID",
+			Data:    nil,
+		})
+	}
+	if !callerIsShelter(c, uint(shelterID)) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "You can only change your own shelter's blackout dates",
+			Data:    nil,
+		})
+	}
+
+	var body struct {
+		Date   string `json:"date"`
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		})
+	}
+	date, err := time.Parse("2006-01-02", body.Date)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid date format. Use YYYY-MM-DD",
+			Data:    nil,
+		})
+	}
+
+	blackout := models.ShelterBlackoutDate{
+		ShelterID: uint(shelterID),
+		Date:      date,
+		Reason:    strings.TrimSpace(body.Reason),
+		CreatedAt: time.Now(),
+	}
+	if err := middleware.DBConn.Create(&blackout).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "409",
+				Message: "This date is already blocked",
+				Data:    nil,
+			})
+		}
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to save blackout date",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Blackout date added",
+		Data:    blackout,
+	})
+}
+
+func DeleteBlackoutDate(c *fiber.Ctx) error {
+	shelterID, err := strconv.ParseUint(c.Params("shelter_id"), 10, 32)
+	if err != nil || !callerIsShelter(c, uint(shelterID)) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "You can only change your own shelter's blackout dates",
+			Data:    nil,
+		})
+	}
+
+	result := middleware.DBConn.Where("blackout_id = ? AND shelter_id = ?", c.Params("blackout_id"), shelterID).
+		Delete(&models.ShelterBlackoutDate{})
+	if result.Error != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to delete blackout date",
+			Data:    result.Error.Error(),
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "404",
+			Message: "Blackout date not found",
+			Data:    nil,
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Blackout date removed",
+		Data:    nil,
+	})
+}
+
+// slotRange reads ?from=YYYY-MM-DD (default today) and ?days= (default 14).
+func slotRange(c *fiber.Ctx) (string, int, error) {
+	from := c.Query("from")
+	if from != "" {
+		if _, err := time.Parse("2006-01-02", from); err != nil {
+			return "", 0, errors.New("Invalid from date. Use YYYY-MM-DD")
+		}
+	}
+	days := c.QueryInt("days", 14)
+	if days < 1 || days > maxSlotListDays {
+		return "", 0, fmt.Errorf("days must be between 1 and %d", maxSlotListDays)
+	}
+	return from, days, nil
+}
+
+// GetShelterInterviewSlots lists a shelter's upcoming slots and their free
+// seats.
+func GetShelterInterviewSlots(c *fiber.Ctx) error {
+	shelterID, err := strconv.ParseUint(c.Params("shelter_id"), 10, 32)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid shelter ID",
+			Data:    nil,
+		})
+	}
+	from, days, err := slotRange(c)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	slots, err := listInterviewSlots(middleware.DBConn, uint(shelterID), from, days)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Database error while listing interview slots",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data:    slots,
+	})
+}
+
+// GetApplicationInterviewSlots lists the free slots an adopter can book for
+// their application.
+func GetApplicationInterviewSlots(c *fiber.Ctx) error {
+	var application models.AdoptionSubmission
+	if err := middleware.DBConn.Where("application_id = ?", c.Params("application_id")).First(&application).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(response.AdopterResponseModel{
+				RetCode: "404",
+				Message: "Application not found",
+				Data:    nil,
+			})
+		}
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching application",
+			Data:    err.Error(),
+		})
+	}
+	if !callerIsAdopter(c, application.AdopterID) {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "403",
+			Message: "You can only view slots for your own application",
+			Data:    nil,
+		})
+	}
+	from, days, err := slotRange(c)
+	if err != nil {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "400",
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	slots, err := listInterviewSlots(middleware.DBConn, application.ShelterID, from, days)
+	if err != nil {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "500",
+			Message: "Database error while listing interview slots",
+			Data:    err.Error(),
+		})
+	}
+	free := make([]InterviewSlot, 0, len(slots))
+	for _, slot := range slots {
+		if slot.Available > 0 {
+			free = append(free, slot)
+		}
+	}
+
+	return c.JSON(response.AdopterResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data:    free,
+	})
+}
+
+// BookInterviewSlot lets an adopter whose application was invited to
+// interview pick one of the shelter's free slots.
+func BookInterviewSlot(c *fiber.Ctx) error {
+	var body struct {
+		// RFC 3339, or YYYY-MM-DDTHH:MM:SS on the shelter's wall clock
+		SlotStart string `json:"slot_start"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		})
+	}
+
+	var application models.AdoptionSubmission
+	if err := middleware.DBConn.Where("application_id = ?", c.Params("application_id")).First(&application).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(response.AdopterResponseModel{
+				RetCode: "404",
+				Message: "Application not found",
+				Data:    nil,
+			})
+		}
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching application",
+			Data:    err.Error(),
+		})
+	}
+	if !callerIsAdopter(c, application.AdopterID) {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "403",
+			Message: "You can only book interviews for your own application",
+			Data:    nil,
+		})
+	}
+	if application.Status != "interview" {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "400",
+			Message: "The shelter has not invited this application to an interview",
+			Data:    nil,
+		})
+	}
+
+	zone := shelterTimezone(middleware.DBConn, application.ShelterID)
+	start, err := time.Parse(time.RFC3339, body.SlotStart)
+	if err != nil {
+		if start, err = time.ParseInLocation("2006-01-02T15:04:05", body.SlotStart, mustLocation(zone)); err != nil {
+			return c.JSON(response.AdopterResponseModel{
+				RetCode: "400",
+				Message: "Invalid slot_start format. Use RFC 3339 or YYYY-MM-DDTHH:MM:SS",
+				Data:    nil,
+			})
+		}
+	}
+	if !start.After(time.Now()) {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "400",
+			Message: "The selected slot is in the past",
+			Data:    nil,
+		})
+	}
+
+	windows, blackoutDays, err := loadAvailability(middleware.DBConn, application.ShelterID)
+	if err != nil {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching availability",
+			Data:    err.Error(),
+		})
+	}
+	if slotWindow(windows, blackoutDays, start, mustLocation(zone)) == nil {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "400",
+			Message: "The selected time is not one of the shelter's interview slots",
+			Data:    nil,
+		})
+	}
+
+	interview := models.ScheduleInterview{
+		ApplicationID: application.ApplicationID,
+		ShelterID:     application.ShelterID,
+		AdopterID:     application.AdopterID,
+		CreatedAt:     time.Now(),
+	}
+	err = middleware.DBConn.Where("application_id = ?", application.ApplicationID).First(&interview).Error
+	if err == nil && interviewIsOpen(interview) {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "409",
+			Message: "An interview is already booked; request a reschedule instead",
+			Data:    interview,
+		})
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching interview",
+			Data:    err.Error(),
+		})
+	}
+
+	interview.InterviewAt = start.UTC()
+	interview.Timezone = zone
+	interview.InterviewStatus = "scheduled"
+	interview.CancelReason = ""
+
+	tx := middleware.DBConn.Begin()
+	if err := tx.Save(&interview).Error; err != nil {
+		tx.Rollback()
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "500",
+			Message: "Failed to book interview",
+			Data:    err.Error(),
+		})
+	}
+	if err := reserveInterviewSlot(tx, interview); err != nil {
+		tx.Rollback()
+		if errors.Is(err, ErrSlotFull) {
+			return c.JSON(response.AdopterResponseModel{
+				RetCode: "409",
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "500",
+			Message: "Failed to book interview",
+			Data:    err.Error(),
+		})
+	}
+	if err := recordInterviewHistory(tx, interview, "scheduled", "adopter", nil, "Booked by adopter"); err != nil {
+		tx.Rollback()
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "500",
+			Message: "Failed to record interview history",
+			Data:    err.Error(),
+		})
+	}
+	if err := announceInterviewChange(tx, interview, "scheduled"); err != nil {
+		tx.Rollback()
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "500",
+			Message: "Failed to queue interview notifications",
+			Data:    err.Error(),
+		})
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "500",
+			Message: "Failed to book interview",
+			Data:    err.Error(),
+		})
+	}
+
+	localizeInterview(middleware.DBConn, &interview)
+	return c.JSON(response.AdopterResponseModel{
+		RetCode: "200",
+		Message: "Interview booked",
+		Data:    interview,
+	})
+}
@@ -0,0 +1,283 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"pethub_api/middleware"
+	"pethub_api/models"
+	"pethub_api/models/response"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Used for interviews that were not booked into a published slot
+const defaultInterviewMinutes = 60
+
+// interviewDuration is the slot length when the interview sits on one of the
+// shelter's published slots.
+func interviewDuration(db *gorm.DB, interview models.ScheduleInterview) time.Duration {
+	windows, blackoutDays, err := loadAvailability(db, interview.ShelterID)
+	if err != nil {
+		return defaultInterviewMinutes * time.Minute
+	}
+	loc := mustLocation(shelterTimezone(db, interview.ShelterID))
+	return interviewLength(windows, blackoutDays, interview.InterviewAt, loc)
+}
+
+// interviewLength is how long an interview starting at start runs: its
+// slot's length on a published slot, otherwise the default.
+func interviewLength(windows []models.ShelterAvailability, blackoutDays map[string]bool, start time.Time, loc *time.Location) time.Duration {
+	if window := slotWindow(windows, blackoutDays, start, loc); window != nil {
+		return time.Duration(window.SlotMinutes) * time.Minute
+	}
+	return defaultInterviewMinutes * time.Minute
+}
+
+// interviewEvents turns interviews into calendar events. The sequence number
+// is the number of history entries, so every change supersedes the copy a
+// calendar app already holds.
+func interviewEvents(db *gorm.DB, interviews []models.ScheduleInterview) ([]middleware.ICSEvent, error) {
+	events := []middleware.ICSEvent{}
+	if len(interviews) == 0 {
+		return events, nil
+	}
+
+	applicationIDs := make([]uint, 0, len(interviews))
+	interviewIDs := make([]uint, 0, len(interviews))
+	for _, interview := range interviews {
+		applicationIDs = append(applicationIDs, interview.ApplicationID)
+		interviewIDs = append(interviewIDs, interview.InterviewID)
+	}
+
+	var applications []models.AdoptionSubmission
+	if err := db.Preload("Adopter").Preload("Pet").Preload("Shelter").
+		Where("application_id IN ?", applicationIDs).
+		Find(&applications).Error; err != nil {
+		return nil, err
+	}
+	byApplication := make(map[uint]models.AdoptionSubmission, len(applications))
+	for _, app := range applications {
+		byApplication[app.ApplicationID] = app
+	}
+
+	var changes []struct {
+		InterviewID uint
+		Total       int
+	}
+	if err := db.Model(&models.InterviewHistory{}).
+		Select("interview_id, count(*) AS total").
+		Where("interview_id IN ?", interviewIDs).
+		Group("interview_id").
+		Scan(&changes).Error; err != nil {
+		return nil, err
+	}
+	sequence := make(map[uint]int, len(changes))
+	for _, row := range changes {
+		sequence[row.InterviewID] = row.Total
+	}
+
+	for _, interview := range interviews {
+		app := byApplication[interview.ApplicationID]
+		adopterName := strings.TrimSpace(app.Adopter.FirstName + " " + app.Adopter.LastName)
+
+		description := fmt.Sprintf("Adoption interview for %s.\nAdopter: %s\nShelter: %s", app.Pet.PetName, adopterName, app.Shelter.ShelterName)
+		if app.Shelter.ShelterContact != "" {
+			description += "\nShelter contact: " + app.Shelter.ShelterContact
+		}
+		if interview.InterviewNotes != "" {
+			description += "\n\n" + interview.InterviewNotes
+		}
+
+		var attendees []string
+		if app.Adopter.Email != "" {
+			attendees = append(attendees, app.Adopter.Email)
+		}
+		events = append(events, middleware.ICSEvent{
+			UID:         fmt.Sprintf("interview-%d@pethub", interview.InterviewID),
+			Summary:     fmt.Sprintf("Adoption interview: %s (%s)", app.Pet.PetName, app.Shelter.ShelterName),
+			Description: description,
+			Location:    app.Shelter.ShelterAddress,
+			Start:       interview.InterviewAt,
+			End:         interview.InterviewAt.Add(interviewDuration(db, interview)),
+			Cancelled:   interview.InterviewStatus == "cancelled" || interview.InterviewStatus == "rejected",
+			Sequence:    sequence[interview.InterviewID],
+			Updated:     interview.UpdatedAt,
+			Organizer:   app.Shelter.ShelterEmail,
+			Attendees:   attendees,
+		})
+	}
+	return events, nil
+}
+
+// DownloadInterviewICS returns a single interview as an .ics file.
+func DownloadInterviewICS(c *fiber.Ctx) error {
+	interview, retCode, err := findApplicationInterview(c.Params("application_id"))
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	if !callerIsAdopter(c, interview.AdopterID) && !callerIsShelter(c, interview.ShelterID) {
+		return c.JSON(response.ResponseModel{
+			RetCode: "403",
+			Message: "You are not a party to this interview",
+			Data:    nil,
+		})
+	}
+
+	events, err := interviewEvents(middleware.DBConn, []models.ScheduleInterview{interview})
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Database error while building calendar event",
+			Data:    err.Error(),
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="interview-%d.ics"`, interview.InterviewID))
+	return c.Send(middleware.BuildICS("", "PUBLISH", events))
+}
+
+func newFeedToken() (string, error) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// calendarFeedURL builds the subscription URL. PUBLIC_BASE_URL is used when
+// the API sits behind a proxy that changes the host.
+func calendarFeedURL(c *fiber.Ctx, token string) string {
+	base := strings.TrimRight(middleware.GetEnv("PUBLIC_BASE_URL"), "/")
+	if base == "" {
+		base = c.BaseURL()
+	}
+	return base + "/calendar/feed/" + token + ".ics"
+}
+
+// calendarFeed returns the owner's feed URL, creating the token on first use
+// or replacing it when rotate is set.
+func calendarFeed(c *fiber.Ctx, ownerType, ownerParam string, rotate bool) error {
+	ownerID, err := strconv.ParseUint(c.Params(ownerParam), 10, 32)
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid " + ownerType + " ID",
+			Data:    nil,
+		})
+	}
+	if !callerIs(c, ownerType, uint(ownerID)) {
+		return c.JSON(response.ResponseModel{
+			RetCode: "403",
+			Message: "You can only manage your own calendar feed",
+			Data:    nil,
+		})
+	}
+
+	var feed models.CalendarFeedToken
+	err = middleware.DBConn.Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).First(&feed).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching calendar feed",
+			Data:    err.Error(),
+		})
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) || rotate {
+		token, err := newFeedToken()
+		if err != nil {
+			return c.JSON(response.ResponseModel{
+				RetCode: "500",
+				Message: "Failed to generate calendar feed token",
+				Data:    err.Error(),
+			})
+		}
+		feed.OwnerType = ownerType
+		feed.OwnerID = uint(ownerID)
+		feed.Token = token
+		feed.CreatedAt = time.Now()
+		if err := middleware.DBConn.Save(&feed).Error; err != nil {
+			return c.JSON(response.ResponseModel{
+				RetCode: "500",
+				Message: "Failed to save calendar feed token",
+				Data:    err.Error(),
+			})
+		}
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data: fiber.Map{
+			"feed_url":   calendarFeedURL(c, feed.Token),
+			"created_at": feed.CreatedAt,
+		},
+	})
+}
+
+func GetShelterCalendarFeed(c *fiber.Ctx) error {
+	return calendarFeed(c, "shelter", "shelter_id", false)
+}
+
+func RotateShelterCalendarFeed(c *fiber.Ctx) error {
+	return calendarFeed(c, "shelter", "shelter_id", true)
+}
+
+func GetAdopterCalendarFeed(c *fiber.Ctx) error {
+	return calendarFeed(c, "adopter", "adopter_id", false)
+}
+
+func RotateAdopterCalendarFeed(c *fiber.Ctx) error {
+	return calendarFeed(c, "adopter", "adopter_id", true)
+}
+
+// ServeCalendarFeed is the public subscription endpoint. The token is the
+// only credential. Cancelled interviews stay in the feed as cancelled events
+// so subscribed calendars drop them.
+func ServeCalendarFeed(c *fiber.Ctx) error {
+	token := strings.TrimSuffix(c.Params("token"), ".ics")
+
+	var feed models.CalendarFeedToken
+	if err := middleware.DBConn.Where("token = ?", token).First(&feed).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).SendString("Calendar not found")
+		}
+		return c.Status(fiber.StatusInternalServerError).SendString("Database error")
+	}
+
+	ownerColumn := "shelter_id"
+	name := "PetHub shelter interviews"
+	if feed.OwnerType == "adopter" {
+		ownerColumn = "adopter_id"
+		name = "PetHub adoption interviews"
+	}
+
+	var interviews []models.ScheduleInterview
+	if err := middleware.DBConn.
+		Where(ownerColumn+" = ? AND interview_at >= ? AND interview_status IN ?", feed.OwnerID, time.Now(), []string{"scheduled", "rescheduled", "cancelled", "rejected"}).
+		Order("interview_at").
+		Find(&interviews).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Database error")
+	}
+
+	events, err := interviewEvents(middleware.DBConn, interviews)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Database error")
+	}
+
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	return c.Send(middleware.BuildICS(name, "PUBLISH", events))
+}
@@ -0,0 +1,367 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"pethub_api/middleware"
+	"pethub_api/models"
+	"pethub_api/models/response"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Used when a shelter has not saved its own letter template
+var defaultLetterTemplate = models.LetterTemplate{
+	Title: "Certificate of Adoption and Adoption Agreement",
+	Body: "This certifies that {{adopter_name}} has been approved by {{shelter_name}} to adopt " +
+		"{{pet_name}}, a {{pet_sex}} {{pet_type}}, on {{approval_date}}.\n" +
+		"By signing below, both parties agree to the terms of this adoption.",
+	Terms: "1. The adopter will provide proper food, shelter, veterinary care and affection for the pet.\n" +
+		"2. The adopter will not sell, give away or abandon the pet, and will contact {{shelter_name}} if they can no longer keep it.\n" +
+		"3. {{shelter_name}} may follow up on the pet's welfare after the adoption.",
+	FooterNote: "Thank you for giving a shelter pet a home.",
+}
+
+// contractDocumentNumber is derived from the approval year and application ID
+// so the same application always prints the same number.
+func contractDocumentNumber(application models.AdoptionSubmission) string {
+	return fmt.Sprintf("PH-%d-%06d", contractApprovalDate(application).Year(), application.ApplicationID)
+}
+
+// contractApprovalDate is when the application was approved.
+// loadContractApplication pins a missing approval date before printing, so
+// this and the document number never drift.
+func contractApprovalDate(application models.AdoptionSubmission) time.Time {
+	if application.ApprovedAt != nil {
+		return *application.ApprovedAt
+	}
+	return application.UpdatedAt
+}
+
+func findLetterTemplate(db *gorm.DB, shelterID uint) (models.LetterTemplate, error) {
+	var tmpl models.LetterTemplate
+	err := db.Where("shelter_id = ?", shelterID).First(&tmpl).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		tmpl = defaultLetterTemplate
+		tmpl.ShelterID = shelterID
+		return tmpl, nil
+	}
+	return tmpl, err
+}
+
+// loadContractApplication fetches an application with everything the contract
+// prints, and checks it belongs to the shelter and has been approved.
+func loadContractApplication(applicationID, shelterID string) (models.AdoptionSubmission, string, error) {
+	var application models.AdoptionSubmission
+	err := middleware.DBConn.
+		Preload("Adopter").
+		Preload("Pet").
+		Preload("Shelter").
+		Preload("Shelter.ShelterMedia").
+		Where("application_id = ?", applicationID).
+		First(&application).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return application, "404", errors.New("Application not found")
+	} else if err != nil {
+		return application, "500", errors.New("Database error while fetching application")
+	}
+
+	if shelterID != "" && strconv.FormatUint(uint64(application.ShelterID), 10) != shelterID {
+		return application, "404", errors.New("Application not found")
+	}
+	if application.Status != "approved" && application.Status != "completed" {
+		return application, "400", errors.New("Contract is only available for approved applications")
+	}
+
+	// Applications approved before approval dates were recorded take their
+	// last update once; updated_at keeps moving, the stored date does not
+	if application.ApprovedAt == nil {
+		approvedAt := application.UpdatedAt
+		if err := middleware.DBConn.Model(&models.AdoptionSubmission{}).
+			Where("application_id = ? AND approved_at IS NULL", application.ApplicationID).
+			UpdateColumn("approved_at", approvedAt).Error; err != nil {
+			return application, "500", errors.New("Database error while recording approval date")
+		}
+		var pinned models.AdoptionSubmission
+		if err := middleware.DBConn.Select("approved_at").
+			Where("application_id = ?", application.ApplicationID).
+			First(&pinned).Error; err != nil {
+			return application, "500", errors.New("Database error while fetching approval date")
+		}
+		application.ApprovedAt = pinned.ApprovedAt
+	}
+	return application, "200", nil
+}
+
+func fillLetterPlaceholders(text string, application models.AdoptionSubmission) string {
+	pet := application.Pet
+	adopter := application.Adopter
+	replacer := strings.NewReplacer(
+		"{{adopter_name}}", strings.TrimSpace(adopter.FirstName+" "+adopter.LastName),
+		"{{adopter_address}}", adopter.Address,
+		"{{adopter_contact}}", adopter.ContactNumber,
+		"{{adopter_email}}", adopter.Email,
+		"{{pet_name}}", pet.PetName,
+		"{{pet_type}}", strings.ToLower(pet.PetType),
+		"{{pet_sex}}", strings.ToLower(pet.PetSex),
+		"{{pet_age}}", fmt.Sprintf("%d %s", pet.PetAge, pet.AgeType),
+		"{{shelter_name}}", application.Shelter.ShelterName,
+		"{{shelter_address}}", application.Shelter.ShelterAddress,
+		"{{approval_date}}", contractApprovalDate(application).Format("January 2, 2006"),
+		"{{document_number}}", contractDocumentNumber(application),
+	)
+	return replacer.Replace(text)
+}
+
+// renderAdoptionContract lays out the certificate and contract for an
+// approved application. Signatures that have been captured are drawn into
+// their signature blocks.
+func renderAdoptionContract(application models.AdoptionSubmission, tmpl models.LetterTemplate, signatures []models.ContractSignature) []byte {
+	const left, right = 50.0, middleware.PDFPageWidth - 50.0
+	doc := middleware.NewPDF()
+	shelter := application.Shelter
+
+	// Letterhead, with the shelter logo when one is uploaded
+	textLeft := left
+	if shelter.ShelterMedia.ShelterProfile != "" {
+		if logo, err := base64.StdEncoding.DecodeString(shelter.ShelterMedia.ShelterProfile); err == nil {
+			if err := doc.Image(logo, left, 40, 60, 60); err == nil {
+				textLeft = left + 75
+			}
+		}
+	}
+	doc.Text(textLeft, 60, 16, true, shelter.ShelterName)
+	doc.Text(textLeft, 78, 9, false, shelter.ShelterAddress)
+	doc.Text(textLeft, 91, 9, false, strings.Trim(shelter.ShelterContact+"  |  "+shelter.ShelterEmail, " |"))
+	doc.Line(left, 112, right, 112, 1)
+
+	title := fillLetterPlaceholders(tmpl.Title, application)
+	doc.Text((middleware.PDFPageWidth-middleware.TextWidth(title, 15))/2, 142, 15, true, title)
+	doc.Text(left, 166, 9, false, "Document No.: "+contractDocumentNumber(application))
+	approval := "Approval Date: " + contractApprovalDate(application).Format("January 2, 2006")
+	doc.Text(right-middleware.TextWidth(approval, 9), 166, 9, false, approval)
+
+	y := 196.0
+	section := func(heading string, rows [][2]string) {
+		doc.Text(left, y, 11, true, heading)
+		y += 16
+		for _, row := range rows {
+			doc.Text(left+10, y, 10, true, row[0])
+			doc.Text(left+130, y, 10, false, row[1])
+			y += 14
+		}
+		y += 8
+	}
+
+	pet := application.Pet
+	section("Pet Details", [][2]string{
+		{"Name", pet.PetName},
+		{"Type", pet.PetType},
+		{"Sex", pet.PetSex},
+		{"Age", fmt.Sprintf("%d %s", pet.PetAge, pet.AgeType)},
+		{"Size", pet.PetSize},
+	})
+
+	adopter := application.Adopter
+	section("Adopter Details", [][2]string{
+		{"Name", strings.TrimSpace(adopter.FirstName + " " + adopter.LastName)},
+		{"Address", adopter.Address},
+		{"Contact Number", adopter.ContactNumber},
+		{"Email", adopter.Email},
+	})
+
+	y = doc.WrapText(left, y, right-left, 10, false, fillLetterPlaceholders(tmpl.Body, application))
+	y += 8
+	if tmpl.Terms != "" {
+		doc.Text(left, y, 11, true, "Terms of Adoption")
+		y = doc.WrapText(left, y+16, right-left, 9.5, false, fillLetterPlaceholders(tmpl.Terms, application))
+	}
+
+	// Signature blocks need about 90pt; move them to a fresh page if needed
+	if y+110 > middleware.PDFPageHeight-50 {
+		doc.AddPage()
+		y = 60
+	}
+	y += 50
+	blocks := []struct {
+		role, name, caption string
+		x                   float64
+	}{
+		{"adopter", strings.TrimSpace(adopter.FirstName + " " + adopter.LastName), "Adopter signature over printed name", left},
+		{"shelter", shelter.ShelterOwner, "For " + shelter.ShelterName, right - 200},
+	}
+	for _, block := range blocks {
+		name, date := block.name, "Date: ____________________"
+		for _, sig := range signatures {
+			if sig.SignerRole != block.role {
+				continue
+			}
+			if raw, err := base64.StdEncoding.DecodeString(sig.SignatureImage); err == nil {
+				doc.Image(raw, block.x+20, y-42, 120, 40)
+			}
+			name = sig.TypedName
+			date = "Signed electronically " + sig.SignedAt.Format("January 2, 2006 15:04 MST")
+		}
+		doc.Line(block.x, y, block.x+200, y, 0.8)
+		doc.Text(block.x, y+14, 10, true, name)
+		doc.Text(block.x, y+27, 9, false, block.caption)
+		doc.Text(block.x, y+45, 9, false, date)
+	}
+
+	if tmpl.FooterNote != "" {
+		note := fillLetterPlaceholders(tmpl.FooterNote, application)
+		doc.Text((middleware.PDFPageWidth-middleware.TextWidth(note, 9))/2, middleware.PDFPageHeight-35, 9, false, note)
+	}
+
+	return doc.Bytes()
+}
+
+// DownloadAdoptionContract streams the adoption certificate and contract as
+// a PDF.
+func DownloadAdoptionContract(c *fiber.Ctx) error {
+	application, retCode, err := loadContractApplication(c.Params("application_id"), c.Params("shelter_id"))
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	if !callerIsShelter(c, application.ShelterID) && !callerIsAdopter(c, application.AdopterID) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "You are not a party to this contract",
+			Data:    nil,
+		})
+	}
+
+	tmpl, err := findLetterTemplate(middleware.DBConn, application.ShelterID)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching letter template",
+			Data:    err.Error(),
+		})
+	}
+
+	pdf := renderAdoptionContract(application, tmpl, nil)
+
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.pdf"`, contractDocumentNumber(application)))
+	return c.Send(pdf)
+}
+
+func GetLetterTemplate(c *fiber.Ctx) error {
+	shelterID, err := strconv.ParseUint(c.Params("shelter_id"), 10, 32)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid shelter ID",
+			Data:    nil,
+		})
+	}
+	if !callerIsShelter(c, uint(shelterID)) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "You can only view your own shelter's contract template",
+			Data:    nil,
+		})
+	}
+
+	tmpl, err := findLetterTemplate(middleware.DBConn, uint(shelterID))
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching letter template",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data:    tmpl,
+	})
+}
+
+func UpdateLetterTemplate(c *fiber.Ctx) error {
+	shelterID, err := strconv.ParseUint(c.Params("shelter_id"), 10, 32)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid shelter ID",
+			Data:    nil,
+		})
+	}
+	if !callerIsShelter(c, uint(shelterID)) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "You can only change your own shelter's contract template",
+			Data:    nil,
+		})
+	}
+
+	tmpl, err := findLetterTemplate(middleware.DBConn, uint(shelterID))
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching letter template",
+			Data:    err.Error(),
+		})
+	}
+
+	var body struct {
+		Title      *string `json:"title"`
+		Body       *string `json:"body"`
+		Terms      *string `json:"terms"`
+		FooterNote *string `json:"footer_note"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		})
+	}
+
+	if body.Title != nil {
+		tmpl.Title = *body.Title
+	}
+	if body.Body != nil {
+		tmpl.Body = *body.Body
+	}
+	if body.Terms != nil {
+		tmpl.Terms = *body.Terms
+	}
+	if body.FooterNote != nil {
+		tmpl.FooterNote = *body.FooterNote
+	}
+
+	if strings.TrimSpace(tmpl.Title) == "" || strings.TrimSpace(tmpl.Body) == "" {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Title and body are required",
+			Data:    nil,
+		})
+	}
+
+	if err := middleware.DBConn.Save(&tmpl).Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to save letter template",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Letter template saved successfully",
+		Data:    tmpl,
+	})
+}
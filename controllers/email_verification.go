@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pethub_api/middleware"
+	"pethub_api/models"
+	"pethub_api/models/response"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const emailVerificationTTL = 15 * time.Minute
+
+// emailVerificationMaxAttempts is how many wrong guesses a code survives
+// before it is discarded and a new one must be requested.
+const emailVerificationMaxAttempts = 5
+
+// emailVerificationStore holds the code sent to each adopter, with the
+// address it was sent to so a code cannot verify an address changed since.
+var emailVerificationStore = struct {
+	sync.Mutex
+	codes map[uint]emailVerificationCode
+}{codes: make(map[uint]emailVerificationCode)}
+
+type emailVerificationCode struct {
+	Email     string
+	Code      string
+	ExpiresAt time.Time
+	Attempts  int
+}
+
+// markEmailUnverified clears the verified flag after the adopter's email
+// address changes.
+func markEmailUnverified(db *gorm.DB, adopterID uint) error {
+	return db.Model(&models.AdopterAccount{}).
+		Where("adopter_id = ?", adopterID).
+		Update("email_verified", false).Error
+}
+
+func loadVerifyingAdopter(c *fiber.Ctx) (models.AdopterInfo, string, error) {
+	var info models.AdopterInfo
+	adopterID, err := strconv.ParseUint(c.Params("adopter_id"), 10, 32)
+	if err != nil {
+		return info, "400", errors.New("Invalid adopter ID")
+	}
+	if !callerIsAdopter(c, uint(adopterID)) {
+		return info, "403", errors.New("You can only verify your own email address")
+	}
+	if err := middleware.DBConn.Where("adopter_id = ?", adopterID).First(&info).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return info, "404", errors.New("Adopter not found")
+		}
+		return info, "500", errors.New("Database error while fetching adopter")
+	}
+	if strings.TrimSpace(info.Email) == "" {
+		return info, "400", errors.New("Add an email address to your profile first")
+	}
+	return info, "200", nil
+}
+
+// RequestEmailVerification emails the adopter a code proving they own the
+// address on their profile.
+func RequestEmailVerification(c *fiber.Ctx) error {
+	info, retCode, err := loadVerifyingAdopter(c)
+	if err != nil {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	code := GenerateRandomCode()
+	body := "Your email verification code is: " + code + "\n\nThis code will expire in 15 minutes.\n\n" +
+		"If you did not request this, please ignore the message."
+	recipient := NotificationRecipient{Type: RecipientAdopter, ID: info.AdopterID, Email: info.Email}
+	if err := (emailChannel{}).Send(recipient, "Verify your email address", body); err != nil {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "500",
+			Message: "Failed to send verification email",
+			Data:    err.Error(),
+		})
+	}
+
+	emailVerificationStore.Lock()
+	emailVerificationStore.codes[info.AdopterID] = emailVerificationCode{
+		Email:     info.Email,
+		Code:      code,
+		ExpiresAt: time.Now().Add(emailVerificationTTL),
+	}
+	emailVerificationStore.Unlock()
+
+	return c.JSON(response.AdopterResponseModel{
+		RetCode: "200",
+		Message: "Verification code sent to your email",
+		Data:    nil,
+	})
+}
+
+// ConfirmEmailVerification marks the adopter's email verified when the code
+// matches the one sent to their current address.
+func ConfirmEmailVerification(c *fiber.Ctx) error {
+	info, retCode, err := loadVerifyingAdopter(c)
+	if err != nil {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		})
+	}
+
+	emailVerificationStore.Lock()
+	sent, exists := emailVerificationStore.codes[info.AdopterID]
+	valid := exists && time.Now().Before(sent.ExpiresAt) && strings.EqualFold(sent.Email, info.Email)
+	if valid && sent.Code == strings.TrimSpace(body.Code) {
+		delete(emailVerificationStore.codes, info.AdopterID)
+	} else {
+		valid = false
+		if exists {
+			sent.Attempts++
+			if sent.Attempts >= emailVerificationMaxAttempts {
+				delete(emailVerificationStore.codes, info.AdopterID)
+			} else {
+				emailVerificationStore.codes[info.AdopterID] = sent
+			}
+		}
+	}
+	emailVerificationStore.Unlock()
+
+	if !valid {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "401",
+			Message: "Code incorrect, expired or sent to a different address",
+			Data:    nil,
+		})
+	}
+
+	if err := middleware.DBConn.Model(&models.AdopterAccount{}).
+		Where("adopter_id = ?", info.AdopterID).
+		Update("email_verified", true).Error; err != nil {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "500",
+			Message: "Failed to mark email verified",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.AdopterResponseModel{
+		RetCode: "200",
+		Message: "Email verified",
+		Data:    nil,
+	})
+}
@@ -0,0 +1,368 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"strings"
+	"time"
+
+	"pethub_api/middleware"
+	"pethub_api/models"
+	"pethub_api/models/response"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const maxSignatureImageSize = 512 * 1024
+
+// The contract renderer holds the decoded pixels in memory, so signature
+// dimensions are capped as well as the file size.
+const (
+	maxSignatureImageWidth  = 2000
+	maxSignatureImageHeight = 1000
+)
+
+// readSignatureImage reads the drawn signature upload and checks it is a
+// PNG or JPEG the contract renderer can embed.
+func readSignatureImage(c *fiber.Ctx) (string, error) {
+	file, err := c.FormFile("signature_image")
+	if err != nil {
+		return "", errors.New("signature_image is required")
+	}
+	if file.Size > maxSignatureImageSize {
+		return "", errors.New("signature_image must be 512KB or smaller")
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return "", errors.New("failed to open signature image")
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return "", errors.New("failed to read signature image")
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", errors.New("signature_image must be a PNG or JPEG")
+	}
+	if config.Width > maxSignatureImageWidth || config.Height > maxSignatureImageHeight {
+		return "", fmt.Errorf("signature_image must be at most %dx%d pixels", maxSignatureImageWidth, maxSignatureImageHeight)
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func loadContractSignatures(db *gorm.DB, applicationID uint) ([]models.ContractSignature, error) {
+	var signatures []models.ContractSignature
+	err := db.Where("application_id = ?", applicationID).Order("signed_at").Find(&signatures).Error
+	return signatures, err
+}
+
+// callerIs checks the JWT subject against an account of the given role.
+// Adopter and shelter IDs overlap, so both the "id" and "role" claims must
+// match.
+func callerIs(c *fiber.Ctx, role string, id uint) bool {
+	callerID, err := middleware.GetAdopterIDFromJWT(c)
+	return err == nil && callerID == id && middleware.GetRoleFromJWT(c) == role
+}
+
+// callerIsShelter checks that the caller is signed in as the given shelter.
+func callerIsShelter(c *fiber.Ctx, shelterID uint) bool {
+	return callerIs(c, middleware.RoleShelter, shelterID)
+}
+
+// callerIsAdopter checks that the caller is signed in as the given adopter.
+func callerIsAdopter(c *fiber.Ctx, adopterID uint) bool {
+	return callerIs(c, middleware.RoleAdopter, adopterID)
+}
+
+// ReviewAdoptionContract returns the contract as it currently stands, with
+// any signatures captured so far. The X-Document-SHA256 header carries the
+// hash of the unsigned contract, which signers send back to confirm what
+// they reviewed.
+func ReviewAdoptionContract(c *fiber.Ctx) error {
+	application, retCode, err := loadContractApplication(c.Params("application_id"), "")
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	if !callerIsAdopter(c, application.AdopterID) && !callerIsShelter(c, application.ShelterID) {
+		return c.JSON(response.ResponseModel{
+			RetCode: "403",
+			Message: "You are not a party to this contract",
+			Data:    nil,
+		})
+	}
+
+	tmpl, err := findLetterTemplate(middleware.DBConn, application.ShelterID)
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching letter template",
+			Data:    err.Error(),
+		})
+	}
+	signatures, err := loadContractSignatures(middleware.DBConn, application.ApplicationID)
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching signatures",
+			Data:    err.Error(),
+		})
+	}
+
+	c.Set("X-Document-SHA256", sha256Hex(renderAdoptionContract(application, tmpl, nil)))
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`inline; filename="%s.pdf"`, contractDocumentNumber(application)))
+	return c.Send(renderAdoptionContract(application, tmpl, signatures))
+}
+
+// SignAdoptionContract captures the adopter's signature.
+func SignAdoptionContract(c *fiber.Ctx) error {
+	return signAdoptionContract(c, "adopter")
+}
+
+// CountersignAdoptionContract captures the shelter's signature and, with
+// both signatures present, seals the final document.
+func CountersignAdoptionContract(c *fiber.Ctx) error {
+	return signAdoptionContract(c, "shelter")
+}
+
+func signAdoptionContract(c *fiber.Ctx, role string) error {
+	application, retCode, err := loadContractApplication(c.Params("application_id"), "")
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	if application.Status != "approved" {
+		return c.JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Contract can only be signed while the application is approved",
+			Data:    nil,
+		})
+	}
+
+	signerID := application.AdopterID
+	if role == "shelter" {
+		signerID = application.ShelterID
+	}
+	if !callerIs(c, role, signerID) {
+		return c.JSON(response.ResponseModel{
+			RetCode: "403",
+			Message: "You are not allowed to sign this contract",
+			Data:    nil,
+		})
+	}
+
+	typedName := strings.TrimSpace(c.FormValue("typed_name"))
+	if typedName == "" {
+		return c.JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "typed_name is required",
+			Data:    nil,
+		})
+	}
+	signatureImage, err := readSignatureImage(c)
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	tmpl, err := findLetterTemplate(middleware.DBConn, application.ShelterID)
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching letter template",
+			Data:    err.Error(),
+		})
+	}
+
+	// The signer proves which contract they reviewed with its hash, and the
+	// signature is refused if the contract changed since
+	sent := strings.TrimSpace(c.FormValue("document_hash"))
+	if sent == "" {
+		return c.JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "document_hash is required; send the X-Document-SHA256 of the reviewed contract",
+			Data:    nil,
+		})
+	}
+	reviewedHash := sha256Hex(renderAdoptionContract(application, tmpl, nil))
+	if !strings.EqualFold(sent, reviewedHash) {
+		return c.JSON(response.ResponseModel{
+			RetCode: "409",
+			Message: "The contract has changed since it was reviewed; please review it again",
+			Data:    nil,
+		})
+	}
+
+	tx := middleware.DBConn.Begin()
+
+	signatures, err := loadContractSignatures(tx, application.ApplicationID)
+	if err != nil {
+		tx.Rollback()
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching signatures",
+			Data:    err.Error(),
+		})
+	}
+	adopterSigned := false
+	for _, sig := range signatures {
+		if sig.SignerRole == "adopter" {
+			adopterSigned = true
+		}
+	}
+	if role == "shelter" && !adopterSigned {
+		tx.Rollback()
+		return c.JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "The adopter has to sign before the shelter countersigns",
+			Data:    nil,
+		})
+	}
+
+	signature := models.ContractSignature{
+		ApplicationID:  application.ApplicationID,
+		SignerRole:     role,
+		SignerID:       signerID,
+		TypedName:      typedName,
+		SignatureImage: signatureImage,
+		ReviewedHash:   reviewedHash,
+		IPAddress:      c.IP(),
+		SignedAt:       time.Now(),
+	}
+	if err := tx.Create(&signature).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return c.JSON(response.ResponseModel{
+				RetCode: "409",
+				Message: "This contract has already been signed by the " + role,
+				Data:    nil,
+			})
+		}
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to save signature",
+			Data:    err.Error(),
+		})
+	}
+
+	var sealed *models.SignedContract
+	if role == "shelter" {
+		document := renderAdoptionContract(application, tmpl, append(signatures, signature))
+		sealed = &models.SignedContract{
+			ApplicationID:  application.ApplicationID,
+			DocumentNumber: contractDocumentNumber(application),
+			Document:       base64.StdEncoding.EncodeToString(document),
+			SHA256:         sha256Hex(document),
+			FinalizedAt:    time.Now(),
+		}
+		if err := tx.Create(sealed).Error; err != nil {
+			tx.Rollback()
+			return c.JSON(response.ResponseModel{
+				RetCode: "500",
+				Message: "Failed to store signed contract",
+				Data:    err.Error(),
+			})
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to save signature",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Contract signed successfully",
+		Data: fiber.Map{
+			"signature_id":    signature.SignatureID,
+			"signer_role":     signature.SignerRole,
+			"signed_at":       signature.SignedAt,
+			"reviewed_hash":   signature.ReviewedHash,
+			"signed_contract": sealed,
+		},
+	})
+}
+
+// GetSignedContract streams the sealed, countersigned contract.
+func GetSignedContract(c *fiber.Ctx) error {
+	var application models.AdoptionSubmission
+	if err := middleware.DBConn.Where("application_id = ?", c.Params("application_id")).First(&application).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(response.ResponseModel{
+				RetCode: "404",
+				Message: "Application not found",
+				Data:    nil,
+			})
+		}
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching application",
+			Data:    err.Error(),
+		})
+	}
+	if !callerIsAdopter(c, application.AdopterID) && !callerIsShelter(c, application.ShelterID) {
+		return c.JSON(response.ResponseModel{
+			RetCode: "403",
+			Message: "You are not a party to this contract",
+			Data:    nil,
+		})
+	}
+
+	var contract models.SignedContract
+	if err := middleware.DBConn.Where("application_id = ?", application.ApplicationID).First(&contract).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(response.ResponseModel{
+				RetCode: "404",
+				Message: "Contract has not been signed by both parties yet",
+				Data:    nil,
+			})
+		}
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching signed contract",
+			Data:    err.Error(),
+		})
+	}
+
+	document, err := base64.StdEncoding.DecodeString(contract.Document)
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Stored contract is corrupted",
+			Data:    nil,
+		})
+	}
+
+	c.Set("X-Document-SHA256", contract.SHA256)
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s-signed.pdf"`, contract.DocumentNumber))
+	return c.Send(document)
+}
@@ -0,0 +1,637 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"pethub_api/middleware"
+	"pethub_api/models"
+	"pethub_api/models/response"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const maxHomeVisitPhotoSize = 5 * 1024 * 1024
+
+// Every visit starts with these checklist items; shelters can add their own
+// when scheduling.
+var defaultHomeVisitChecklist = []models.HomeVisitItem{
+	{Key: "fenced_yard", Label: "Fenced yard or secure outdoor area"},
+	{Key: "other_pets", Label: "Other pets in the home met and compatible"},
+	{Key: "hazards", Label: "No hazards such as toxic plants, exposed wiring or unsecured chemicals"},
+}
+
+var homeVisitItemResults = map[string]bool{
+	"pass":           true,
+	"fail":           true,
+	"not_applicable": true,
+}
+
+// hasPassingHomeVisit reports whether approval can go ahead: either the
+// shelter does not require home visits, or one of the application's visits
+// passed.
+func hasPassingHomeVisit(db *gorm.DB, application models.AdoptionSubmission) (bool, error) {
+	var shelter models.ShelterInfo
+	if err := db.Select("shelter_id, require_home_visit").Where("shelter_id = ?", application.ShelterID).First(&shelter).Error; err != nil {
+		return false, err
+	}
+	if !shelter.RequireHomeVisit {
+		return true, nil
+	}
+
+	var passed int64
+	if err := db.Model(&models.HomeVisit{}).
+		Where("application_id = ? AND status = ? AND outcome = ?", application.ApplicationID, "completed", "pass").
+		Count(&passed).Error; err != nil {
+		return false, err
+	}
+	return passed > 0, nil
+}
+
+// findHomeVisit loads a visit with its checklist and photos, making sure it
+// belongs to the application in the URL and to the calling shelter.
+func findHomeVisit(c *fiber.Ctx) (models.HomeVisit, string, error) {
+	var visit models.HomeVisit
+	err := middleware.DBConn.
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Preload("Photos").
+		Where("visit_id = ? AND application_id = ?", c.Params("visit_id"), c.Params("application_id")).
+		First(&visit).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return visit, "404", errors.New("Home visit not found")
+	} else if err != nil {
+		return visit, "500", errors.New("Database error while fetching home visit")
+	}
+	if !callerIsShelter(c, visit.ShelterID) {
+		return visit, "403", errors.New("Only the application's shelter can manage its home visits")
+	}
+	return visit, "200", nil
+}
+
+// checklistKey turns a free-text label into the key used to refer to an item.
+func checklistKey(label string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(label)) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "_"):
+			b.WriteByte('_')
+		}
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
+
+func UpdateHomeVisitSettings(c *fiber.Ctx) error {
+	shelterID, err := strconv.ParseUint(c.Params("shelter_id"), 10, 32)
+	if err != nil || !callerIsShelter(c, uint(shelterID)) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "You can only change your own shelter's home visit settings",
+			Data:    nil,
+		})
+	}
+
+	var body struct {
+		RequireHomeVisit *bool `json:"require_home_visit"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.RequireHomeVisit == nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "require_home_visit is required",
+			Data:    nil,
+		})
+	}
+
+	result := middleware.DBConn.Model(&models.ShelterInfo{}).
+		Where("shelter_id = ?", shelterID).
+		Update("require_home_visit", *body.RequireHomeVisit)
+	if result.Error != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to update home visit settings",
+			Data:    result.Error.Error(),
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "404",
+			Message: "Shelter not found",
+			Data:    nil,
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Home visit settings updated",
+		Data:    fiber.Map{"require_home_visit": *body.RequireHomeVisit},
+	})
+}
+
+// ScheduleHomeVisit books a home visit for an application in the interview
+// stage. A new visit can be booked after a failed or cancelled one.
+func ScheduleHomeVisit(c *fiber.Ctx) error {
+	var body struct {
+		VisitAt   string   `json:"visit_at"`   // RFC 3339
+		VisitDate string   `json:"visit_date"` // or a date and time in timezone
+		VisitTime string   `json:"visit_time"`
+		Timezone  string   `json:"timezone"` // defaults to the shelter's
+		Address   string   `json:"address"`  // defaults to the adopter's address
+		Notes     string   `json:"notes"`
+		Checklist []string `json:"checklist"` // extra items on top of the defaults
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		})
+	}
+
+	var application models.AdoptionSubmission
+	if err := middleware.DBConn.Preload("Adopter").Where("application_id = ?", c.Params("application_id")).First(&application).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "404",
+				Message: "Application not found",
+				Data:    nil,
+			})
+		}
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching application",
+			Data:    err.Error(),
+		})
+	}
+	if !callerIsShelter(c, application.ShelterID) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "Only the application's shelter can schedule a home visit",
+			Data:    nil,
+		})
+	}
+	if application.Status != "interview" {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Home visits can only be scheduled for applications in the interview stage",
+			Data:    nil,
+		})
+	}
+
+	zone := body.Timezone
+	if zone == "" {
+		zone = shelterTimezone(middleware.DBConn, application.ShelterID)
+	}
+	visitAt, err := parseInterviewInstant(body.VisitAt, body.VisitDate, body.VisitTime, zone)
+	if err == nil {
+		_, err = loadTimezone(zone)
+	}
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: strings.ReplaceAll(err.Error(), "interview_", "visit_"),
+			Data:    nil,
+		})
+	}
+	if !visitAt.After(time.Now()) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Visit time must be in the future",
+			Data:    nil,
+		})
+	}
+
+	var open int64
+	if err := middleware.DBConn.Model(&models.HomeVisit{}).
+		Where("application_id = ? AND status = ?", application.ApplicationID, "scheduled").
+		Count(&open).Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Database error while checking home visits",
+			Data:    err.Error(),
+		})
+	}
+	if open > 0 {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "409",
+			Message: "A home visit is already scheduled for this application",
+			Data:    nil,
+		})
+	}
+
+	items := append([]models.HomeVisitItem{}, defaultHomeVisitChecklist...)
+	seen := map[string]bool{}
+	for _, item := range items {
+		seen[item.Key] = true
+	}
+	for _, label := range body.Checklist {
+		key := checklistKey(label)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		items = append(items, models.HomeVisitItem{Key: key, Label: strings.TrimSpace(label)})
+	}
+	for i := range items {
+		items[i].Position = i + 1
+	}
+
+	address := strings.TrimSpace(body.Address)
+	if address == "" {
+		address = application.Adopter.Address
+	}
+
+	visit := models.HomeVisit{
+		ApplicationID: application.ApplicationID,
+		ShelterID:     application.ShelterID,
+		AdopterID:     application.AdopterID,
+		VisitAt:       visitAt.UTC(),
+		Timezone:      zone,
+		Address:       address,
+		Notes:         body.Notes,
+		Status:        "scheduled",
+		CreatedAt:     time.Now(),
+		Items:         items,
+	}
+	if err := middleware.DBConn.Create(&visit).Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to schedule home visit",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Home visit scheduled",
+		Data:    visit,
+	})
+}
+
+// UpdateHomeVisitChecklist records inspection results for checklist items.
+func UpdateHomeVisitChecklist(c *fiber.Ctx) error {
+	var body struct {
+		Items []struct {
+			Key    string `json:"key"`
+			Result string `json:"result"`
+			Notes  string `json:"notes"`
+		} `json:"items"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		})
+	}
+
+	visit, retCode, err := findHomeVisit(c)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	if visit.Status != "scheduled" {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "The checklist can only be changed before the outcome is recorded",
+			Data:    nil,
+		})
+	}
+
+	byKey := make(map[string]*models.HomeVisitItem, len(visit.Items))
+	for i := range visit.Items {
+		byKey[visit.Items[i].Key] = &visit.Items[i]
+	}
+	for _, update := range body.Items {
+		item, ok := byKey[update.Key]
+		if !ok {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "400",
+				Message: fmt.Sprintf("Unknown checklist item '%s'", update.Key),
+				Data:    nil,
+			})
+		}
+		if update.Result != "" && !homeVisitItemResults[update.Result] {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "400",
+				Message: "result must be one of pass, fail, not_applicable",
+				Data:    nil,
+			})
+		}
+		item.Result = update.Result
+		item.Notes = update.Notes
+	}
+
+	tx := middleware.DBConn.Begin()
+	for _, item := range visit.Items {
+		if err := tx.Model(&models.HomeVisitItem{}).Where("item_id = ?", item.ItemID).
+			Updates(map[string]interface{}{"result": item.Result, "notes": item.Notes}).Error; err != nil {
+			tx.Rollback()
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "500",
+				Message: "Failed to update checklist",
+				Data:    err.Error(),
+			})
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to update checklist",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Checklist updated",
+		Data:    visit,
+	})
+}
+
+// UploadHomeVisitPhoto attaches a photo to a visit, optionally against a
+// checklist item.
+func UploadHomeVisitPhoto(c *fiber.Ctx) error {
+	visit, retCode, err := findHomeVisit(c)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	if visit.Status == "cancelled" {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Cannot add photos to a cancelled visit",
+			Data:    nil,
+		})
+	}
+
+	itemKey := c.FormValue("item_key")
+	if itemKey != "" {
+		found := false
+		for _, item := range visit.Items {
+			found = found || item.Key == itemKey
+		}
+		if !found {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "400",
+				Message: fmt.Sprintf("Unknown checklist item '%s'", itemKey),
+				Data:    nil,
+			})
+		}
+	}
+
+	file, err := c.FormFile("photo")
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "photo is required",
+			Data:    nil,
+		})
+	}
+	if file.Size > maxHomeVisitPhotoSize {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "photo must be 5MB or smaller",
+			Data:    nil,
+		})
+	}
+	f, err := file.Open()
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to open photo",
+			Data:    err.Error(),
+		})
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to read photo",
+			Data:    err.Error(),
+		})
+	}
+	if _, _, err := image.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "photo must be a PNG or JPEG",
+			Data:    nil,
+		})
+	}
+
+	photo := models.HomeVisitPhoto{
+		VisitID:   visit.VisitID,
+		ItemKey:   itemKey,
+		Image:     base64.StdEncoding.EncodeToString(data),
+		Caption:   c.FormValue("caption"),
+		CreatedAt: time.Now(),
+	}
+	if err := middleware.DBConn.Create(&photo).Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to save photo",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Photo uploaded",
+		Data:    photo,
+	})
+}
+
+// RecordHomeVisitOutcome closes a visit as passed or failed. Every checklist
+// item must have a result first.
+func RecordHomeVisitOutcome(c *fiber.Ctx) error {
+	var body struct {
+		Outcome string `json:"outcome"` // pass or fail
+		Notes   string `json:"notes"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		})
+	}
+	if body.Outcome != "pass" && body.Outcome != "fail" {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "outcome must be pass or fail",
+			Data:    nil,
+		})
+	}
+
+	visit, retCode, err := findHomeVisit(c)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	if visit.Status != "scheduled" {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Outcome has already been recorded or the visit was cancelled",
+			Data:    nil,
+		})
+	}
+	for _, item := range visit.Items {
+		if item.Result == "" {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "400",
+				Message: fmt.Sprintf("Checklist item '%s' has no result yet", item.Key),
+				Data:    nil,
+			})
+		}
+		if body.Outcome == "pass" && item.Result == "fail" {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "400",
+				Message: fmt.Sprintf("Cannot pass a visit with a failed checklist item ('%s')", item.Key),
+				Data:    nil,
+			})
+		}
+	}
+
+	now := time.Now()
+	visit.Status = "completed"
+	visit.Outcome = body.Outcome
+	visit.OutcomeNotes = body.Notes
+	visit.CompletedAt = &now
+	if err := middleware.DBConn.Model(&visit).Updates(map[string]interface{}{
+		"status":        visit.Status,
+		"outcome":       visit.Outcome,
+		"outcome_notes": visit.OutcomeNotes,
+		"completed_at":  visit.CompletedAt,
+	}).Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to record home visit outcome",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Home visit outcome recorded",
+		Data:    visit,
+	})
+}
+
+func CancelHomeVisit(c *fiber.Ctx) error {
+	visit, retCode, err := findHomeVisit(c)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	if visit.Status != "scheduled" {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Only scheduled visits can be cancelled",
+			Data:    nil,
+		})
+	}
+
+	visit.Status = "cancelled"
+	if err := middleware.DBConn.Model(&visit).Update("status", visit.Status).Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to cancel home visit",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Home visit cancelled",
+		Data:    visit,
+	})
+}
+
+// GetHomeVisits lists an application's visits for either party, along with
+// whether the shelter requires a passing visit before approval.
+func GetHomeVisits(c *fiber.Ctx) error {
+	applicationID, err := strconv.ParseUint(c.Params("application_id"), 10, 32)
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid application ID",
+			Data:    nil,
+		})
+	}
+
+	var application models.AdoptionSubmission
+	if err := middleware.DBConn.Where("application_id = ?", applicationID).First(&application).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(response.ResponseModel{
+				RetCode: "404",
+				Message: "Application not found",
+				Data:    nil,
+			})
+		}
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching application",
+			Data:    err.Error(),
+		})
+	}
+	if !callerIsAdopter(c, application.AdopterID) && !callerIsShelter(c, application.ShelterID) {
+		return c.JSON(response.ResponseModel{
+			RetCode: "403",
+			Message: "You are not a party to this application",
+			Data:    nil,
+		})
+	}
+
+	visits := []models.HomeVisit{}
+	if err := middleware.DBConn.
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Preload("Photos").
+		Where("application_id = ?", applicationID).
+		Order("visit_at DESC").
+		Find(&visits).Error; err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching home visits",
+			Data:    err.Error(),
+		})
+	}
+
+	cleared, err := hasPassingHomeVisit(middleware.DBConn, application)
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Database error while checking home visits",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data: fiber.Map{
+			"visits":               visits,
+			"cleared_for_approval": cleared,
+		},
+	})
+}
@@ -0,0 +1,878 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"pethub_api/middleware"
+	"pethub_api/models"
+	"pethub_api/models/response"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var interviewOutcomes = map[string]bool{
+	"recommend":        true,
+	"do_not_recommend": true,
+	"needs_follow_up":  true,
+}
+
+// interviewIsOpen reports whether the interview is still expected to happen.
+func interviewIsOpen(interview models.ScheduleInterview) bool {
+	return interview.InterviewStatus == "scheduled" || interview.InterviewStatus == "rescheduled"
+}
+
+func findApplicationInterview(applicationID string) (models.ScheduleInterview, string, error) {
+	var interview models.ScheduleInterview
+	err := middleware.DBConn.Where("application_id = ?", applicationID).First(&interview).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return interview, "404", errors.New("No interview scheduled for this application")
+	} else if err != nil {
+		return interview, "500", errors.New("Database error while fetching interview")
+	}
+	return interview, "200", nil
+}
+
+// recordInterviewHistory appends an entry for the interview's current time.
+// oldAt is the time before the change, if it moved.
+func recordInterviewHistory(db *gorm.DB, interview models.ScheduleInterview, action, actorRole string, oldAt *time.Time, reason string) error {
+	newAt := interview.InterviewAt
+	return db.Create(&models.InterviewHistory{
+		InterviewID:   interview.InterviewID,
+		ApplicationID: interview.ApplicationID,
+		Action:        action,
+		ActorRole:     actorRole,
+		OldAt:         oldAt,
+		NewAt:         &newAt,
+		Reason:        reason,
+		CreatedAt:     time.Now(),
+	}).Error
+}
+
+// moveInterview changes the time of an open interview and logs the move.
+func moveInterview(db *gorm.DB, interview *models.ScheduleInterview, at time.Time, zone, action, actorRole, reason string) error {
+	oldAt := interview.InterviewAt
+	interview.InterviewAt = at.UTC()
+	interview.Timezone = zone
+	interview.InterviewStatus = "rescheduled"
+	if err := db.Save(interview).Error; err != nil {
+		return err
+	}
+	if err := reserveInterviewSlot(db, *interview); err != nil {
+		return err
+	}
+	return recordInterviewHistory(db, *interview, action, actorRole, &oldAt, reason)
+}
+
+// advanceToInterview moves an application to the interview stage, queues the
+// other applications for the same pet and holds the pet as pending.
+func advanceToInterview(db *gorm.DB, application *models.AdoptionSubmission) (string, error) {
+	// Update the selected application status to 'interview'
+	oldStatus := application.Status
+	application.Status = "interview"
+	if err := db.Save(application).Error; err != nil {
+		return "500", errors.New("Failed to update application status")
+	}
+	if err := publishApplicationStatus(db, *application, oldStatus); err != nil {
+		return "500", errors.New("Failed to notify adopter")
+	}
+
+	// Set all other applications for the same pet to 'in queue'
+	var others []models.AdoptionSubmission
+	if err := db.Where("pet_id = ? AND application_id != ? AND status IN ?", application.PetID, application.ApplicationID, activeApplicationStatuses).
+		Find(&others).Error; err != nil {
+		return "500", errors.New("Failed to fetch other applications")
+	}
+	for _, other := range others {
+		oldStatus := other.Status
+		other.Status = "in queue"
+		if err := db.Model(&other).Update("status", other.Status).Error; err != nil {
+			return "500", errors.New("Failed to update other applications")
+		}
+		if err := publishApplicationStatus(db, other, oldStatus); err != nil {
+			return "500", errors.New("Failed to notify adopter")
+		}
+	}
+
+	// Hold the pet as pending
+	var pet models.PetInfo
+	if err := db.Where("pet_id = ?", application.PetID).First(&pet).Error; err != nil {
+		return "500", errors.New("Failed to fetch pet")
+	}
+	change := byShelter(application.ShelterID, "Interview stage").forApplication(application.ApplicationID)
+	if err := setPetStatus(db, &pet, models.PetPending, change); err != nil {
+		if errors.Is(err, ErrPetStatusChange) {
+			return "409", err
+		}
+		return "500", errors.New("Failed to update pet status")
+	}
+	return "200", nil
+}
+
+// InviteToInterview advances an application to the interview stage without
+// fixing a time, so the adopter can book one of the shelter's slots.
+func InviteToInterview(c *fiber.Ctx) error {
+	var application models.AdoptionSubmission
+	if err := middleware.DBConn.Where("application_id = ?", c.Params("application_id")).First(&application).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "404",
+				Message: "Application not found",
+				Data:    nil,
+			})
+		}
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching application",
+			Data:    err.Error(),
+		})
+	}
+	if !callerIsShelter(c, application.ShelterID) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "You can only invite applicants of your own shelter",
+			Data:    nil,
+		})
+	}
+	if application.Status != "pending" && application.Status != "in queue" {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Only pending applications can be invited to an interview",
+			Data:    nil,
+		})
+	}
+
+	tx := middleware.DBConn.Begin()
+	if retCode, err := advanceToInterview(tx, &application); err != nil {
+		tx.Rollback()
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to update application status",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Application invited to interview. The adopter can now book a slot.",
+		Data:    application,
+	})
+}
+
+// RescheduleInterview moves an interview to a new slot. A reason is required
+// so the adopter knows why it changed.
+func RescheduleInterview(c *fiber.Ctx) error {
+	var body struct {
+		InterviewAt   string `json:"interview_at"`   // RFC 3339
+		InterviewDate string `json:"interview_date"` // or a date and time in timezone
+		InterviewTime string `json:"interview_time"`
+		Timezone      string `json:"timezone"` // defaults to the interview's current zone
+		Reason        string `json:"reason"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		})
+	}
+	if strings.TrimSpace(body.Reason) == "" {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "A reason is required to reschedule an interview",
+			Data:    nil,
+		})
+	}
+
+	interview, retCode, err := findApplicationInterview(c.Params("application_id"))
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	if !callerIsShelter(c, interview.ShelterID) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "You can only reschedule your own interviews",
+			Data:    nil,
+		})
+	}
+	if !interviewIsOpen(interview) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Only scheduled interviews can be rescheduled",
+			Data:    nil,
+		})
+	}
+
+	zone := body.Timezone
+	if zone == "" {
+		zone = interview.Timezone
+	}
+	if zone == "" {
+		zone = shelterTimezone(middleware.DBConn, interview.ShelterID)
+	}
+	at, err := parseInterviewInstant(body.InterviewAt, body.InterviewDate, body.InterviewTime, zone)
+	if err == nil {
+		_, err = loadTimezone(zone)
+	}
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	if retCode, err := validateInterviewInstant(middleware.DBConn, interview.ShelterID, at); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	tx := middleware.DBConn.Begin()
+	if err := moveInterview(tx, &interview, at, zone, "rescheduled", "shelter", body.Reason); err != nil {
+		tx.Rollback()
+		if errors.Is(err, ErrSlotFull) {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "409",
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to reschedule interview",
+			Data:    err.Error(),
+		})
+	}
+	// A shelter-initiated move supersedes anything the adopter asked for
+	if err := tx.Model(&models.InterviewRescheduleRequest{}).
+		Where("interview_id = ? AND status = ?", interview.InterviewID, "pending").
+		Updates(map[string]interface{}{"status": "declined", "response_note": "Superseded by shelter reschedule", "responded_at": time.Now()}).Error; err != nil {
+		tx.Rollback()
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to update reschedule requests",
+			Data:    err.Error(),
+		})
+	}
+	if err := announceInterviewChange(tx, interview, "rescheduled"); err != nil {
+		tx.Rollback()
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to queue interview notifications",
+			Data:    err.Error(),
+		})
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to reschedule interview",
+			Data:    err.Error(),
+		})
+	}
+
+	localizeInterview(middleware.DBConn, &interview)
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Interview rescheduled",
+		Data:    interview,
+	})
+}
+
+// CancelInterview calls off an interview. The application stays in the
+// interview stage so the shelter can book a new slot or reject it.
+func CancelInterview(c *fiber.Ctx) error {
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		})
+	}
+	if strings.TrimSpace(body.Reason) == "" {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "A reason is required to cancel an interview",
+			Data:    nil,
+		})
+	}
+	return closeInterview(c, "cancelled", body.Reason, "Interview cancelled")
+}
+
+// MarkInterviewNoShow records that the adopter did not attend.
+func MarkInterviewNoShow(c *fiber.Ctx) error {
+	var body struct {
+		Notes string `json:"notes"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		})
+	}
+	return closeInterview(c, "no_show", body.Notes, "Interview marked as no-show")
+}
+
+func closeInterview(c *fiber.Ctx, status, reason, message string) error {
+	interview, retCode, err := findApplicationInterview(c.Params("application_id"))
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	if !callerIsShelter(c, interview.ShelterID) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "You can only update your own interviews",
+			Data:    nil,
+		})
+	}
+	if !interviewIsOpen(interview) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Interview is not scheduled",
+			Data:    nil,
+		})
+	}
+
+	interview.InterviewStatus = status
+	if status == "cancelled" {
+		interview.CancelReason = reason
+	}
+
+	tx := middleware.DBConn.Begin()
+	if err := tx.Save(&interview).Error; err != nil {
+		tx.Rollback()
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to update interview",
+			Data:    err.Error(),
+		})
+	}
+	if err := releaseInterviewSlot(tx, interview.ApplicationID); err != nil {
+		tx.Rollback()
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to release interview slot",
+			Data:    err.Error(),
+		})
+	}
+	if err := tx.Model(&models.InterviewRescheduleRequest{}).
+		Where("interview_id = ? AND status = ?", interview.InterviewID, "pending").
+		Updates(map[string]interface{}{"status": "declined", "response_note": "Interview " + strings.ReplaceAll(status, "_", "-"), "responded_at": time.Now()}).Error; err != nil {
+		tx.Rollback()
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to update reschedule requests",
+			Data:    err.Error(),
+		})
+	}
+	if err := recordInterviewHistory(tx, interview, status, "shelter", nil, reason); err != nil {
+		tx.Rollback()
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to record interview history",
+			Data:    err.Error(),
+		})
+	}
+	if status == "cancelled" {
+		if err := announceInterviewChange(tx, interview, "cancelled"); err != nil {
+			tx.Rollback()
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "500",
+				Message: "Failed to queue interview notifications",
+				Data:    err.Error(),
+			})
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to update interview",
+			Data:    err.Error(),
+		})
+	}
+
+	localizeInterview(middleware.DBConn, &interview)
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: message,
+		Data:    interview,
+	})
+}
+
+// RecordInterviewOutcome stores the shelter's recommendation and notes once
+// the interview has taken place.
+func RecordInterviewOutcome(c *fiber.Ctx) error {
+	var body struct {
+		Recommendation string `json:"recommendation"`
+		Notes          string `json:"notes"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		})
+	}
+	if !interviewOutcomes[body.Recommendation] {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "recommendation must be one of recommend, do_not_recommend, needs_follow_up",
+			Data:    nil,
+		})
+	}
+
+	interview, retCode, err := findApplicationInterview(c.Params("application_id"))
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	if !callerIsShelter(c, interview.ShelterID) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "You can only record outcomes for your own interviews",
+			Data:    nil,
+		})
+	}
+	if interview.InterviewStatus == "cancelled" || interview.InterviewStatus == "no_show" {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Cannot record an outcome for an interview that did not take place",
+			Data:    nil,
+		})
+	}
+
+	now := time.Now()
+	interview.OutcomeRecommendation = body.Recommendation
+	interview.OutcomeNotes = body.Notes
+	interview.OutcomeRecordedAt = &now
+	if interviewIsOpen(interview) {
+		interview.InterviewStatus = "completed"
+	}
+
+	tx := middleware.DBConn.Begin()
+	if err := tx.Save(&interview).Error; err != nil {
+		tx.Rollback()
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to record interview outcome",
+			Data:    err.Error(),
+		})
+	}
+	if err := recordInterviewHistory(tx, interview, "outcome_recorded", "shelter", nil, body.Recommendation); err != nil {
+		tx.Rollback()
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to record interview history",
+			Data:    err.Error(),
+		})
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to record interview outcome",
+			Data:    err.Error(),
+		})
+	}
+
+	localizeInterview(middleware.DBConn, &interview)
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Interview outcome recorded",
+		Data:    interview,
+	})
+}
+
+// GetInterviewDetails returns the interview for an application together with
+// its history and reschedule requests. Both parties can read it.
+func GetInterviewDetails(c *fiber.Ctx) error {
+	interview, retCode, err := findApplicationInterview(c.Params("application_id"))
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	if !callerIsAdopter(c, interview.AdopterID) && !callerIsShelter(c, interview.ShelterID) {
+		return c.JSON(response.ResponseModel{
+			RetCode: "403",
+			Message: "You are not a party to this interview",
+			Data:    nil,
+		})
+	}
+
+	history := []models.InterviewHistory{}
+	if err := middleware.DBConn.Where("interview_id = ?", interview.InterviewID).
+		Order("created_at").
+		Find(&history).Error; err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching interview history",
+			Data:    err.Error(),
+		})
+	}
+
+	requests := []models.InterviewRescheduleRequest{}
+	if err := middleware.DBConn.Where("interview_id = ?", interview.InterviewID).
+		Order("created_at DESC").
+		Find(&requests).Error; err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching reschedule requests",
+			Data:    err.Error(),
+		})
+	}
+
+	// Outcome notes are for shelter staff only
+	if !callerIsShelter(c, interview.ShelterID) {
+		interview.OutcomeRecommendation = ""
+		interview.OutcomeNotes = ""
+		interview.OutcomeRecordedAt = nil
+		for i := range history {
+			if history[i].Action == "outcome_recorded" {
+				history[i].Reason = ""
+			}
+		}
+	}
+
+	localizeInterview(middleware.DBConn, &interview)
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data: fiber.Map{
+			"interview":           interview,
+			"history":             history,
+			"reschedule_requests": requests,
+		},
+	})
+}
+
+// RequestInterviewReschedule lets the adopter propose a new slot. Only one
+// request can be pending at a time.
+func RequestInterviewReschedule(c *fiber.Ctx) error {
+	var body struct {
+		ProposedAt   string `json:"proposed_at"`   // RFC 3339
+		ProposedDate string `json:"proposed_date"` // or a date and time in timezone
+		ProposedTime string `json:"proposed_time"`
+		Timezone     string `json:"timezone"` // defaults to the adopter's zone
+		Reason       string `json:"reason"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		})
+	}
+	if strings.TrimSpace(body.Reason) == "" {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "400",
+			Message: "A reason is required to request a reschedule",
+			Data:    nil,
+		})
+	}
+
+	interview, retCode, err := findApplicationInterview(c.Params("application_id"))
+	if err != nil {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	if !callerIsAdopter(c, interview.AdopterID) {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "403",
+			Message: "You can only reschedule your own interview",
+			Data:    nil,
+		})
+	}
+	if !interviewIsOpen(interview) {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "400",
+			Message: "Only scheduled interviews can be rescheduled",
+			Data:    nil,
+		})
+	}
+
+	zone := body.Timezone
+	if zone == "" {
+		zone = adopterTimezone(middleware.DBConn, interview.AdopterID, shelterTimezone(middleware.DBConn, interview.ShelterID))
+	}
+	proposedAt, err := parseInterviewInstant(body.ProposedAt, body.ProposedDate, body.ProposedTime, zone)
+	if err == nil {
+		_, err = loadTimezone(zone)
+	}
+	if err != nil {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "400",
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	if retCode, err := validateInterviewInstant(middleware.DBConn, interview.ShelterID, proposedAt); err != nil {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	var pending int64
+	if err := middleware.DBConn.Model(&models.InterviewRescheduleRequest{}).
+		Where("interview_id = ? AND status = ?", interview.InterviewID, "pending").
+		Count(&pending).Error; err != nil {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "500",
+			Message: "Database error while checking reschedule requests",
+			Data:    err.Error(),
+		})
+	}
+	if pending > 0 {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "409",
+			Message: "A reschedule request is already waiting for the shelter",
+			Data:    nil,
+		})
+	}
+
+	request := models.InterviewRescheduleRequest{
+		InterviewID:   interview.InterviewID,
+		ApplicationID: interview.ApplicationID,
+		ProposedAt:    proposedAt.UTC(),
+		Timezone:      zone,
+		Reason:        body.Reason,
+		Status:        "pending",
+		CreatedAt:     time.Now(),
+	}
+
+	tx := middleware.DBConn.Begin()
+	if err := tx.Create(&request).Error; err != nil {
+		tx.Rollback()
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "500",
+			Message: "Failed to save reschedule request",
+			Data:    err.Error(),
+		})
+	}
+	proposed := request.ProposedAt
+	if err := tx.Create(&models.InterviewHistory{
+		InterviewID:   interview.InterviewID,
+		ApplicationID: interview.ApplicationID,
+		Action:        "reschedule_requested",
+		ActorRole:     "adopter",
+		OldAt:         &interview.InterviewAt,
+		NewAt:         &proposed,
+		Reason:        body.Reason,
+		CreatedAt:     time.Now(),
+	}).Error; err != nil {
+		tx.Rollback()
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "500",
+			Message: "Failed to record interview history",
+			Data:    err.Error(),
+		})
+	}
+	if err := middleware.PublishEvent(tx, RescheduleRequestedEvent{
+		RequestID:     request.RequestID,
+		ApplicationID: interview.ApplicationID,
+		ShelterID:     interview.ShelterID,
+		AdopterID:     interview.AdopterID,
+		ProposedAt:    request.ProposedAt,
+		Timezone:      zone,
+		Reason:        body.Reason,
+	}); err != nil {
+		tx.Rollback()
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "500",
+			Message: "Failed to notify shelter",
+			Data:    err.Error(),
+		})
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "500",
+			Message: "Failed to save reschedule request",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.AdopterResponseModel{
+		RetCode: "200",
+		Message: "Reschedule request sent to the shelter",
+		Data:    request,
+	})
+}
+
+// RespondToRescheduleRequest accepts or declines an adopter's reschedule
+// request. Accepting moves the interview to the proposed slot.
+func RespondToRescheduleRequest(c *fiber.Ctx) error {
+	var body struct {
+		Action string `json:"action"` // accept or decline
+		Note   string `json:"note"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		})
+	}
+	if body.Action != "accept" && body.Action != "decline" {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "action must be accept or decline",
+			Data:    nil,
+		})
+	}
+
+	var request models.InterviewRescheduleRequest
+	if err := middleware.DBConn.Where("request_id = ?", c.Params("request_id")).First(&request).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "404",
+				Message: "Reschedule request not found",
+				Data:    nil,
+			})
+		}
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching reschedule request",
+			Data:    err.Error(),
+		})
+	}
+	if request.Status != "pending" {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Reschedule request has already been answered",
+			Data:    nil,
+		})
+	}
+
+	var interview models.ScheduleInterview
+	if err := middleware.DBConn.Where("interview_id = ?", request.InterviewID).First(&interview).Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching interview",
+			Data:    err.Error(),
+		})
+	}
+	if !callerIsShelter(c, interview.ShelterID) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "You can only answer requests for your own interviews",
+			Data:    nil,
+		})
+	}
+
+	now := time.Now()
+	request.ResponseNote = body.Note
+	request.RespondedAt = &now
+
+	tx := middleware.DBConn.Begin()
+	if body.Action == "accept" {
+		if !interviewIsOpen(interview) {
+			tx.Rollback()
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "400",
+				Message: "Interview is no longer scheduled",
+				Data:    nil,
+			})
+		}
+		request.Status = "accepted"
+		// The request may have gone stale while it waited
+		if retCode, err := validateInterviewInstant(tx, interview.ShelterID, request.ProposedAt); err != nil {
+			tx.Rollback()
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: retCode,
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		if err := moveInterview(tx, &interview, request.ProposedAt, interview.Timezone, "reschedule_accepted", "shelter", request.Reason); err != nil {
+			tx.Rollback()
+			if errors.Is(err, ErrSlotFull) {
+				return c.JSON(response.ShelterResponseModel{
+					RetCode: "409",
+					Message: err.Error(),
+					Data:    nil,
+				})
+			}
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "500",
+				Message: "Failed to reschedule interview",
+				Data:    err.Error(),
+			})
+		}
+	} else {
+		request.Status = "declined"
+		if err := recordInterviewHistory(tx, interview, "reschedule_declined", "shelter", nil, body.Note); err != nil {
+			tx.Rollback()
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "500",
+				Message: "Failed to record interview history",
+				Data:    err.Error(),
+			})
+		}
+	}
+	if err := tx.Save(&request).Error; err != nil {
+		tx.Rollback()
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to update reschedule request",
+			Data:    err.Error(),
+		})
+	}
+	if request.Status == "accepted" {
+		if err := announceInterviewChange(tx, interview, "rescheduled"); err != nil {
+			tx.Rollback()
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "500",
+				Message: "Failed to queue interview notifications",
+				Data:    err.Error(),
+			})
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to update reschedule request",
+			Data:    err.Error(),
+		})
+	}
+
+	localizeInterview(middleware.DBConn, &interview)
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Reschedule request " + request.Status,
+		Data: fiber.Map{
+			"request":   request,
+			"interview": interview,
+		},
+	})
+}
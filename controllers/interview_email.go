@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+
+	"pethub_api/middleware"
+	"pethub_api/models"
+
+	"gorm.io/gorm"
+)
+
+// sendEmailWithAttachment sends a plain-text email with one attachment
+// through the same Gmail account SendEmail uses.
+func sendEmailWithAttachment(toEmail, subject, body, filename, contentType string, attachment []byte) error {
+	from := os.Getenv("EMAIL_ADDRESS")
+	password := os.Getenv("EMAIL_PASSWORD")
+	auth := smtp.PlainAuth("", from, password, "smtp.gmail.com")
+
+	var msg bytes.Buffer
+	writer := multipart.NewWriter(&msg)
+	fmt.Fprintf(&msg, "From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=%s\r\n\r\n",
+		from, toEmail, subject, writer.Boundary())
+
+	text, _ := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"text/plain; charset=utf-8"},
+	})
+	text.Write([]byte(body))
+
+	part, _ := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType + `; name="` + filename + `"`},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {`attachment; filename="` + filename + `"`},
+	})
+	encoded := base64.StdEncoding.EncodeToString(attachment)
+	for len(encoded) > 76 {
+		part.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	part.Write([]byte(encoded))
+	writer.Close()
+
+	err := smtp.SendMail("smtp.gmail.com:587", auth, from, []string{toEmail}, msg.Bytes())
+	if err != nil {
+		log.Println("Send email error:", err)
+	}
+	return err
+}
+
+// emailInterviewChange tells both parties about a scheduled, moved or
+// cancelled interview, each in their own timezone, with an .ics invite that
+// updates the event in their calendar. The emails are queued on the outbox
+// in db, so pass the transaction that changes the interview.
+func emailInterviewChange(db *gorm.DB, interview models.ScheduleInterview, change string) error {
+	var application models.AdoptionSubmission
+	if err := db.Preload("Adopter").Preload("Pet").Preload("Shelter").
+		Where("application_id = ?", interview.ApplicationID).
+		First(&application).Error; err != nil {
+		return err
+	}
+
+	events, err := interviewEvents(db, []models.ScheduleInterview{interview})
+	if err != nil {
+		return err
+	}
+	method := "REQUEST"
+	if change == "cancelled" {
+		method = "CANCEL"
+	}
+	invite := middleware.BuildICS("", method, events)
+	localizeInterview(db, &interview)
+
+	subject := fmt.Sprintf("Adoption interview %s: %s", change, application.Pet.PetName)
+	recipients := []struct {
+		groupKey    string
+		email, name string
+		when        models.InterviewLocalTime
+	}{
+		{middleware.RealtimeChannel(RecipientAdopter, application.AdopterID), application.Adopter.Email,
+			strings.TrimSpace(application.Adopter.FirstName + " " + application.Adopter.LastName), interview.AdopterLocal},
+		{middleware.RealtimeChannel(RecipientShelter, application.ShelterID), application.Shelter.ShelterEmail,
+			application.Shelter.ShelterName, interview.ShelterLocal},
+	}
+
+	for _, recipient := range recipients {
+		if recipient.email == "" {
+			continue
+		}
+		body := fmt.Sprintf("Hello %s,\n\nThe adoption interview for %s with %s has been %s.\n\nWhen: %s (%s)\nWhere: %s\n",
+			recipient.name, application.Pet.PetName, application.Shelter.ShelterName, change,
+			recipient.when.Display, recipient.when.Timezone, application.Shelter.ShelterAddress)
+		if change == "cancelled" && interview.CancelReason != "" {
+			body += "Reason: " + interview.CancelReason + "\n"
+		}
+		body += "\nThe attached invite updates the event in your calendar.\n"
+		if err := queueEmail(db, recipient.groupKey, outboxEmail{
+			To:             recipient.email,
+			Subject:        subject,
+			Body:           body,
+			AttachmentName: "interview.ics",
+			AttachmentType: "text/calendar; method=" + method,
+			Attachment:     invite,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
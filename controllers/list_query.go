@@ -0,0 +1,299 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+// listFilter maps a query parameter onto a column. Exact filters take a
+// comma-separated list of values; contains filters match case-insensitively
+// anywhere in the column.
+type listFilter struct {
+	Column   string
+	Contains bool
+}
+
+// listSpec declares how a list endpoint can be paged, sorted and filtered.
+// Only the sort keys and filters listed here are accepted from the query
+// string.
+type listSpec struct {
+	IDColumn    string                // unique column, the final tie-breaker
+	Sorts       map[string]string     // ?sort= key -> column expression
+	DefaultSort string                // e.g. "-priority_status,-created_at"
+	Filters     map[string]listFilter // query parameter -> filter
+	Preloads    []string
+}
+
+// pageInfo is the pagination part of a list response. Page is set in page
+// mode, NextCursor in cursor mode while there are more results.
+type pageInfo struct {
+	Total      int64  `json:"total"`
+	Limit      int    `json:"limit"`
+	Page       int    `json:"page,omitempty"`
+	Sort       string `json:"sort"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type listSortKey struct {
+	Column string
+	Desc   bool
+}
+
+// cursorValue is one sort value carried in a cursor, with its kind so it
+// goes back to the database with the same type.
+type cursorValue struct {
+	Kind  string `json:"k"`
+	Value string `json:"v"`
+}
+
+// paginateList runs a list query the way every list endpoint does:
+//
+//   - filters from spec.Filters, on top of whatever the caller applied
+//   - ?sort=key or ?sort=-key for descending, comma-separated, from spec.Sorts
+//   - ?limit= (default 20, at most 100), then either ?page= (default), or
+//     ?cursor= for keyset paging: start with an empty cursor and pass back
+//     next_cursor for the following page
+//
+// query carries the caller's own conditions and joins, but no ordering or
+// preloads; those come from spec. Empty results are an empty list, not an
+// error.
+func paginateList[T any](c *fiber.Ctx, query *gorm.DB, spec listSpec) ([]T, pageInfo, string, error) {
+	items := []T{}
+	query = query.Model(new(T))
+	info := pageInfo{Limit: listLimit(c)}
+
+	query = applyListFilters(c, query, spec)
+
+	info.Sort = c.Query("sort", spec.DefaultSort)
+	sorts, err := parseListSort(info.Sort, spec)
+	if err != nil {
+		return items, info, "400", err
+	}
+
+	if err := query.Session(&gorm.Session{}).Distinct(spec.IDColumn).Count(&info.Total).Error; err != nil {
+		return items, info, "500", errors.New("Database error while counting results")
+	}
+
+	// First find the IDs on this page, with the sort values for the cursor
+	selects := []string{spec.IDColumn + " AS list_id"}
+	orders := make([]string, 0, len(sorts))
+	for i, key := range sorts {
+		selects = append(selects, fmt.Sprintf("%s AS list_sort_%d", key.Column, i))
+		direction := "ASC"
+		if key.Desc {
+			direction = "DESC"
+		}
+		orders = append(orders, key.Column+" "+direction)
+	}
+	page := query.Session(&gorm.Session{}).Select(strings.Join(selects, ", ")).
+		Order(strings.Join(orders, ", ")).Limit(info.Limit + 1)
+
+	cursorMode := c.Context().QueryArgs().Has("cursor")
+	if cursorMode {
+		if raw := c.Query("cursor"); raw != "" {
+			values, err := decodeListCursor(raw, len(sorts))
+			if err != nil {
+				return items, info, "400", err
+			}
+			condition, args := keysetCondition(sorts, values)
+			page = page.Where(condition, args...)
+		}
+	} else {
+		info.Page = listPageNumber(c)
+		page = page.Offset((info.Page - 1) * info.Limit)
+	}
+
+	var rows []map[string]interface{}
+	if err := page.Find(&rows).Error; err != nil {
+		return items, info, "500", errors.New("Database error while listing results")
+	}
+	if len(rows) > info.Limit {
+		info.HasMore = true
+		rows = rows[:info.Limit]
+	}
+	if len(rows) == 0 {
+		return items, info, "200", nil
+	}
+	if cursorMode && info.HasMore {
+		last := rows[len(rows)-1]
+		values := make([]interface{}, len(sorts))
+		for i := range sorts {
+			values[i] = last[fmt.Sprintf("list_sort_%d", i)]
+		}
+		info.NextCursor = encodeListCursor(values)
+	}
+
+	// Then load those rows in full, in the same order
+	ids := make([]string, len(rows))
+	for i, row := range rows {
+		ids[i] = fmt.Sprint(row["list_id"])
+	}
+	load := query.Session(&gorm.Session{NewDB: true})
+	for _, preload := range spec.Preloads {
+		load = load.Preload(preload)
+	}
+	if err := load.Where(spec.IDColumn+" IN ?", ids).
+		Order(gorm.Expr("array_position(string_to_array(?, ','), "+spec.IDColumn+"::text)", strings.Join(ids, ","))).
+		Find(&items).Error; err != nil {
+		return items, info, "500", errors.New("Database error while loading results")
+	}
+	return items, info, "200", nil
+}
+
+// listLimit reads ?limit=, falling back to the default when it is missing or
+// out of range.
+func listLimit(c *fiber.Ctx) int {
+	limit := c.QueryInt("limit", defaultListLimit)
+	if limit < 1 || limit > maxListLimit {
+		return defaultListLimit
+	}
+	return limit
+}
+
+// listPageNumber reads ?page=, counting from 1.
+func listPageNumber(c *fiber.Ctx) int {
+	if page := c.QueryInt("page", 1); page > 1 {
+		return page
+	}
+	return 1
+}
+
+// applyListFilters narrows query by the spec's filters present in the query
+// string.
+func applyListFilters(c *fiber.Ctx, query *gorm.DB, spec listSpec) *gorm.DB {
+	for param, filter := range spec.Filters {
+		raw := strings.TrimSpace(c.Query(param))
+		if raw == "" {
+			continue
+		}
+		if filter.Contains {
+			query = query.Where(filter.Column+" ILIKE ?", "%"+raw+"%")
+			continue
+		}
+		var values []string
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		query = query.Where(filter.Column+" IN ?", values)
+	}
+	return query
+}
+
+// parseListSort reads a sort such as "-created_at,pet_name" against the
+// whitelisted keys, and ends it with the ID so the order is total.
+func parseListSort(raw string, spec listSpec) ([]listSortKey, error) {
+	var sorts []listSortKey
+	for _, key := range strings.Split(raw, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		desc := strings.HasPrefix(key, "-")
+		column, ok := spec.Sorts[strings.TrimPrefix(key, "-")]
+		if !ok {
+			allowed := make([]string, 0, len(spec.Sorts))
+			for name := range spec.Sorts {
+				allowed = append(allowed, name)
+			}
+			sort.Strings(allowed)
+			return nil, fmt.Errorf("Cannot sort by '%s'; sort by %s", strings.TrimPrefix(key, "-"), strings.Join(allowed, ", "))
+		}
+		sorts = append(sorts, listSortKey{Column: column, Desc: desc})
+	}
+	return append(sorts, listSortKey{Column: spec.IDColumn}), nil
+}
+
+// keysetCondition selects the rows after values in the order of sorts:
+// (a > ?) OR (a = ? AND b < ?) OR ..., with each comparison following its
+// key's direction.
+func keysetCondition(sorts []listSortKey, values []interface{}) (string, []interface{}) {
+	var clauses []string
+	var args []interface{}
+	for i, key := range sorts {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, sorts[j].Column+" = ?")
+			args = append(args, values[j])
+		}
+		operator := ">"
+		if key.Desc {
+			operator = "<"
+		}
+		parts = append(parts, key.Column+" "+operator+" ?")
+		args = append(args, values[i])
+		clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+	}
+	return "(" + strings.Join(clauses, " OR ") + ")", args
+}
+
+func encodeListCursor(values []interface{}) string {
+	encoded := make([]cursorValue, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case time.Time:
+			encoded[i] = cursorValue{"time", v.Format(time.RFC3339Nano)}
+		case int64, int32, int16, int, uint, uint32, uint64:
+			encoded[i] = cursorValue{"int", fmt.Sprint(v)}
+		case float64, float32:
+			encoded[i] = cursorValue{"float", fmt.Sprint(v)}
+		case bool:
+			encoded[i] = cursorValue{"bool", strconv.FormatBool(v)}
+		default:
+			encoded[i] = cursorValue{"string", fmt.Sprint(v)}
+		}
+	}
+	data, _ := json.Marshal(encoded)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeListCursor reads a cursor holding one value per sort key.
+func decodeListCursor(raw string, size int) ([]interface{}, error) {
+	invalid := errors.New("Invalid cursor")
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, invalid
+	}
+	var encoded []cursorValue
+	if err := json.Unmarshal(data, &encoded); err != nil || len(encoded) != size {
+		return nil, invalid
+	}
+
+	values := make([]interface{}, len(encoded))
+	for i, value := range encoded {
+		switch value.Kind {
+		case "time":
+			values[i], err = time.Parse(time.RFC3339Nano, value.Value)
+		case "int":
+			values[i], err = strconv.ParseInt(value.Value, 10, 64)
+		case "float":
+			values[i], err = strconv.ParseFloat(value.Value, 64)
+		case "bool":
+			values[i], err = strconv.ParseBool(value.Value)
+		case "string":
+			values[i] = value.Value
+		default:
+			err = invalid
+		}
+		if err != nil {
+			return nil, invalid
+		}
+	}
+	return values, nil
+}
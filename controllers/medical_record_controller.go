@@ -0,0 +1,589 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"pethub_api/middleware"
+	"pethub_api/models"
+	"pethub_api/models/response"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const maxMedicalDocumentSize = 10 * 1024 * 1024
+
+// dueSoonDays is how far ahead a dose counts as coming up.
+const dueSoonDays = 14
+
+var medicalRecordTypes = map[string]bool{
+	"vaccination": true,
+	"deworming":   true,
+	"treatment":   true,
+	"vet_visit":   true,
+}
+
+var medicalDocumentTypes = map[string]bool{
+	"application/pdf": true,
+	"image/png":       true,
+	"image/jpeg":      true,
+}
+
+// VaccinationStatus summarises whether a pet's vaccinations are current.
+// Status is up_to_date, overdue or no_records.
+type VaccinationStatus struct {
+	Status  string                    `json:"status"`
+	Overdue []models.PetMedicalRecord `json:"overdue"`
+	DueSoon []models.PetMedicalRecord `json:"due_soon"`
+}
+
+// vaccinationStatus works out the status from a pet's records. Only the
+// latest dose of each vaccine counts; an earlier dose's due date is
+// settled by the booster that followed it.
+func vaccinationStatus(records []models.PetMedicalRecord, now time.Time) VaccinationStatus {
+	latest := map[string]models.PetMedicalRecord{}
+	var order []string
+	for _, record := range records {
+		if record.RecordType != "vaccination" {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(record.Name))
+		current, seen := latest[key]
+		if !seen {
+			order = append(order, key)
+		}
+		if !seen || record.AdministeredOn.After(current.AdministeredOn) {
+			latest[key] = record
+		}
+	}
+
+	status := VaccinationStatus{
+		Status:  "up_to_date",
+		Overdue: []models.PetMedicalRecord{},
+		DueSoon: []models.PetMedicalRecord{},
+	}
+	if len(latest) == 0 {
+		status.Status = "no_records"
+		return status
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	for _, key := range order {
+		record := latest[key]
+		if record.NextDueOn == nil {
+			continue
+		}
+		switch {
+		case record.NextDueOn.Before(today):
+			status.Overdue = append(status.Overdue, record)
+		case record.NextDueOn.Before(today.AddDate(0, 0, dueSoonDays+1)):
+			status.DueSoon = append(status.DueSoon, record)
+		}
+	}
+	if len(status.Overdue) > 0 {
+		status.Status = "overdue"
+	}
+	return status
+}
+
+// loadMedicalHistory returns a pet's records, newest first, with their
+// documents.
+func loadMedicalHistory(db *gorm.DB, petID uint) ([]models.PetMedicalRecord, error) {
+	records := []models.PetMedicalRecord{}
+	err := db.Preload("Documents").Where("pet_id = ?", petID).
+		Order("administered_on DESC, record_id DESC").
+		Find(&records).Error
+	return records, err
+}
+
+// findShelterPet loads :pet_id and checks the caller is its shelter.
+func findShelterPet(c *fiber.Ctx) (models.PetInfo, string, error) {
+	var pet models.PetInfo
+	if err := middleware.DBConn.Where("pet_id = ?", c.Params("pet_id")).First(&pet).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return pet, "404", errors.New("Pet not found")
+		}
+		return pet, "500", errors.New("Database error while fetching pet")
+	}
+	if !callerIsShelter(c, pet.ShelterID) {
+		return pet, "403", errors.New("Only the pet's shelter can manage its medical records")
+	}
+	return pet, "200", nil
+}
+
+// findShelterMedicalRecord loads :record_id and checks the caller is the
+// shelter of its pet.
+func findShelterMedicalRecord(c *fiber.Ctx) (models.PetMedicalRecord, string, error) {
+	var record models.PetMedicalRecord
+	if err := middleware.DBConn.Where("record_id = ?", c.Params("record_id")).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return record, "404", errors.New("Medical record not found")
+		}
+		return record, "500", errors.New("Database error while fetching medical record")
+	}
+	var pet models.PetInfo
+	if err := middleware.DBConn.Select("pet_id, shelter_id").Where("pet_id = ?", record.PetID).First(&pet).Error; err != nil {
+		return record, "500", errors.New("Database error while fetching pet")
+	}
+	if !callerIsShelter(c, pet.ShelterID) {
+		return record, "403", errors.New("Only the pet's shelter can manage its medical records")
+	}
+	return record, "200", nil
+}
+
+// medicalRecordInput is the body for creating or editing a record. Dates
+// are YYYY-MM-DD; an empty next_due_on clears it.
+type medicalRecordInput struct {
+	RecordType     *string `json:"record_type" form:"record_type"`
+	Name           *string `json:"name" form:"name"`
+	AdministeredOn *string `json:"administered_on" form:"administered_on"`
+	BatchNumber    *string `json:"batch_number" form:"batch_number"`
+	NextDueOn      *string `json:"next_due_on" form:"next_due_on"`
+	Veterinarian   *string `json:"veterinarian" form:"veterinarian"`
+	Clinic         *string `json:"clinic" form:"clinic"`
+	Notes          *string `json:"notes" form:"notes"`
+}
+
+// apply copies the fields that were sent onto record and validates the
+// result.
+func (input medicalRecordInput) apply(record *models.PetMedicalRecord) error {
+	if input.RecordType != nil {
+		record.RecordType = strings.ToLower(strings.TrimSpace(*input.RecordType))
+	}
+	if input.Name != nil {
+		record.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.AdministeredOn != nil {
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(*input.AdministeredOn))
+		if err != nil {
+			return errors.New("administered_on must be YYYY-MM-DD")
+		}
+		record.AdministeredOn = date
+	}
+	if input.NextDueOn != nil {
+		record.NextDueOn = nil
+		if value := strings.TrimSpace(*input.NextDueOn); value != "" {
+			date, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				return errors.New("next_due_on must be YYYY-MM-DD")
+			}
+			record.NextDueOn = &date
+		}
+	}
+	if input.BatchNumber != nil {
+		record.BatchNumber = strings.TrimSpace(*input.BatchNumber)
+	}
+	if input.Veterinarian != nil {
+		record.Veterinarian = strings.TrimSpace(*input.Veterinarian)
+	}
+	if input.Clinic != nil {
+		record.Clinic = strings.TrimSpace(*input.Clinic)
+	}
+	if input.Notes != nil {
+		record.Notes = strings.TrimSpace(*input.Notes)
+	}
+
+	if !medicalRecordTypes[record.RecordType] {
+		return errors.New("record_type must be vaccination, deworming, treatment or vet_visit")
+	}
+	if record.Name == "" {
+		return errors.New("name is required")
+	}
+	if record.AdministeredOn.IsZero() {
+		return errors.New("administered_on is required")
+	}
+	if record.AdministeredOn.After(time.Now()) {
+		return errors.New("administered_on cannot be in the future")
+	}
+	if record.NextDueOn != nil && !record.NextDueOn.After(record.AdministeredOn) {
+		return errors.New("next_due_on must be after administered_on")
+	}
+	return nil
+}
+
+// readMedicalDocument reads one uploaded PDF, PNG or JPEG.
+func readMedicalDocument(file *multipart.FileHeader) (*models.MedicalDocument, string, error) {
+	if file.Size > maxMedicalDocumentSize {
+		return nil, "400", fmt.Errorf("%s is larger than 10MB", file.Filename)
+	}
+	f, err := file.Open()
+	if err != nil {
+		return nil, "500", fmt.Errorf("Failed to open %s", file.Filename)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, "500", fmt.Errorf("Failed to read %s", file.Filename)
+	}
+	contentType := http.DetectContentType(data)
+	if !medicalDocumentTypes[contentType] {
+		return nil, "400", fmt.Errorf("%s must be a PDF, PNG or JPEG", file.Filename)
+	}
+	return &models.MedicalDocument{
+		FileName:    file.Filename,
+		ContentType: contentType,
+		Data:        base64.StdEncoding.EncodeToString(data),
+		CreatedAt:   time.Now(),
+	}, "200", nil
+}
+
+// GetPetMedicalRecords returns a pet's medical history and vaccination
+// status for its shelter.
+func GetPetMedicalRecords(c *fiber.Ctx) error {
+	pet, retCode, err := findShelterPet(c)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	records, err := loadMedicalHistory(middleware.DBConn, pet.PetID)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to fetch medical records",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Medical records fetched successfully",
+		Data: fiber.Map{
+			"pet_id":       pet.PetID,
+			"vaccinations": vaccinationStatus(records, time.Now()),
+			"records":      records,
+		},
+	})
+}
+
+// AddPetMedicalRecord adds a record, with any files posted under
+// "documents".
+func AddPetMedicalRecord(c *fiber.Ctx) error {
+	pet, retCode, err := findShelterPet(c)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	var input medicalRecordInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		})
+	}
+	record := models.PetMedicalRecord{PetID: pet.PetID, CreatedAt: time.Now()}
+	if err := input.apply(&record); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	if form, err := c.MultipartForm(); err == nil {
+		for _, file := range form.File["documents"] {
+			document, retCode, err := readMedicalDocument(file)
+			if err != nil {
+				return c.JSON(response.ShelterResponseModel{
+					RetCode: retCode,
+					Message: err.Error(),
+					Data:    nil,
+				})
+			}
+			record.Documents = append(record.Documents, *document)
+		}
+	}
+
+	if err := middleware.DBConn.Create(&record).Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to save medical record",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Medical record added",
+		Data:    record,
+	})
+}
+
+// UpdatePetMedicalRecord edits the fields sent for a record.
+func UpdatePetMedicalRecord(c *fiber.Ctx) error {
+	record, retCode, err := findShelterMedicalRecord(c)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	var input medicalRecordInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		})
+	}
+	if err := input.apply(&record); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	if err := middleware.DBConn.Omit("Documents").Save(&record).Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to update medical record",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Medical record updated",
+		Data:    record,
+	})
+}
+
+// DeletePetMedicalRecord removes a record entered by mistake, along with
+// its documents.
+func DeletePetMedicalRecord(c *fiber.Ctx) error {
+	record, retCode, err := findShelterMedicalRecord(c)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	err = middleware.DBConn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("record_id = ?", record.RecordID).Delete(&models.MedicalDocument{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("record_id = ?", record.RecordID).Delete(&models.MedicalOverdueAlert{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&record).Error
+	})
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to delete medical record",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Medical record deleted",
+		Data:    nil,
+	})
+}
+
+// UploadMedicalDocument attaches a file posted as "document" to a record.
+func UploadMedicalDocument(c *fiber.Ctx) error {
+	record, retCode, err := findShelterMedicalRecord(c)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	file, err := c.FormFile("document")
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "document is required",
+			Data:    nil,
+		})
+	}
+	document, retCode, err := readMedicalDocument(file)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	document.RecordID = record.RecordID
+
+	if err := middleware.DBConn.Create(document).Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to save document",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Document uploaded",
+		Data:    document,
+	})
+}
+
+// GetAdoptedPetMedicalRecords hands the medical record over to the adopter
+// once their adoption of the pet is completed, up to its return if the pet
+// came back.
+func GetAdoptedPetMedicalRecords(c *fiber.Ctx) error {
+	adopterID, err := strconv.ParseUint(c.Params("adopter_id"), 10, 32)
+	if err != nil || !callerIsAdopter(c, uint(adopterID)) {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "403",
+			Message: "You can only view your own pets' records",
+			Data:    nil,
+		})
+	}
+
+	var adoption models.AdoptionSubmission
+	if err := middleware.DBConn.
+		Where("adopter_id = ? AND pet_id = ? AND status = ?", adopterID, c.Params("pet_id"), "completed").
+		Order("updated_at DESC").First(&adoption).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(response.AdopterResponseModel{
+				RetCode: "403",
+				Message: "Medical records are handed over once the adoption is completed",
+				Data:    nil,
+			})
+		}
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "500",
+			Message: "Database error while checking adoption",
+			Data:    err.Error(),
+		})
+	}
+
+	// Once the pet is returned its records belong to the shelter and the next
+	// adopter; the former adopter keeps what was recorded before the return day
+	history := middleware.DBConn
+	var petReturn models.PetReturn
+	err = middleware.DBConn.Where("application_id = ?", adoption.ApplicationID).
+		Order("returned_on").First(&petReturn).Error
+	if err == nil {
+		history = history.Where("administered_on < ?", petReturn.ReturnedOn)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "500",
+			Message: "Database error while checking adoption",
+			Data:    err.Error(),
+		})
+	}
+
+	var pet models.PetInfo
+	if err := middleware.DBConn.Where("pet_id = ?", adoption.PetID).First(&pet).Error; err != nil {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching pet",
+			Data:    err.Error(),
+		})
+	}
+	records, err := loadMedicalHistory(history, pet.PetID)
+	if err != nil {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "500",
+			Message: "Failed to fetch medical records",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.AdopterResponseModel{
+		RetCode: "200",
+		Message: "Medical records fetched successfully",
+		Data: fiber.Map{
+			"pet_id":           pet.PetID,
+			"pet_name":         pet.PetName,
+			"microchip_number": pet.MicrochipNumber,
+			"spayed_neutered":  pet.SpayedNeutered,
+			"vaccinations":     vaccinationStatus(records, time.Now()),
+			"records":          records,
+		},
+	})
+}
+
+// alertOverdueMedicalRecords tells each shelter about vaccinations and
+// dewormings that are past due on pets still in its care, once per due
+// date. Only the latest dose of each counts.
+func alertOverdueMedicalRecords(db *gorm.DB) error {
+	var overdue []struct {
+		models.PetMedicalRecord
+		ShelterID uint
+		PetName   string
+	}
+	if err := db.Raw(`SELECT r.*, p.shelter_id, p.pet_name
+		FROM pet_medical_records r
+		JOIN petinfo p ON p.pet_id = r.pet_id
+		WHERE r.record_type IN ('vaccination', 'deworming')
+		AND r.next_due_on < CURRENT_DATE
+		AND p.status NOT IN ('adopted', 'archived')
+		AND NOT EXISTS (
+			SELECT 1 FROM pet_medical_records n
+			WHERE n.pet_id = r.pet_id AND n.record_type = r.record_type
+			AND LOWER(n.name) = LOWER(r.name) AND n.administered_on > r.administered_on)
+		AND NOT EXISTS (
+			SELECT 1 FROM medical_overdue_alerts a
+			WHERE a.record_id = r.record_id AND a.next_due_on = r.next_due_on)
+		ORDER BY p.shelter_id, r.next_due_on`).Scan(&overdue).Error; err != nil {
+		return err
+	}
+
+	byShelter := map[uint][]OverdueMedicalItem{}
+	var shelters []uint
+	for _, row := range overdue {
+		if _, seen := byShelter[row.ShelterID]; !seen {
+			shelters = append(shelters, row.ShelterID)
+		}
+		byShelter[row.ShelterID] = append(byShelter[row.ShelterID], OverdueMedicalItem{
+			RecordID:  row.RecordID,
+			PetID:     row.PetID,
+			PetName:   row.PetName,
+			Name:      row.Name,
+			NextDueOn: *row.NextDueOn,
+		})
+	}
+
+	for _, shelterID := range shelters {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for _, item := range byShelter[shelterID] {
+				if err := tx.Create(&models.MedicalOverdueAlert{
+					RecordID:  item.RecordID,
+					NextDueOn: item.NextDueOn,
+					SentAt:    time.Now(),
+				}).Error; err != nil {
+					return err
+				}
+			}
+			return middleware.PublishEvent(tx, MedicalRecordsOverdueEvent{
+				ShelterID: shelterID,
+				Items:     byShelter[shelterID],
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
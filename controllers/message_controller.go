@@ -0,0 +1,358 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"pethub_api/middleware"
+	"pethub_api/models"
+	"pethub_api/models/response"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	maxMessageLength          = 2000
+	maxMessageAttachments     = 4
+	maxMessageAttachmentBytes = 5 * 1024 * 1024
+)
+
+// loadThreadApplication finds the application behind a message thread and
+// checks the caller is its adopter or shelter, depending on role.
+func loadThreadApplication(c *fiber.Ctx, role string) (models.AdoptionSubmission, string, error) {
+	var application models.AdoptionSubmission
+	applicationID, err := strconv.ParseUint(c.Params("application_id"), 10, 32)
+	if err != nil {
+		return application, "400", errors.New("Invalid application ID")
+	}
+	if err := middleware.DBConn.Where("application_id = ?", applicationID).First(&application).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return application, "404", errors.New("Application not found")
+		}
+		return application, "500", errors.New("Database error while fetching application")
+	}
+
+	ownerID := application.AdopterID
+	if role == RecipientShelter {
+		ownerID = application.ShelterID
+	}
+	if !callerIs(c, role, ownerID) {
+		return application, "403", errors.New("You are not a party to this application")
+	}
+	return application, "200", nil
+}
+
+// GetAdopterMessages returns the adopter's thread with the shelter.
+func GetAdopterMessages(c *fiber.Ctx) error {
+	return getApplicationMessages(c, RecipientAdopter)
+}
+
+// GetShelterMessages returns the shelter's thread with the adopter.
+func GetShelterMessages(c *fiber.Ctx) error {
+	return getApplicationMessages(c, RecipientShelter)
+}
+
+func getApplicationMessages(c *fiber.Ctx, role string) error {
+	application, retCode, err := loadThreadApplication(c, role)
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	messages := []models.ApplicationMessage{}
+	if err := middleware.DBConn.Preload("Attachments").
+		Where("application_id = ?", application.ApplicationID).
+		Order("created_at, message_id").
+		Find(&messages).Error; err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching messages",
+			Data:    err.Error(),
+		})
+	}
+
+	unread := 0
+	for _, message := range messages {
+		if message.SenderType != role && message.ReadAt == nil {
+			unread++
+		}
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Messages fetched successfully",
+		Data: fiber.Map{
+			"application_id": application.ApplicationID,
+			"read_only":      isTerminalApplicationStatus(application.Status),
+			"unread_count":   unread,
+			"messages":       messages,
+		},
+	})
+}
+
+// SendAdopterMessage posts a message from the adopter to the shelter.
+func SendAdopterMessage(c *fiber.Ctx) error {
+	return sendApplicationMessage(c, RecipientAdopter)
+}
+
+// SendShelterMessage posts a message from the shelter to the adopter.
+func SendShelterMessage(c *fiber.Ctx) error {
+	return sendApplicationMessage(c, RecipientShelter)
+}
+
+// sendApplicationMessage posts the "body" form value and any PNG or JPEG
+// files under "attachments". The thread is read-only once the application
+// is completed, rejected or expired.
+func sendApplicationMessage(c *fiber.Ctx, role string) error {
+	application, retCode, err := loadThreadApplication(c, role)
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	if isTerminalApplicationStatus(application.Status) {
+		return c.JSON(response.ResponseModel{
+			RetCode: "409",
+			Message: fmt.Sprintf("This conversation is read-only because the application is %s", application.Status),
+			Data:    nil,
+		})
+	}
+
+	body := strings.TrimSpace(c.FormValue("body"))
+	if len([]rune(body)) > maxMessageLength {
+		return c.JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: fmt.Sprintf("Messages can be at most %d characters", maxMessageLength),
+			Data:    nil,
+		})
+	}
+
+	attachments, retCode, err := readMessageAttachments(c)
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	if body == "" && len(attachments) == 0 {
+		return c.JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "A message needs text or an attachment",
+			Data:    nil,
+		})
+	}
+
+	senderID := application.AdopterID
+	if role == RecipientShelter {
+		senderID = application.ShelterID
+	}
+	message := models.ApplicationMessage{
+		ApplicationID: application.ApplicationID,
+		SenderType:    role,
+		SenderID:      senderID,
+		Body:          body,
+		CreatedAt:     time.Now(),
+		Attachments:   attachments,
+	}
+
+	tx := middleware.DBConn.Begin()
+	if err := tx.Create(&message).Error; err != nil {
+		tx.Rollback()
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to send message",
+			Data:    err.Error(),
+		})
+	}
+	if err := middleware.PublishEvent(tx, MessagePostedEvent{Application: application, Message: message}); err != nil {
+		tx.Rollback()
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to notify the other party",
+			Data:    err.Error(),
+		})
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to send message",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Message sent",
+		Data:    message,
+	})
+}
+
+// readMessageAttachments reads the image files posted under "attachments".
+func readMessageAttachments(c *fiber.Ctx) ([]models.MessageAttachment, string, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		// Not a multipart request, so there are no files
+		return nil, "200", nil
+	}
+	files := form.File["attachments"]
+	if len(files) > maxMessageAttachments {
+		return nil, "400", fmt.Errorf("A message can have at most %d attachments", maxMessageAttachments)
+	}
+
+	attachments := make([]models.MessageAttachment, 0, len(files))
+	for _, file := range files {
+		if file.Size > maxMessageAttachmentBytes {
+			return nil, "400", fmt.Errorf("%s is larger than 5MB", file.Filename)
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, "500", fmt.Errorf("Failed to open %s", file.Filename)
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, "500", fmt.Errorf("Failed to read %s", file.Filename)
+		}
+		_, format, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, "400", fmt.Errorf("%s must be a PNG or JPEG", file.Filename)
+		}
+		attachments = append(attachments, models.MessageAttachment{
+			FileName:    file.Filename,
+			ContentType: "image/" + format,
+			Image:       base64.StdEncoding.EncodeToString(data),
+			CreatedAt:   time.Now(),
+		})
+	}
+	return attachments, "200", nil
+}
+
+// MarkAdopterMessagesRead marks the shelter's messages in the thread read.
+func MarkAdopterMessagesRead(c *fiber.Ctx) error {
+	return markApplicationMessagesRead(c, RecipientAdopter)
+}
+
+// MarkShelterMessagesRead marks the adopter's messages in the thread read.
+func MarkShelterMessagesRead(c *fiber.Ctx) error {
+	return markApplicationMessagesRead(c, RecipientShelter)
+}
+
+// markApplicationMessagesRead records that the caller has read everything
+// the other party sent, and tells the sender so they can show the receipt.
+// This still works on read-only threads.
+func markApplicationMessagesRead(c *fiber.Ctx, role string) error {
+	application, retCode, err := loadThreadApplication(c, role)
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	readAt := time.Now()
+	result := middleware.DBConn.Model(&models.ApplicationMessage{}).
+		Where("application_id = ? AND sender_type <> ? AND read_at IS NULL", application.ApplicationID, role).
+		Update("read_at", readAt)
+	if result.Error != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to mark messages as read",
+			Data:    result.Error.Error(),
+		})
+	}
+
+	if result.RowsAffected > 0 {
+		senderType, senderID := RecipientShelter, application.ShelterID
+		if role == RecipientShelter {
+			senderType, senderID = RecipientAdopter, application.AdopterID
+		}
+		middleware.Realtime.Publish(middleware.RealtimeChannel(senderType, senderID), "messages_read", fiber.Map{
+			"application_id": application.ApplicationID,
+			"read_at":        readAt,
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Messages marked as read",
+		Data:    fiber.Map{"marked_read": result.RowsAffected},
+	})
+}
+
+// CountUnreadAdopterMessages returns how many shelter messages the adopter
+// has not read, in total and per application.
+func CountUnreadAdopterMessages(c *fiber.Ctx) error {
+	return countUnreadMessages(c, RecipientAdopter, "adopter_id", c.Params("adopter_id"))
+}
+
+// CountUnreadShelterMessages returns how many adopter messages the shelter
+// has not read, in total and per application.
+func CountUnreadShelterMessages(c *fiber.Ctx) error {
+	return countUnreadMessages(c, RecipientShelter, "shelter_id", c.Params("shelter_id"))
+}
+
+func countUnreadMessages(c *fiber.Ctx, role, ownerColumn, rawOwnerID string) error {
+	ownerID, err := strconv.ParseUint(rawOwnerID, 10, 32)
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid ID",
+			Data:    nil,
+		})
+	}
+	if !callerIs(c, role, uint(ownerID)) {
+		return c.JSON(response.ResponseModel{
+			RetCode: "403",
+			Message: "You can only view your own messages",
+			Data:    nil,
+		})
+	}
+
+	byApplication := []struct {
+		ApplicationID uint  `json:"application_id"`
+		Unread        int64 `json:"unread"`
+	}{}
+	if err := middleware.DBConn.Model(&models.ApplicationMessage{}).
+		Select("application_messages.application_id, COUNT(*) AS unread").
+		Joins("JOIN adoption_submissions ON adoption_submissions.application_id = application_messages.application_id").
+		Where("adoption_submissions."+ownerColumn+" = ?", ownerID).
+		Where("application_messages.sender_type <> ? AND application_messages.read_at IS NULL", role).
+		Group("application_messages.application_id").
+		Order("application_messages.application_id").
+		Scan(&byApplication).Error; err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to count unread messages",
+			Data:    err.Error(),
+		})
+	}
+
+	var total int64
+	for _, row := range byApplication {
+		total += row.Unread
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Unread messages counted",
+		Data: fiber.Map{
+			"unread_count":   total,
+			"by_application": byApplication,
+		},
+	})
+}
@@ -0,0 +1,268 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"pethub_api/middleware"
+	"pethub_api/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Recipient types a notification can be addressed to
+const (
+	RecipientAdopter = "adopter"
+	RecipientShelter = "shelter"
+)
+
+// unreadNotificationCount counts a recipient's unread notifications.
+func unreadNotificationCount(db *gorm.DB, recipientType string, recipientID uint) (int64, error) {
+	var count int64
+	err := db.Model(&models.Notification{}).
+		Where("recipient_type = ? AND recipient_id = ? AND is_read = false AND hidden = false", recipientType, recipientID).
+		Count(&count).Error
+	return count, err
+}
+
+// pushUnreadCount sends the recipient's unread count to their open streams
+// once db's transaction commits. It is only a hint for badges, so failures
+// are logged.
+func pushUnreadCount(db *gorm.DB, recipientType string, recipientID uint) {
+	middleware.AfterCommit(db, func() {
+		count, err := unreadNotificationCount(middleware.DBConn, recipientType, recipientID)
+		if err != nil {
+			log.Println("Realtime: failed to count unread notifications:", err)
+			return
+		}
+		middleware.Realtime.Publish(middleware.RealtimeChannel(recipientType, recipientID), "unread_count",
+			fiber.Map{"unread_count": count})
+	})
+}
+
+// listNotifications is a paginated read (?page, ?limit) of one recipient's
+// notifications, newest first.
+func listNotifications(c *fiber.Ctx, recipientType, recipientIDStr string) error {
+	recipientID, err := strconv.Atoi(recipientIDStr)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Invalid %s ID", recipientType),
+		})
+	}
+	if !callerIs(c, recipientType, uint(recipientID)) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You can only access your own notifications",
+		})
+	}
+
+	page := c.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := middleware.DBConn.Model(&models.Notification{}).
+		Where("recipient_type = ? AND recipient_id = ? AND hidden = false", recipientType, recipientID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Println("Error counting notifications:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch notifications",
+		})
+	}
+
+	notifications := []models.Notification{}
+	if err := query.Order("created_at DESC, id DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&notifications).Error; err != nil {
+		log.Println("Error fetching notifications:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch notifications",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"notifications": notifications,
+		"page":          page,
+		"limit":         limit,
+		"total":         total,
+	})
+}
+
+func countUnreadNotifications(c *fiber.Ctx, recipientType, recipientIDStr string) error {
+	recipientID, err := strconv.Atoi(recipientIDStr)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Invalid %s ID", recipientType),
+		})
+	}
+	if !callerIs(c, recipientType, uint(recipientID)) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You can only access your own notifications",
+		})
+	}
+
+	count, err := unreadNotificationCount(middleware.DBConn, recipientType, uint(recipientID))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to count unread notifications",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"unread_count": count,
+	})
+}
+
+// findNotification loads a notification from the caller's own inbox, so
+// nobody reaches into another recipient's notifications.
+func findNotification(c *fiber.Ctx, recipientType, notificationID string) (models.Notification, error) {
+	var notif models.Notification
+	callerID, err := middleware.GetAdopterIDFromJWT(c)
+	if err != nil || middleware.GetRoleFromJWT(c) != recipientType {
+		return notif, gorm.ErrRecordNotFound
+	}
+	err = middleware.DBConn.
+		Where("id = ? AND recipient_type = ? AND recipient_id = ? AND hidden = false", notificationID, recipientType, callerID).
+		First(&notif).Error
+	return notif, err
+}
+
+func setNotificationReadStatus(c *fiber.Ctx, recipientType string) error {
+	var body struct {
+		IsRead bool `json:"is_read"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	notif, err := findNotification(c, recipientType, c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Notification not found",
+		})
+	}
+
+	notif.IsRead = body.IsRead
+	if err := middleware.DBConn.Save(&notif).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update notification",
+		})
+	}
+	pushUnreadCount(middleware.DBConn, notif.RecipientType, notif.RecipientID)
+
+	status := "read"
+	if !body.IsRead {
+		status = "unread"
+	}
+	return c.JSON(fiber.Map{
+		"message": fmt.Sprintf("Notification marked as %s", status),
+	})
+}
+
+func getNotificationByID(c *fiber.Ctx, recipientType string) error {
+	notif, err := findNotification(c, recipientType, c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Notification not found",
+		})
+	}
+
+	// Mark as read if not already
+	if !notif.IsRead {
+		notif.IsRead = true
+		if err := middleware.DBConn.Save(&notif).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to mark notification as read",
+			})
+		}
+		pushUnreadCount(middleware.DBConn, notif.RecipientType, notif.RecipientID)
+	}
+
+	return c.JSON(fiber.Map{
+		"notification": notif,
+	})
+}
+
+func deleteAllNotifications(c *fiber.Ctx, recipientType, recipientIDStr string) error {
+	recipientID, err := strconv.Atoi(recipientIDStr)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Invalid %s ID", recipientType),
+		})
+	}
+	if !callerIs(c, recipientType, uint(recipientID)) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You can only access your own notifications",
+		})
+	}
+
+	// Hard delete everything in the inbox; hidden rows still feed queued
+	// email/SMS deliveries
+	if err := middleware.DBConn.
+		Where("recipient_type = ? AND recipient_id = ? AND hidden = false", recipientType, recipientID).
+		Delete(&models.Notification{}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete notifications",
+		})
+	}
+	pushUnreadCount(middleware.DBConn, recipientType, uint(recipientID))
+
+	return c.JSON(fiber.Map{
+		"message": "All notifications deleted successfully",
+	})
+}
+
+// GetAdoptionNotifications lists an adopter's notifications, newest first.
+// Notifications are written when the events happen, so this is a plain
+// paginated read (?page, ?limit).
+func GetAdoptionNotifications(c *fiber.Ctx) error {
+	return listNotifications(c, RecipientAdopter, c.Params("adopter_id"))
+}
+
+func CountUnreadNotifications(c *fiber.Ctx) error {
+	return countUnreadNotifications(c, RecipientAdopter, c.Params("adopter_id"))
+}
+
+func SetNotificationReadStatus(c *fiber.Ctx) error {
+	return setNotificationReadStatus(c, RecipientAdopter)
+}
+
+func GetNotificationByID(c *fiber.Ctx) error {
+	return getNotificationByID(c, RecipientAdopter)
+}
+
+func DeleteAllNotifications(c *fiber.Ctx) error {
+	return deleteAllNotifications(c, RecipientAdopter, c.Params("adopter_id"))
+}
+
+// GetShelterNotifications is the shelter inbox: new applications, reschedule
+// requests, reports and registration decisions.
+func GetShelterNotifications(c *fiber.Ctx) error {
+	return listNotifications(c, RecipientShelter, c.Params("shelter_id"))
+}
+
+func CountUnreadShelterNotifications(c *fiber.Ctx) error {
+	return countUnreadNotifications(c, RecipientShelter, c.Params("shelter_id"))
+}
+
+func SetShelterNotificationReadStatus(c *fiber.Ctx) error {
+	return setNotificationReadStatus(c, RecipientShelter)
+}
+
+func GetShelterNotificationByID(c *fiber.Ctx) error {
+	return getNotificationByID(c, RecipientShelter)
+}
+
+func DeleteAllShelterNotifications(c *fiber.Ctx) error {
+	return deleteAllNotifications(c, RecipientShelter, c.Params("shelter_id"))
+}
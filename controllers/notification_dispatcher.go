@@ -0,0 +1,389 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"pethub_api/middleware"
+	"pethub_api/models"
+
+	"gorm.io/gorm"
+)
+
+// Outside channels a notification can go out on besides the in-app inbox
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+	ChannelPush  = "push"
+)
+
+// notificationTypes lists the notification types each kind of recipient can
+// set preferences for.
+var notificationTypes = map[string][]string{
+	RecipientAdopter: {"application", "interview", "approved", "completed", "rejected", "message"},
+	RecipientShelter: {"application", "interview", "registration", "report", "message", "medical"},
+}
+
+// smsByDefault are the adopter notification types that also go out by SMS
+// unless the adopter turns it off: interview times and decisions.
+var smsByDefault = map[string]bool{"interview": true, "approved": true, "rejected": true}
+
+// defaultNotificationPreference is used for types the recipient never set:
+// the inbox and live push, plus SMS for the adopter types above.
+func defaultNotificationPreference(recipientType string, recipientID uint, eventType string) models.NotificationPreference {
+	return models.NotificationPreference{
+		RecipientType: recipientType,
+		RecipientID:   recipientID,
+		EventType:     eventType,
+		InApp:         true,
+		SMS:           recipientType == RecipientAdopter && smsByDefault[eventType],
+		Push:          true,
+	}
+}
+
+// NotificationRecipient is the contact details a channel delivers to.
+type NotificationRecipient struct {
+	Type     string
+	ID       uint
+	Name     string
+	Email    string
+	Phone    string
+	Timezone string
+}
+
+// NotificationChannel delivers a message over one outside channel.
+type NotificationChannel interface {
+	Send(recipient NotificationRecipient, subject, body string) error
+}
+
+var notificationChannels = struct {
+	sync.RWMutex
+	byName map[string]NotificationChannel
+}{byName: map[string]NotificationChannel{
+	ChannelEmail: emailChannel{},
+	ChannelSMS:   smsChannel{},
+	ChannelPush:  pushChannel{},
+}}
+
+// RegisterNotificationChannel installs the driver for a channel, replacing
+// any driver already registered under that name.
+func RegisterNotificationChannel(name string, channel NotificationChannel) {
+	notificationChannels.Lock()
+	defer notificationChannels.Unlock()
+	notificationChannels.byName[name] = channel
+}
+
+func notificationChannel(name string) (NotificationChannel, bool) {
+	notificationChannels.RLock()
+	defer notificationChannels.RUnlock()
+	channel, ok := notificationChannels.byName[name]
+	return channel, ok
+}
+
+// emailChannel sends through the same Gmail account as SendEmail.
+type emailChannel struct{}
+
+func (emailChannel) Send(recipient NotificationRecipient, subject, body string) error {
+	if recipient.Email == "" {
+		return errors.New("recipient has no email address")
+	}
+	from := os.Getenv("EMAIL_ADDRESS")
+	password := os.Getenv("EMAIL_PASSWORD")
+	auth := smtp.PlainAuth("", from, password, "smtp.gmail.com")
+
+	msg := []byte("From: " + from + "\r\nTo: " + recipient.Email + "\r\nSubject: " + subject +
+		"\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n" + body)
+	return smtp.SendMail("smtp.gmail.com:587", auth, from, []string{recipient.Email}, msg)
+}
+
+// normalizeContactNumber stores contact numbers in E.164 so they can be
+// texted. An empty number stays empty.
+func normalizeContactNumber(raw string) (string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return "", nil
+	}
+	return middleware.NormalizePhone(raw)
+}
+
+// smsChannel texts the recipient's contact number through middleware.SMS.
+type smsChannel struct{}
+
+func (smsChannel) Send(recipient NotificationRecipient, subject, body string) error {
+	if recipient.Phone == "" {
+		return errors.New("recipient has no contact number")
+	}
+	to, err := middleware.NormalizePhone(recipient.Phone)
+	if err != nil {
+		return err
+	}
+	return middleware.SMS.SendSMS(to, "PetHub: "+subject+". "+body)
+}
+
+// pushChannel alerts the recipient's connected devices over their event
+// stream.
+type pushChannel struct{}
+
+func (pushChannel) Send(recipient NotificationRecipient, subject, body string) error {
+	return middleware.Realtime.Publish(middleware.RealtimeChannel(recipient.Type, recipient.ID), "push",
+		map[string]string{"title": subject, "body": body})
+}
+
+// loadNotificationRecipient looks up where a recipient's notifications go.
+func loadNotificationRecipient(db *gorm.DB, recipientType string, recipientID uint) (NotificationRecipient, error) {
+	recipient := NotificationRecipient{Type: recipientType, ID: recipientID}
+	switch recipientType {
+	case RecipientAdopter:
+		var info models.AdopterInfo
+		if err := db.Where("adopter_id = ?", recipientID).First(&info).Error; err != nil {
+			return recipient, err
+		}
+		recipient.Name = strings.TrimSpace(info.FirstName + " " + info.LastName)
+		recipient.Email = info.Email
+		recipient.Phone = info.ContactNumber
+		recipient.Timezone = adopterTimezone(db, recipientID, defaultTimezone())
+	case RecipientShelter:
+		var info models.ShelterInfo
+		if err := db.Where("shelter_id = ?", recipientID).First(&info).Error; err != nil {
+			return recipient, err
+		}
+		recipient.Name = info.ShelterName
+		recipient.Email = info.ShelterEmail
+		recipient.Phone = info.ShelterContact
+		recipient.Timezone = shelterTimezone(db, recipientID)
+	default:
+		return recipient, fmt.Errorf("unknown recipient type %q", recipientType)
+	}
+	return recipient, nil
+}
+
+// loadNotificationSettings returns the recipient's settings, or the zero
+// settings (no quiet hours, no digest) if they never saved any.
+func loadNotificationSettings(db *gorm.DB, recipientType string, recipientID uint) (models.NotificationSettings, error) {
+	settings := models.NotificationSettings{RecipientType: recipientType, RecipientID: recipientID, DigestHour: 8}
+	err := db.Where("recipient_type = ? AND recipient_id = ?", recipientType, recipientID).First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	return settings, err
+}
+
+// quietHoursEnd returns when the recipient's quiet hours are over, or now
+// if they are not in quiet hours.
+func quietHoursEnd(settings models.NotificationSettings, zone string, now time.Time) time.Time {
+	if settings.QuietHoursStart == "" || settings.QuietHoursEnd == "" {
+		return now
+	}
+	local := now.In(mustLocation(zone))
+	start := clockOn(local, settings.QuietHoursStart)
+	end := clockOn(local, settings.QuietHoursEnd)
+
+	if !start.After(end) {
+		if !local.Before(start) && local.Before(end) {
+			return end
+		}
+		return now
+	}
+	// Quiet hours wrap past midnight
+	if !local.Before(start) {
+		return end.AddDate(0, 0, 1)
+	}
+	if local.Before(end) {
+		return end
+	}
+	return now
+}
+
+// notify is the central notification dispatcher. It stores the notification
+// in the recipient's inbox (hidden if they turned in-app off for its type)
+// and queues it on the outbox for the outside channels they chose. Email goes into the
+// daily digest when that is on; other channels wait out quiet hours.
+func notify(db *gorm.DB, notification models.Notification) error {
+	pref := defaultNotificationPreference(notification.RecipientType, notification.RecipientID, notification.Type)
+	if err := db.Where("recipient_type = ? AND recipient_id = ? AND event_type = ?",
+		notification.RecipientType, notification.RecipientID, notification.Type).
+		First(&pref).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	now := time.Now()
+	if notification.CreatedAt.IsZero() {
+		notification.CreatedAt = now
+	}
+	notification.Hidden = !pref.InApp
+	if err := db.Create(&notification).Error; err != nil {
+		return err
+	}
+	if pref.InApp {
+		middleware.Realtime.PublishAfterCommit(db, middleware.RealtimeChannel(notification.RecipientType, notification.RecipientID),
+			"notification", notification)
+		pushUnreadCount(db, notification.RecipientType, notification.RecipientID)
+	}
+
+	var channels []string
+	if pref.Email {
+		channels = append(channels, ChannelEmail)
+	}
+	if pref.SMS {
+		channels = append(channels, ChannelSMS)
+	}
+	if pref.Push {
+		channels = append(channels, ChannelPush)
+	}
+	if len(channels) == 0 {
+		return nil
+	}
+
+	settings, err := loadNotificationSettings(db, notification.RecipientType, notification.RecipientID)
+	if err != nil {
+		return err
+	}
+	sendAfter := now
+	if settings.QuietHoursStart != "" {
+		recipient, err := loadNotificationRecipient(db, notification.RecipientType, notification.RecipientID)
+		if err != nil {
+			return err
+		}
+		sendAfter = quietHoursEnd(settings, recipient.Timezone, now)
+	}
+
+	for _, channel := range channels {
+		if err := middleware.EnqueueOutbox(db, middleware.OutboxEntry{
+			Topic:     "notification." + channel,
+			Payload:   notificationDelivery{NotificationID: notification.ID},
+			GroupKey:  middleware.RealtimeChannel(notification.RecipientType, notification.RecipientID),
+			SendAfter: sendAfter,
+			Held:      channel == ChannelEmail && settings.DailyDigest,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notificationDelivery is the payload of the notification.* outbox topics.
+type notificationDelivery struct {
+	NotificationID uint `json:"notification_id"`
+}
+
+// deliverNotification returns the outbox handler that sends notifications
+// over channel. A notification deleted before it went out is skipped.
+func deliverNotification(channelName string) middleware.OutboxHandler {
+	return func(db *gorm.DB, payload []byte) error {
+		var delivery notificationDelivery
+		if err := json.Unmarshal(payload, &delivery); err != nil {
+			return err
+		}
+		var notification models.Notification
+		if err := db.Where("id = ?", delivery.NotificationID).First(&notification).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		channel, ok := notificationChannel(channelName)
+		if !ok {
+			return fmt.Errorf("no driver configured for channel %q", channelName)
+		}
+		recipient, err := loadNotificationRecipient(db, notification.RecipientType, notification.RecipientID)
+		if err != nil {
+			return err
+		}
+		return channel.Send(recipient, notification.Title, notification.Message)
+	}
+}
+
+// sendDailyDigests emails each recipient with the digest on everything that
+// was held back for it, once a day at their chosen local hour.
+func sendDailyDigests(db *gorm.DB) error {
+	var all []models.NotificationSettings
+	if err := db.Where("daily_digest = ?", true).Find(&all).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, settings := range all {
+		recipient, err := loadNotificationRecipient(db, settings.RecipientType, settings.RecipientID)
+		if err != nil {
+			log.Printf("Daily digest: failed to load %s %d: %v", settings.RecipientType, settings.RecipientID, err)
+			continue
+		}
+		local := now.In(mustLocation(recipient.Timezone))
+		digestAt := time.Date(local.Year(), local.Month(), local.Day(), settings.DigestHour, 0, 0, 0, local.Location())
+		if local.Before(digestAt) {
+			continue
+		}
+
+		// Claim today's digest so only one instance sends it
+		claim := db.Model(&models.NotificationSettings{}).
+			Where("settings_id = ? AND (last_digest_at IS NULL OR last_digest_at < ?)", settings.SettingsID, digestAt).
+			Update("last_digest_at", now)
+		if claim.Error != nil {
+			return claim.Error
+		}
+		if claim.RowsAffected == 0 {
+			continue
+		}
+
+		var held []models.OutboxMessage
+		if err := db.Where("topic = ? AND status = ? AND group_key = ?", TopicNotificationEmail, "held",
+			middleware.RealtimeChannel(settings.RecipientType, settings.RecipientID)).
+			Order("created_at").
+			Find(&held).Error; err != nil {
+			return err
+		}
+		if len(held) == 0 {
+			continue
+		}
+
+		ids := make([]uint, 0, len(held))
+		notificationIDs := make([]uint, 0, len(held))
+		for _, msg := range held {
+			var delivery notificationDelivery
+			if err := json.Unmarshal([]byte(msg.Payload), &delivery); err != nil {
+				log.Printf("Daily digest: bad payload in outbox message %d: %v", msg.MessageID, err)
+				continue
+			}
+			ids = append(ids, msg.MessageID)
+			notificationIDs = append(notificationIDs, delivery.NotificationID)
+		}
+		var notifications []models.Notification
+		if err := db.Where("id IN ?", notificationIDs).Order("created_at").Find(&notifications).Error; err != nil {
+			return err
+		}
+
+		// The digest replaces the held emails; queue it and retire them together
+		tx := db.Begin()
+		if len(notifications) > 0 && recipient.Email != "" {
+			var body strings.Builder
+			fmt.Fprintf(&body, "Hello %s,\n\nHere is what happened on PetHub since your last digest:\n\n", recipient.Name)
+			for _, notification := range notifications {
+				fmt.Fprintf(&body, "- %s: %s\n", notification.Title, notification.Message)
+			}
+			if err := queueEmail(tx, middleware.RealtimeChannel(settings.RecipientType, settings.RecipientID), outboxEmail{
+				To:      recipient.Email,
+				Subject: "Your PetHub daily digest",
+				Body:    body.String(),
+			}); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		if err := tx.Model(&models.OutboxMessage{}).
+			Where("message_id IN ? AND status = ?", ids, "held").
+			Updates(map[string]interface{}{"status": "sent", "sent_at": now}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit().Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,549 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"pethub_api/middleware"
+	"pethub_api/models"
+
+	"gorm.io/gorm"
+)
+
+// Names of the events published by the API
+const (
+	EventApplicationSubmitted     = "application.submitted"
+	EventApplicationStatusChanged = "application.status_changed"
+	EventRescheduleRequested      = "interview.reschedule_requested"
+	EventShelterRegistration      = "shelter.registration_decided"
+	EventReportFiled              = "shelter.report_filed"
+	EventInterviewChanged         = "interview.changed"
+	EventInterviewReminder        = "interview.reminder"
+	EventApplicationsOverdue      = "application.overdue"
+	EventMessagePosted            = "application.message_posted"
+	EventMedicalOverdue           = "pet.medical_overdue"
+)
+
+// ApplicationEvent is published whenever an application is created or moves
+// to a new status.
+type ApplicationEvent struct {
+	Name          string `json:"name"`
+	ApplicationID uint   `json:"application_id"`
+	AdopterID     uint   `json:"adopter_id"`
+	ShelterID     uint   `json:"shelter_id"`
+	PetID         uint   `json:"pet_id"`
+	OldStatus     string `json:"old_status"`
+	NewStatus     string `json:"new_status"`
+	Reason        string `json:"reason"`
+}
+
+func (e ApplicationEvent) EventName() string {
+	return e.Name
+}
+
+// publishApplicationStatus announces that application moved from oldStatus
+// to its current status. Nothing is published when the status is unchanged.
+func publishApplicationStatus(db *gorm.DB, application models.AdoptionSubmission, oldStatus string) error {
+	if oldStatus == application.Status {
+		return nil
+	}
+	name := EventApplicationStatusChanged
+	if oldStatus == "" {
+		name = EventApplicationSubmitted
+	}
+	return middleware.PublishEvent(db, ApplicationEvent{
+		Name:          name,
+		ApplicationID: application.ApplicationID,
+		AdopterID:     application.AdopterID,
+		ShelterID:     application.ShelterID,
+		PetID:         application.PetID,
+		OldStatus:     oldStatus,
+		NewStatus:     application.Status,
+		Reason:        application.ReasonForRejection,
+	})
+}
+
+// RegisterEventHandlers wires the event handlers of this package. It is
+// called once at start-up.
+func RegisterEventHandlers() {
+	middleware.SubscribeEvent(EventApplicationSubmitted, notifyApplicationStatus)
+	middleware.SubscribeEvent(EventApplicationStatusChanged, notifyApplicationStatus)
+	middleware.SubscribeEvent(EventApplicationSubmitted, pushApplicationStatus)
+	middleware.SubscribeEvent(EventApplicationStatusChanged, pushApplicationStatus)
+	middleware.SubscribeEvent(EventApplicationSubmitted, notifyShelterOfApplication)
+	middleware.SubscribeEvent(EventRescheduleRequested, notifyShelterOfRescheduleRequest)
+	middleware.SubscribeEvent(EventShelterRegistration, notifyShelterOfRegistration)
+	middleware.SubscribeEvent(EventReportFiled, notifyShelterOfReport)
+	middleware.SubscribeEvent(EventInterviewChanged, notifyAdopterOfInterview)
+	middleware.SubscribeEvent(EventInterviewReminder, notifyInterviewReminder)
+	middleware.SubscribeEvent(EventApplicationsOverdue, notifyShelterOfOverdueApplications)
+	middleware.SubscribeEvent(EventMessagePosted, notifyOfMessage)
+	middleware.SubscribeEvent(EventMedicalOverdue, notifyShelterOfOverdueMedical)
+	middleware.SubscribeEvent(EventApplicationStatusChanged, handOverMedicalRecords)
+}
+
+// applicationNotificationText gives the title, type and category shown to
+// the adopter for an application status.
+func applicationNotificationText(status string) (title, notifType, category string) {
+	switch status {
+	case "pending":
+		return "Application Pending", "application", "inprogress"
+	case "in queue":
+		return "Application In Queue", "application", "inprogress"
+	case "interview":
+		// The interview time itself arrives as an "interview" notification
+		return "Interview Stage", "application", "inprogress"
+	case "approved":
+		return "Application Approved", "approved", "approved"
+	case "completed":
+		return "Adoption Completed", "completed", "completed"
+	case "application_reject":
+		return "Application Rejected", "rejected", "rejected"
+	case "interview_reject":
+		return "Interview Rejected", "rejected", "rejected"
+	case "approved_reject":
+		return "Approval Rejected", "rejected", "rejected"
+	case "rejected":
+		return "Application Rejected", "rejected", "rejected"
+	case "expired":
+		return "Application Expired", "rejected", "rejected"
+	}
+	return "Application Update", "application", "inprogress"
+}
+
+// notifyApplicationStatus stores the adopter's notification for an
+// application event.
+func notifyApplicationStatus(db *gorm.DB, event middleware.DomainEvent) error {
+	e := event.(ApplicationEvent)
+
+	var pet models.PetInfo
+	if err := db.Select("pet_id, pet_name").Where("pet_id = ?", e.PetID).First(&pet).Error; err != nil {
+		return err
+	}
+
+	title, notifType, category := applicationNotificationText(e.NewStatus)
+	message := fmt.Sprintf("Your adoption application for %s is now '%s'.", pet.PetName, strings.Title(e.NewStatus))
+	if e.Name == EventApplicationSubmitted {
+		message = fmt.Sprintf("Your adoption application for %s has been submitted.", pet.PetName)
+	}
+	if category == "rejected" && e.Reason != "" {
+		message += " Reason: " + e.Reason
+	}
+
+	return notify(db, models.Notification{
+		RecipientType: RecipientAdopter,
+		RecipientID:   e.AdopterID,
+		PetID:         e.PetID,
+		ApplicationID: e.ApplicationID,
+		Title:         title,
+		Message:       message,
+		Type:          notifType,
+		Status:        e.NewStatus,
+		Category:      category,
+	})
+}
+
+// notifyShelterOfApplication tells the shelter a new application arrived.
+func notifyShelterOfApplication(db *gorm.DB, event middleware.DomainEvent) error {
+	e := event.(ApplicationEvent)
+
+	var application models.AdoptionSubmission
+	if err := db.Preload("Adopter").Preload("Pet").
+		Where("application_id = ?", e.ApplicationID).
+		First(&application).Error; err != nil {
+		return err
+	}
+
+	return notify(db, models.Notification{
+		RecipientType: RecipientShelter,
+		RecipientID:   e.ShelterID,
+		PetID:         e.PetID,
+		ApplicationID: e.ApplicationID,
+		Title:         "New Application",
+		Message: fmt.Sprintf("%s %s applied to adopt %s.",
+			application.Adopter.FirstName, application.Adopter.LastName, application.Pet.PetName),
+		Type:     "application",
+		Status:   e.NewStatus,
+		Category: "inprogress",
+	})
+}
+
+// RescheduleRequestedEvent is published when an adopter asks to move their
+// interview.
+type RescheduleRequestedEvent struct {
+	RequestID     uint
+	ApplicationID uint
+	ShelterID     uint
+	AdopterID     uint
+	ProposedAt    time.Time
+	Timezone      string
+	Reason        string
+}
+
+func (RescheduleRequestedEvent) EventName() string {
+	return EventRescheduleRequested
+}
+
+func notifyShelterOfRescheduleRequest(db *gorm.DB, event middleware.DomainEvent) error {
+	e := event.(RescheduleRequestedEvent)
+
+	var application models.AdoptionSubmission
+	if err := db.Preload("Adopter").Preload("Pet").
+		Where("application_id = ?", e.ApplicationID).
+		First(&application).Error; err != nil {
+		return err
+	}
+	when := localTime(e.ProposedAt, shelterTimezone(db, e.ShelterID))
+
+	return notify(db, models.Notification{
+		RecipientType: RecipientShelter,
+		RecipientID:   e.ShelterID,
+		PetID:         application.PetID,
+		ApplicationID: e.ApplicationID,
+		Title:         "Reschedule Requested",
+		Message: fmt.Sprintf("%s %s asked to move the interview for %s to %s. Reason: %s",
+			application.Adopter.FirstName, application.Adopter.LastName, application.Pet.PetName, when.Display, e.Reason),
+		Type:     "interview",
+		Status:   "reschedule_requested",
+		Category: "inprogress",
+	})
+}
+
+// ShelterRegistrationEvent is published when an admin approves or rejects a
+// shelter's registration.
+type ShelterRegistrationEvent struct {
+	ShelterID uint
+	RegStatus string
+}
+
+func (ShelterRegistrationEvent) EventName() string {
+	return EventShelterRegistration
+}
+
+func notifyShelterOfRegistration(db *gorm.DB, event middleware.DomainEvent) error {
+	e := event.(ShelterRegistrationEvent)
+
+	title, category := "Registration Approved", "approved"
+	message := "Your shelter registration has been approved. You can now list pets."
+	if e.RegStatus != "approved" {
+		title, category = "Registration Rejected", "rejected"
+		message = "Your shelter registration has been rejected."
+	}
+	return notify(db, models.Notification{
+		RecipientType: RecipientShelter,
+		RecipientID:   e.ShelterID,
+		Title:         title,
+		Message:       message,
+		Type:          "registration",
+		Status:        e.RegStatus,
+		Category:      category,
+	})
+}
+
+// ReportFiledEvent is published when an adopter reports a shelter.
+type ReportFiledEvent struct {
+	ReportID  uint
+	ShelterID uint
+	Reason    string
+}
+
+func (ReportFiledEvent) EventName() string {
+	return EventReportFiled
+}
+
+func notifyShelterOfReport(db *gorm.DB, event middleware.DomainEvent) error {
+	e := event.(ReportFiledEvent)
+	return notify(db, models.Notification{
+		RecipientType: RecipientShelter,
+		RecipientID:   e.ShelterID,
+		Title:         "Report Filed",
+		Message:       fmt.Sprintf("A report was filed against your shelter: %s. An admin will review it.", e.Reason),
+		Type:          "report",
+		Status:        "reported",
+		Category:      "report",
+	})
+}
+
+// InterviewChangedEvent is published after an interview time is set, moved
+// or called off. Change is scheduled, rescheduled or cancelled.
+type InterviewChangedEvent struct {
+	Interview models.ScheduleInterview
+	Change    string
+}
+
+func (InterviewChangedEvent) EventName() string {
+	return EventInterviewChanged
+}
+
+// notifyAdopterOfInterview tells the adopter when their interview is, in
+// their own timezone.
+func notifyAdopterOfInterview(db *gorm.DB, event middleware.DomainEvent) error {
+	e := event.(InterviewChangedEvent)
+	interview := e.Interview
+
+	var application models.AdoptionSubmission
+	if err := db.Preload("Pet").Preload("Shelter").
+		Where("application_id = ?", interview.ApplicationID).
+		First(&application).Error; err != nil {
+		return err
+	}
+	zone := adopterTimezone(db, interview.AdopterID, shelterTimezone(db, interview.ShelterID))
+	when := localTime(interview.InterviewAt, zone)
+
+	title := "Interview Scheduled"
+	message := fmt.Sprintf("Your interview for %s with %s is on %s (%s).",
+		application.Pet.PetName, application.Shelter.ShelterName, when.Display, when.Timezone)
+	switch e.Change {
+	case "rescheduled":
+		title = "Interview Rescheduled"
+		message = fmt.Sprintf("Your interview for %s with %s has moved to %s (%s).",
+			application.Pet.PetName, application.Shelter.ShelterName, when.Display, when.Timezone)
+	case "cancelled":
+		title = "Interview Cancelled"
+		message = fmt.Sprintf("Your interview for %s on %s was cancelled.", application.Pet.PetName, when.Display)
+		if interview.CancelReason != "" {
+			message += " Reason: " + interview.CancelReason
+		}
+	}
+
+	return notify(db, models.Notification{
+		RecipientType: RecipientAdopter,
+		RecipientID:   interview.AdopterID,
+		PetID:         application.PetID,
+		ApplicationID: interview.ApplicationID,
+		Title:         title,
+		Message:       message,
+		Type:          "interview",
+		Status:        e.Change,
+		Category:      "inprogress",
+	})
+}
+
+// announceInterviewChange notifies the adopter on their chosen channels and
+// emails both parties the calendar invite. Call it inside the transaction
+// that changes the interview so the messages are only sent if it commits.
+func announceInterviewChange(db *gorm.DB, interview models.ScheduleInterview, change string) error {
+	if err := middleware.PublishEvent(db, InterviewChangedEvent{Interview: interview, Change: change}); err != nil {
+		return err
+	}
+	return emailInterviewChange(db, interview, change)
+}
+
+// InterviewReminderEvent is published when an interview is Kind (24h or 1h)
+// away.
+type InterviewReminderEvent struct {
+	Interview models.ScheduleInterview
+	Kind      string
+}
+
+func (InterviewReminderEvent) EventName() string {
+	return EventInterviewReminder
+}
+
+// notifyInterviewReminder reminds the adopter and the shelter, each in their
+// own timezone.
+func notifyInterviewReminder(db *gorm.DB, event middleware.DomainEvent) error {
+	e := event.(InterviewReminderEvent)
+	interview := e.Interview
+
+	var application models.AdoptionSubmission
+	if err := db.Preload("Adopter").Preload("Pet").Preload("Shelter").
+		Where("application_id = ?", interview.ApplicationID).
+		First(&application).Error; err != nil {
+		return err
+	}
+	shelterZone := shelterTimezone(db, interview.ShelterID)
+	adopterWhen := localTime(interview.InterviewAt, adopterTimezone(db, interview.AdopterID, shelterZone))
+	shelterWhen := localTime(interview.InterviewAt, shelterZone)
+
+	lead := "tomorrow"
+	if e.Kind == "1h" {
+		lead = "in an hour"
+	}
+	if err := notify(db, models.Notification{
+		RecipientType: RecipientAdopter,
+		RecipientID:   interview.AdopterID,
+		PetID:         application.PetID,
+		ApplicationID: interview.ApplicationID,
+		Title:         "Interview Reminder",
+		Message: fmt.Sprintf("Your interview for %s with %s is %s, %s (%s).",
+			application.Pet.PetName, application.Shelter.ShelterName, lead, adopterWhen.Display, adopterWhen.Timezone),
+		Type:     "interview",
+		Status:   "reminder_" + e.Kind,
+		Category: "inprogress",
+	}); err != nil {
+		return err
+	}
+	return notify(db, models.Notification{
+		RecipientType: RecipientShelter,
+		RecipientID:   interview.ShelterID,
+		PetID:         application.PetID,
+		ApplicationID: interview.ApplicationID,
+		Title:         "Interview Reminder",
+		Message: fmt.Sprintf("Interview with %s %s about %s is %s, %s (%s).",
+			application.Adopter.FirstName, application.Adopter.LastName, application.Pet.PetName,
+			lead, shelterWhen.Display, shelterWhen.Timezone),
+		Type:     "interview",
+		Status:   "reminder_" + e.Kind,
+		Category: "inprogress",
+	})
+}
+
+// ApplicationsOverdueEvent is published when applications have waited
+// longer than the shelter's SLA.
+type ApplicationsOverdueEvent struct {
+	ShelterID      uint
+	ApplicationIDs []uint
+	SLAHours       int
+}
+
+func (ApplicationsOverdueEvent) EventName() string {
+	return EventApplicationsOverdue
+}
+
+func notifyShelterOfOverdueApplications(db *gorm.DB, event middleware.DomainEvent) error {
+	e := event.(ApplicationsOverdueEvent)
+
+	message := fmt.Sprintf("An application has been waiting for review for more than %d hours.", e.SLAHours)
+	if len(e.ApplicationIDs) > 1 {
+		message = fmt.Sprintf("%d applications have been waiting for review for more than %d hours.", len(e.ApplicationIDs), e.SLAHours)
+	}
+	notification := models.Notification{
+		RecipientType: RecipientShelter,
+		RecipientID:   e.ShelterID,
+		Title:         "Applications Waiting",
+		Message:       message,
+		Type:          "application",
+		Status:        "overdue",
+		Category:      "inprogress",
+	}
+	if len(e.ApplicationIDs) == 1 {
+		notification.ApplicationID = e.ApplicationIDs[0]
+	}
+	return notify(db, notification)
+}
+
+// MessagePostedEvent is published when either party posts in an
+// application's message thread.
+type MessagePostedEvent struct {
+	Application models.AdoptionSubmission
+	Message     models.ApplicationMessage
+}
+
+func (MessagePostedEvent) EventName() string {
+	return EventMessagePosted
+}
+
+// notifyOfMessage tells the other party about a new message and pushes it
+// to their open thread.
+func notifyOfMessage(db *gorm.DB, event middleware.DomainEvent) error {
+	e := event.(MessagePostedEvent)
+	recipientType, recipientID := RecipientShelter, e.Application.ShelterID
+	if e.Message.SenderType == RecipientShelter {
+		recipientType, recipientID = RecipientAdopter, e.Application.AdopterID
+	}
+
+	preview := e.Message.Body
+	if len([]rune(preview)) > 100 {
+		preview = string([]rune(preview)[:100]) + "..."
+	}
+	if preview == "" {
+		preview = "Sent a photo"
+	}
+
+	// Images stay out of the push; the client fetches the thread for them
+	pushed := e.Message
+	pushed.Attachments = make([]models.MessageAttachment, len(e.Message.Attachments))
+	for i, attachment := range e.Message.Attachments {
+		attachment.Image = ""
+		pushed.Attachments[i] = attachment
+	}
+	middleware.Realtime.PublishAfterCommit(db, middleware.RealtimeChannel(recipientType, recipientID), "message", pushed)
+	return notify(db, models.Notification{
+		RecipientType: recipientType,
+		RecipientID:   recipientID,
+		PetID:         e.Application.PetID,
+		ApplicationID: e.Application.ApplicationID,
+		Title:         "New Message",
+		Message:       preview,
+		Type:          "message",
+		Status:        "unread",
+		Category:      "message",
+	})
+}
+
+// OverdueMedicalItem is one overdue vaccination or deworming.
+type OverdueMedicalItem struct {
+	RecordID  uint      `json:"record_id"`
+	PetID     uint      `json:"pet_id"`
+	PetName   string    `json:"pet_name"`
+	Name      string    `json:"name"`
+	NextDueOn time.Time `json:"next_due_on"`
+}
+
+// MedicalRecordsOverdueEvent is published when pets in a shelter's care
+// are past due for a vaccination or deworming.
+type MedicalRecordsOverdueEvent struct {
+	ShelterID uint
+	Items     []OverdueMedicalItem
+}
+
+func (MedicalRecordsOverdueEvent) EventName() string {
+	return EventMedicalOverdue
+}
+
+func notifyShelterOfOverdueMedical(db *gorm.DB, event middleware.DomainEvent) error {
+	e := event.(MedicalRecordsOverdueEvent)
+
+	lines := make([]string, 0, len(e.Items))
+	for _, item := range e.Items {
+		lines = append(lines, fmt.Sprintf("%s: %s (due %s)", item.PetName, item.Name, item.NextDueOn.Format("Jan 2, 2006")))
+	}
+	notification := models.Notification{
+		RecipientType: RecipientShelter,
+		RecipientID:   e.ShelterID,
+		Title:         "Medical Care Overdue",
+		Message:       "Overdue: " + strings.Join(lines, "; "),
+		Type:          "medical",
+		Status:        "overdue",
+		Category:      "medical",
+	}
+	if len(e.Items) == 1 {
+		notification.PetID = e.Items[0].PetID
+	}
+	return notify(db, notification)
+}
+
+// handOverMedicalRecords tells the adopter the pet's medical record is now
+// theirs to view once the adoption is completed.
+func handOverMedicalRecords(db *gorm.DB, event middleware.DomainEvent) error {
+	e := event.(ApplicationEvent)
+	if e.NewStatus != "completed" {
+		return nil
+	}
+
+	var records int64
+	if err := db.Model(&models.PetMedicalRecord{}).Where("pet_id = ?", e.PetID).Count(&records).Error; err != nil {
+		return err
+	}
+	if records == 0 {
+		return nil
+	}
+	var pet models.PetInfo
+	if err := db.Select("pet_id, pet_name").Where("pet_id = ?", e.PetID).First(&pet).Error; err != nil {
+		return err
+	}
+
+	return notify(db, models.Notification{
+		RecipientType: RecipientAdopter,
+		RecipientID:   e.AdopterID,
+		PetID:         e.PetID,
+		ApplicationID: e.ApplicationID,
+		Title:         "Medical Records Handed Over",
+		Message:       fmt.Sprintf("%s's full medical record, with %d entries, is now available in your account.", pet.PetName, records),
+		Type:          "completed",
+		Status:        "completed",
+		Category:      "completed",
+	})
+}
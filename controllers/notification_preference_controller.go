@@ -0,0 +1,256 @@
+package controllers
+
+import (
+	"strconv"
+	"time"
+
+	"pethub_api/middleware"
+	"pethub_api/models"
+	"pethub_api/models/response"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// notificationPreferences returns one preference per notification type the
+// recipient can receive, filling in defaults for types they never set.
+func notificationPreferences(db *gorm.DB, recipientType string, recipientID uint) ([]models.NotificationPreference, error) {
+	var saved []models.NotificationPreference
+	if err := db.Where("recipient_type = ? AND recipient_id = ?", recipientType, recipientID).Find(&saved).Error; err != nil {
+		return nil, err
+	}
+	byType := make(map[string]models.NotificationPreference, len(saved))
+	for _, pref := range saved {
+		byType[pref.EventType] = pref
+	}
+
+	prefs := make([]models.NotificationPreference, 0, len(notificationTypes[recipientType]))
+	for _, eventType := range notificationTypes[recipientType] {
+		pref, ok := byType[eventType]
+		if !ok {
+			pref = defaultNotificationPreference(recipientType, recipientID, eventType)
+		}
+		prefs = append(prefs, pref)
+	}
+	return prefs, nil
+}
+
+func getNotificationPreferences(c *fiber.Ctx, recipientType, recipientIDStr string) error {
+	recipientID, err := strconv.Atoi(recipientIDStr)
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid " + recipientType + " ID",
+			Data:    nil,
+		})
+	}
+	if !callerIs(c, recipientType, uint(recipientID)) {
+		return c.JSON(response.ResponseModel{
+			RetCode: "403",
+			Message: "You can only view your own notification preferences",
+			Data:    nil,
+		})
+	}
+
+	prefs, err := notificationPreferences(middleware.DBConn, recipientType, uint(recipientID))
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to load notification preferences",
+			Data:    err.Error(),
+		})
+	}
+	settings, err := loadNotificationSettings(middleware.DBConn, recipientType, uint(recipientID))
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to load notification settings",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Notification preferences fetched successfully",
+		Data: fiber.Map{
+			"preferences": prefs,
+			"settings":    settings,
+		},
+	})
+}
+
+func updateNotificationPreferences(c *fiber.Ctx, recipientType, recipientIDStr string) error {
+	recipientID, err := strconv.Atoi(recipientIDStr)
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid " + recipientType + " ID",
+			Data:    nil,
+		})
+	}
+	if !callerIs(c, recipientType, uint(recipientID)) {
+		return c.JSON(response.ResponseModel{
+			RetCode: "403",
+			Message: "You can only change your own notification preferences",
+			Data:    nil,
+		})
+	}
+
+	var body struct {
+		Preferences []struct {
+			EventType string `json:"event_type"`
+			InApp     bool   `json:"in_app"`
+			Email     bool   `json:"email"`
+			SMS       bool   `json:"sms"`
+			Push      bool   `json:"push"`
+		} `json:"preferences"`
+		QuietHoursStart *string `json:"quiet_hours_start"` // HH:MM, "" to clear
+		QuietHoursEnd   *string `json:"quiet_hours_end"`
+		DailyDigest     *bool   `json:"daily_digest"`
+		DigestHour      *int    `json:"digest_hour"` // 0-23, recipient's local time
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		})
+	}
+
+	known := make(map[string]bool)
+	for _, eventType := range notificationTypes[recipientType] {
+		known[eventType] = true
+	}
+	prefs := make([]models.NotificationPreference, 0, len(body.Preferences))
+	for _, p := range body.Preferences {
+		if !known[p.EventType] {
+			return c.JSON(response.ResponseModel{
+				RetCode: "400",
+				Message: "Unknown notification type: " + p.EventType,
+				Data:    notificationTypes[recipientType],
+			})
+		}
+		prefs = append(prefs, models.NotificationPreference{
+			RecipientType: recipientType,
+			RecipientID:   uint(recipientID),
+			EventType:     p.EventType,
+			InApp:         p.InApp,
+			Email:         p.Email,
+			SMS:           p.SMS,
+			Push:          p.Push,
+		})
+	}
+
+	settings, err := loadNotificationSettings(middleware.DBConn, recipientType, uint(recipientID))
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to load notification settings",
+			Data:    err.Error(),
+		})
+	}
+	if body.QuietHoursStart != nil {
+		settings.QuietHoursStart = *body.QuietHoursStart
+	}
+	if body.QuietHoursEnd != nil {
+		settings.QuietHoursEnd = *body.QuietHoursEnd
+	}
+	if (settings.QuietHoursStart == "") != (settings.QuietHoursEnd == "") {
+		return c.JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Quiet hours need both a start and an end",
+			Data:    nil,
+		})
+	}
+	for _, clock := range []string{settings.QuietHoursStart, settings.QuietHoursEnd} {
+		if _, err := time.Parse("15:04", clock); clock != "" && err != nil {
+			return c.JSON(response.ResponseModel{
+				RetCode: "400",
+				Message: "Quiet hours must be in HH:MM format",
+				Data:    nil,
+			})
+		}
+	}
+	if body.DigestHour != nil {
+		if *body.DigestHour < 0 || *body.DigestHour > 23 {
+			return c.JSON(response.ResponseModel{
+				RetCode: "400",
+				Message: "digest_hour must be between 0 and 23",
+				Data:    nil,
+			})
+		}
+		settings.DigestHour = *body.DigestHour
+	}
+	digestTurnedOff := false
+	if body.DailyDigest != nil {
+		digestTurnedOff = settings.DailyDigest && !*body.DailyDigest
+		settings.DailyDigest = *body.DailyDigest
+	}
+
+	tx := middleware.DBConn.Begin()
+	if len(prefs) > 0 {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "recipient_type"}, {Name: "recipient_id"}, {Name: "event_type"}},
+			DoUpdates: clause.AssignmentColumns([]string{"in_app", "email", "sms", "push"}),
+		}).Create(&prefs).Error; err != nil {
+			tx.Rollback()
+			return c.JSON(response.ResponseModel{
+				RetCode: "500",
+				Message: "Failed to save notification preferences",
+				Data:    err.Error(),
+			})
+		}
+	}
+	if err := tx.Save(&settings).Error; err != nil {
+		tx.Rollback()
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to save notification settings",
+			Data:    err.Error(),
+		})
+	}
+	// Emails held back for the digest go out on their own from now on
+	if digestTurnedOff {
+		if err := tx.Model(&models.OutboxMessage{}).
+			Where("topic = ? AND status = ? AND group_key = ?", TopicNotificationEmail, "held",
+				middleware.RealtimeChannel(recipientType, uint(recipientID))).
+			Update("status", "pending").Error; err != nil {
+			tx.Rollback()
+			return c.JSON(response.ResponseModel{
+				RetCode: "500",
+				Message: "Failed to release digest emails",
+				Data:    err.Error(),
+			})
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to save notification preferences",
+			Data:    err.Error(),
+		})
+	}
+
+	return getNotificationPreferences(c, recipientType, recipientIDStr)
+}
+
+// GetAdopterNotificationPreferences lists which channels each notification
+// type goes out on, plus quiet hours and the daily digest setting.
+func GetAdopterNotificationPreferences(c *fiber.Ctx) error {
+	return getNotificationPreferences(c, RecipientAdopter, c.Params("adopter_id"))
+}
+
+// UpdateAdopterNotificationPreferences saves the types listed in the body;
+// types left out keep their current channels.
+func UpdateAdopterNotificationPreferences(c *fiber.Ctx) error {
+	return updateNotificationPreferences(c, RecipientAdopter, c.Params("adopter_id"))
+}
+
+func GetShelterNotificationPreferences(c *fiber.Ctx) error {
+	return getNotificationPreferences(c, RecipientShelter, c.Params("shelter_id"))
+}
+
+func UpdateShelterNotificationPreferences(c *fiber.Ctx) error {
+	return updateNotificationPreferences(c, RecipientShelter, c.Params("shelter_id"))
+}
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"pethub_api/middleware"
+	"pethub_api/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Outbox topics handled by this package
+const (
+	TopicEmail             = "email"
+	TopicNotificationEmail = "notification." + ChannelEmail
+	TopicNotificationSMS   = "notification." + ChannelSMS
+	TopicNotificationPush  = "notification." + ChannelPush
+)
+
+// RegisterOutboxHandlers installs the handlers that deliver this package's
+// outbox messages.
+func RegisterOutboxHandlers() {
+	middleware.RegisterOutboxHandler(TopicEmail, sendQueuedEmail)
+	middleware.RegisterOutboxHandler(TopicNotificationEmail, deliverNotification(ChannelEmail))
+	middleware.RegisterOutboxHandler(TopicNotificationSMS, deliverNotification(ChannelSMS))
+	middleware.RegisterOutboxHandler(TopicNotificationPush, deliverNotification(ChannelPush))
+}
+
+// outboxEmail is the payload of an email topic message. The attachment is
+// optional.
+type outboxEmail struct {
+	To             string `json:"to"`
+	Subject        string `json:"subject"`
+	Body           string `json:"body"`
+	AttachmentName string `json:"attachment_name,omitempty"`
+	AttachmentType string `json:"attachment_type,omitempty"`
+	Attachment     []byte `json:"attachment,omitempty"`
+}
+
+// queueEmail puts an email on the outbox in db.
+func queueEmail(db *gorm.DB, groupKey string, email outboxEmail) error {
+	return middleware.EnqueueOutbox(db, middleware.OutboxEntry{
+		Topic:    TopicEmail,
+		Payload:  email,
+		GroupKey: groupKey,
+	})
+}
+
+func sendQueuedEmail(db *gorm.DB, payload []byte) error {
+	var email outboxEmail
+	if err := json.Unmarshal(payload, &email); err != nil {
+		return err
+	}
+	if email.AttachmentName == "" {
+		return (emailChannel{}).Send(NotificationRecipient{Email: email.To}, email.Subject, email.Body)
+	}
+	return sendEmailWithAttachment(email.To, email.Subject, email.Body,
+		email.AttachmentName, email.AttachmentType, email.Attachment)
+}
+
+// GetOutboxMessages lists outbox messages, newest first, optionally
+// filtered by ?status= and ?topic=. Use status=dead to find failed
+// deliveries.
+func GetOutboxMessages(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := middleware.DBConn.Model(&models.OutboxMessage{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if topic := c.Query("topic"); topic != "" {
+		query = query.Where("topic = ?", topic)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch outbox messages",
+			"error":   err.Error(),
+		})
+	}
+	messages := []models.OutboxMessage{}
+	if err := query.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&messages).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch outbox messages",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Outbox messages fetched successfully",
+		"data": fiber.Map{
+			"messages": messages,
+			"page":     page,
+			"limit":    limit,
+			"total":    total,
+		},
+	})
+}
+
+// GetOutboxMessage returns one outbox message with its payload and last
+// error.
+func GetOutboxMessage(c *fiber.Ctx) error {
+	var message models.OutboxMessage
+	if err := middleware.DBConn.Where("message_id = ?", c.Params("message_id")).First(&message).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Outbox message not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to fetch outbox message",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Outbox message fetched successfully",
+		"data":    message,
+	})
+}
+
+// ReplayOutboxMessage queues a dead or held message again with a fresh set
+// of attempts.
+func ReplayOutboxMessage(c *fiber.Ctx) error {
+	messageID, err := strconv.ParseUint(c.Params("message_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid message ID",
+		})
+	}
+
+	replayed, err := middleware.ReplayOutboxMessage(middleware.DBConn, uint(messageID))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to replay outbox message",
+			"error":   err.Error(),
+		})
+	}
+	if replayed == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "Only dead or held messages can be replayed",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Outbox message queued for replay",
+	})
+}
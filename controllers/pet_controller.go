@@ -31,6 +31,30 @@ type PetResponse struct {
 	ShelterID       uint     `json:"shelter_id"`
 	PetImages       []string `json:"pet_image1"`
 	PetVaccine      string   `json:"pet_vaccine"`
+
+	models.PetProfile
+	Breeds []models.Breed `json:"breeds"`
+	models.PetIntake
+}
+
+// petListSpec is how pet lists are paged, sorted and filtered: ?pet_name=,
+// ?sex=, ?type= and ?priority_status=, on top of the profile filters.
+var petListSpec = listSpec{
+	IDColumn: "petinfo.pet_id",
+	Sorts: map[string]string{
+		"priority_status": "petinfo.priority_status",
+		"created_at":      "petinfo.created_at",
+		"pet_name":        "petinfo.pet_name",
+		"pet_id":          "petinfo.pet_id",
+	},
+	DefaultSort: "-priority_status,-created_at",
+	Filters: map[string]listFilter{
+		"pet_name":        {Column: "pet_name", Contains: true},
+		"sex":             {Column: "pet_sex"},
+		"type":            {Column: "pet_type"},
+		"priority_status": {Column: "priority_status"},
+	},
+	Preloads: []string{"Breeds"},
 }
 
 func AddPetInfo(c *fiber.Ctx) error {
@@ -43,28 +67,49 @@ func AddPetInfo(c *fiber.Ctx) error {
 		})
 	}
 
-	// Parse pet age
-	petAgeStr := c.FormValue("pet_age")
-	petAge, err := strconv.Atoi(petAgeStr)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "Invalid pet age",
-		})
-	}
-
 	// Create PetInfo
 	pet := models.PetInfo{
 		ShelterID:       uint(shelterID),
 		PetType:         c.FormValue("pet_type"),
 		PetName:         c.FormValue("pet_name"),
-		PetAge:          petAge,
-		AgeType:         c.FormValue("age_type"),
 		PetSex:          c.FormValue("pet_sex"),
 		PetSize:         c.FormValue("pet_size"),
 		PetDescriptions: c.FormValue("pet_descriptions"),
+		Status:          "available",
 		CreatedAt:       time.Now(),
 	}
 
+	// Structured profile; the age comes from birth_date, or from the older
+	// pet_age and age_type as an estimate
+	if _, err := readPetProfile(formFields(c), &pet); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	if pet.BirthDate == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "birth_date or pet_age is required",
+		})
+	}
+	breeds, _, err := readPetBreeds(formFields(c), middleware.DBConn, pet.PetType)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	pet.Breeds = breeds
+
+	// Intake defaults to today when no intake_date is sent
+	if _, err := readPetIntake(formFields(c), &pet); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	if pet.IntakeDate == nil {
+		today := time.Now()
+		pet.IntakeDate = &today
+	}
+
 	tx := middleware.DBConn.Begin()
 	if err := tx.Create(&pet).Error; err != nil {
 		tx.Rollback()
@@ -72,6 +117,12 @@ func AddPetInfo(c *fiber.Ctx) error {
 			"message": "Failed to save pet info",
 		})
 	}
+	if err := recordPetStatus(tx, pet.PetID, "", pet.Status, byShelter(pet.ShelterID, "Intake")); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to record pet intake",
+		})
+	}
 
 	// Handle image
 	var petImageBase64 string
@@ -147,50 +198,19 @@ func AddPetInfo(c *fiber.Ctx) error {
 }
 
 func FetchAndSearchPets(c *fiber.Ctx) error {
-	// Get filters from query parameters
 	shelterID := c.Params("id")
-	petName := c.Query("pet_name")
-	petStatus := c.Query("status")
-	petSex := c.Query("sex")
-	petType := c.Query("type")
-	prioritystatus := c.Query("priority_status")
-
-	var pets []models.PetInfo
 
-	query := middleware.DBConn.Where("shelter_id = ?", shelterID)
-
-	// if shelterID != "" {
-	// 	query = query.Where("shelter_id = ?", shelterID)
-	// }
-	if petName != "" {
-		query = query.Where("pet_name ILIKE ?", "%"+petName+"%")
-	}
-	if petStatus != "" {
-		query = query.Where("status = ?", petStatus)
-	}
-	if petSex != "" {
-		query = query.Where("pet_sex = ?", petSex)
-	}
-	if petType != "" {
-		query = query.Where("pet_type = ?", petType)
-	}
-	if prioritystatus != "" {
-		query = query.Where("priority_status = ?", prioritystatus)
-	}
-
-	result := query.Order("priority_status DESC").Order("created_at DESC").Find(&pets)
-	if result.Error != nil {
-		return c.JSON(response.ShelterResponseModel{
-			RetCode: "500",
-			Message: "Database error while searching pets",
-			Data:    result.Error,
-		})
+	spec := petListSpec
+	spec.Filters = map[string]listFilter{"status": {Column: "status"}}
+	for param, filter := range petListSpec.Filters {
+		spec.Filters[param] = filter
 	}
-
-	if len(pets) == 0 {
+	query := applyPetProfileFilters(c, middleware.DBConn.Where("shelter_id = ?", shelterID))
+	pets, pagination, retCode, err := paginateList[models.PetInfo](c, query, spec)
+	if err != nil {
 		return c.JSON(response.ShelterResponseModel{
-			RetCode: "404",
-			Message: "No pets found",
+			RetCode: retCode,
+			Message: err.Error(),
 			Data:    nil,
 		})
 	}
@@ -215,16 +235,22 @@ func FetchAndSearchPets(c *fiber.Ctx) error {
 		}
 	}
 
-	var petResponses []PetResponse
+	petResponses := []PetResponse{}
 	for _, pet := range pets {
 		petResponses = append(petResponses, PetResponse{
 			PetID:          pet.PetID,
 			PetType:        pet.PetType,
 			PetName:        pet.PetName,
 			PetSex:         pet.PetSex,
+			PetAge:         pet.PetAge,
+			AgeType:        pet.AgeType,
+			PetSize:        pet.PetSize,
 			PriorityStatus: pet.PriorityStatus,
 			ShelterID:      pet.ShelterID,
 			PetImages:      petMediaMap[pet.PetID],
+			PetProfile:     pet.PetProfile,
+			PetIntake:      pet.PetIntake,
+			Breeds:         pet.Breeds,
 		})
 	}
 
@@ -233,46 +259,22 @@ func FetchAndSearchPets(c *fiber.Ctx) error {
 		"data": fiber.Map{
 			"pets": petResponses,
 		},
+		"pagination": pagination,
 	})
 }
 
 func FetchAndSearchArchivedPets(c *fiber.Ctx) error {
-	// Get filters from query parameters
 	shelterID := c.Params("id")
-	petName := c.Query("pet_name")
-	petSex := c.Query("sex")
-	petType := c.Query("type")
-
-	var pets []models.PetInfo
 
+	// Archived pets are listed newest first; priority no longer applies
+	spec := petListSpec
+	spec.DefaultSort = "-created_at"
 	query := middleware.DBConn.Where("status = ? AND shelter_id = ?", "archived", shelterID)
-
-	// if shelterID != "" {
-	// 	query = query.Where("shelter_id = ?", shelterID)
-	// }
-	if petName != "" {
-		query = query.Where("pet_name ILIKE ?", "%"+petName+"%")
-	}
-	if petSex != "" {
-		query = query.Where("pet_sex = ?", petSex)
-	}
-	if petType != "" {
-		query = query.Where("pet_type = ?", petType)
-	}
-
-	result := query.Order("created_at DESC").Find(&pets)
-	if result.Error != nil {
-		return c.JSON(response.ShelterResponseModel{
-			RetCode: "500",
-			Message: "Database error while searching pets",
-			Data:    result.Error,
-		})
-	}
-
-	if len(pets) == 0 {
+	pets, pagination, retCode, err := paginateList[models.PetInfo](c, query, spec)
+	if err != nil {
 		return c.JSON(response.ShelterResponseModel{
-			RetCode: "404",
-			Message: "No pets found",
+			RetCode: retCode,
+			Message: err.Error(),
 			Data:    nil,
 		})
 	}
@@ -297,7 +299,7 @@ func FetchAndSearchArchivedPets(c *fiber.Ctx) error {
 		}
 	}
 
-	var petResponses []PetResponse
+	petResponses := []PetResponse{}
 	for _, pet := range pets {
 		petResponses = append(petResponses, PetResponse{
 			PetID:     pet.PetID,
@@ -314,6 +316,7 @@ func FetchAndSearchArchivedPets(c *fiber.Ctx) error {
 		"data": fiber.Map{
 			"pets": petResponses,
 		},
+		"pagination": pagination,
 	})
 }
 
@@ -331,7 +334,7 @@ func GetPetInfoByPetID(c *fiber.Ctx) error {
 	petID := c.Params("id")
 
 	var petInfo models.PetInfo
-	infoResult := middleware.DBConn.Debug().Preload("PetMedia").Where("pet_id = ?", petID).First(&petInfo)
+	infoResult := middleware.DBConn.Debug().Preload("PetMedia").Preload("Breeds").Where("pet_id = ?", petID).First(&petInfo)
 
 	if errors.Is(infoResult.Error, gorm.ErrRecordNotFound) {
 		return c.JSON(response.ShelterResponseModel{
@@ -360,7 +363,7 @@ func GetPetInfoByPetID2(c *fiber.Ctx) error {
 
 	// Fetch pet info for the given pet_id
 	var petInfo models.PetInfo
-	infoResult := middleware.DBConn.Where("pet_id = ?", petID).First(&petInfo)
+	infoResult := middleware.DBConn.Preload("Breeds").Preload("PetMedia").Where("pet_id = ?", petID).First(&petInfo)
 
 	if errors.Is(infoResult.Error, gorm.ErrRecordNotFound) {
 		return c.JSON(response.ShelterResponseModel{
@@ -401,6 +404,11 @@ func GetPetInfoByPetID2(c *fiber.Ctx) error {
 		}
 	}
 
+	var petVaccine string
+	if petInfo.PetMedia != nil {
+		petVaccine = petInfo.PetMedia.PetVaccine
+	}
+
 	// Create a response for the pet by combining pet info and media
 	petResponse := PetResponse{
 		PetID:           petInfo.PetID,
@@ -414,7 +422,10 @@ func GetPetInfoByPetID2(c *fiber.Ctx) error {
 		PriorityStatus:  petInfo.PriorityStatus,
 		ShelterID:       petInfo.ShelterID,
 		PetImages:       petImages, // Attach pet images
-		PetVaccine:      petInfo.PetMedia.PetVaccine,
+		PetVaccine:      petVaccine,
+		PetProfile:      petInfo.PetProfile,
+		PetIntake:       petInfo.PetIntake,
+		Breeds:          petInfo.Breeds,
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
@@ -451,22 +462,57 @@ func UpdatePetInfo(c *fiber.Ctx) error {
 	petInfo.PetSex = c.FormValue("pet_sex")
 	petInfo.PetSize = c.FormValue("pet_size")
 	petInfo.PetDescriptions = c.FormValue("pet_descriptions")
-	petInfo.AgeType = c.FormValue("age_type")
 
-	// Handle age field
-	ageStr := c.FormValue("pet_age")
-	petAge, err := strconv.Atoi(ageStr)
+	// Profile fields are only touched when sent, and can be cleared
+	profile, err := readPetProfile(formFields(c), &petInfo)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	intake, err := readPetIntake(formFields(c), &petInfo)
 	if err != nil {
 		return c.JSON(response.ShelterResponseModel{
 			RetCode: "400",
-			Message: "Invalid pet age",
-			Data:    err,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	for column, value := range intake {
+		profile[column] = value
+	}
+	breeds, breedsSent, err := readPetBreeds(formFields(c), middleware.DBConn, petInfo.PetType)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: err.Error(),
+			Data:    nil,
 		})
 	}
-	petInfo.PetAge = petAge
 
 	// Update PetInfo in the database
-	middleware.DBConn.Table("petinfo").Where("pet_id = ?", petID).Updates(&petInfo)
+	middleware.DBConn.Table("petinfo").Where("pet_id = ?", petID).Omit("Breeds", "PetMedia").Updates(&petInfo)
+	if len(profile) > 0 {
+		if err := middleware.DBConn.Model(&models.PetInfo{}).Where("pet_id = ?", petID).Updates(profile).Error; err != nil {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "500",
+				Message: "Failed to update pet profile",
+				Data:    err.Error(),
+			})
+		}
+	}
+	if breedsSent {
+		if err := middleware.DBConn.Model(&petInfo).Association("Breeds").Replace(breeds); err != nil {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "500",
+				Message: "Failed to update pet breeds",
+				Data:    err.Error(),
+			})
+		}
+		petInfo.Breeds = breeds
+	}
 
 	// Fetch or prepare PetMedia
 	var petMedia models.PetMedia
@@ -526,8 +572,17 @@ func UpdatePriorityStatus(c *fiber.Ctx) error {
 		})
 	}
 
-	// If setting to true, check if limit of 3 is reached
+	// If setting to true, check the pet can be prioritized and the limit of 3
+	// is not reached
 	if !petInfo.PriorityStatus {
+		if err := validatePetPriority(petInfo.Status); err != nil {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "409",
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+
 		var count int64
 		err := middleware.DBConn.Model(&models.PetInfo{}).
 			Where("shelter_id = ? AND status = ? AND priority_status = ?", petInfo.ShelterID, "available", true).
@@ -592,7 +647,7 @@ func SetPetStatusToArchive(c *fiber.Ctx) error {
 	}
 
 	// Check if the pet is already archived
-	if pet.Status == "archived" {
+	if pet.Status == models.PetArchived {
 		return c.JSON(response.ShelterResponseModel{
 			RetCode: "400",
 			Message: "Pet is already archived",
@@ -601,14 +656,18 @@ func SetPetStatusToArchive(c *fiber.Ctx) error {
 	}
 
 	// Update pet status to 'archived'
-	pet.Status = "archived"
-	updateResult := middleware.DBConn.Save(&pet)
-
-	if updateResult.Error != nil {
+	var body struct {
+		Reason string `json:"reason" form:"reason"`
+	}
+	c.BodyParser(&body) // the reason is optional
+	if body.Reason == "" {
+		body.Reason = "Archived by shelter"
+	}
+	if err := setPetStatus(middleware.DBConn, &pet, models.PetArchived, byShelter(pet.ShelterID, body.Reason)); err != nil {
 		return c.JSON(response.ShelterResponseModel{
-			RetCode: "500",
-			Message: "Database error while updating pet status",
-			Data:    updateResult.Error,
+			RetCode: petStatusRetCode(err),
+			Message: "Failed to update pet status",
+			Data:    err.Error(),
 		})
 	}
 
@@ -641,8 +700,9 @@ func SetPetStatusToUnarchive(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if the pet is already archived
-	if pet.Status == "available" {
+	// Only archived pets can be unarchived; adopted pets come back through
+	// a recorded return
+	if pet.Status != models.PetArchived {
 		return c.JSON(response.ShelterResponseModel{
 			RetCode: "400",
 			Message: "Pet is not archived",
@@ -650,15 +710,19 @@ func SetPetStatusToUnarchive(c *fiber.Ctx) error {
 		})
 	}
 
-	// Update pet status to 'archived'
-	pet.Status = "available"
-	updateResult := middleware.DBConn.Save(&pet)
-
-	if updateResult.Error != nil {
+	// Update pet status to 'available'
+	var body struct {
+		Reason string `json:"reason" form:"reason"`
+	}
+	c.BodyParser(&body) // the reason is optional
+	if body.Reason == "" {
+		body.Reason = "Unarchived by shelter"
+	}
+	if err := setPetStatus(middleware.DBConn, &pet, models.PetAvailable, byShelter(pet.ShelterID, body.Reason)); err != nil {
 		return c.JSON(response.ShelterResponseModel{
-			RetCode: "500",
-			Message: "Database error while updating pet status",
-			Data:    updateResult.Error,
+			RetCode: petStatusRetCode(err),
+			Message: "Failed to update pet status",
+			Data:    err.Error(),
 		})
 	}
 
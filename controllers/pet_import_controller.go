@@ -0,0 +1,633 @@
+package controllers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"pethub_api/middleware"
+	"pethub_api/models"
+	"pethub_api/models/response"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	maxPetImportSize  = 5 * 1024 * 1024
+	maxPetImportRows  = 1000
+	maxPetPhotosSize  = 50 * 1024 * 1024
+	maxPetPhotoSize   = 5 * 1024 * 1024
+	maxPetPhotosTotal = 100 * 1024 * 1024 // unpacked, across the photos the sheet uses
+	maxPetPhotoFiles  = 2000
+	xlsxContentType   = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	petImportDateHelp = "YYYY-MM-DD"
+)
+
+// petImportColumn documents one column of the pet import layout.
+type petImportColumn struct {
+	Name        string `json:"name"`
+	Required    bool   `json:"required"`
+	Description string `json:"description"`
+}
+
+// petImportColumns is the column layout for bulk pet imports. The header
+// row names the columns, in any order and case; other columns, such as the
+// ones the export adds, are ignored. Blank cells are left unset.
+var petImportColumns = []petImportColumn{
+	{"pet_name", true, "Name of the pet"},
+	{"pet_type", true, "Species, e.g. dog, cat or rabbit"},
+	{"pet_sex", false, "male or female"},
+	{"pet_size", false, "e.g. small, medium or large"},
+	{"pet_descriptions", false, "Free-text description"},
+	{"birth_date", false, petImportDateHelp + "; birth_date or pet_age is required"},
+	{"birth_date_estimated", false, "true if the birth date is a guess"},
+	{"pet_age", false, "Age as a number, used when there is no birth_date"},
+	{"age_type", false, "Unit of pet_age: weeks, months or years (default)"},
+	{"breeds", false, "Up to 3 breeds from the breed list, comma-separated"},
+	{"color", false, "Coat color"},
+	{"coat", false, "hairless, short, medium, long, wire or curly"},
+	{"weight_kg", false, "Weight in kilograms"},
+	{"spayed_neutered", false, "yes, no or unknown"},
+	{"microchip_number", false, "9 to 15 letters or digits"},
+	{"house_trained", false, "yes, no or unknown"},
+	{"good_with_kids", false, "yes, no or unknown"},
+	{"good_with_dogs", false, "yes, no or unknown"},
+	{"good_with_cats", false, "yes, no or unknown"},
+	{"energy_level", false, "low, medium or high"},
+	{"special_needs", false, "Description of any special needs"},
+	{"intake_date", false, petImportDateHelp + "; defaults to the import date"},
+	{"intake_source", false, "stray, surrender, transfer, born_in_shelter or returned"},
+	{"intake_notes", false, "Notes on how the pet came in"},
+	{"photo", false, "File name of the pet's photo in the photos ZIP"},
+}
+
+// petImportRow is one data row of an import, as parsed and validated.
+type petImportRow struct {
+	Row     int            `json:"row"` // line in the file, counting the header as 1
+	PetName string         `json:"pet_name"`
+	Errors  []string       `json:"errors"`
+	Pet     models.PetInfo `json:"-"`
+	Photo   string         `json:"-"` // base64
+}
+
+// petImportReport summarises a parsed import for the preview and for a
+// rejected import.
+type petImportReport struct {
+	TotalRows    int            `json:"total_rows"`
+	ValidRows    int            `json:"valid_rows"`
+	ErrorRows    int            `json:"error_rows"`
+	Rows         []petImportRow `json:"rows"`
+	UnusedPhotos []string       `json:"unused_photos"`
+}
+
+// GetPetImportColumns documents the import layout. With ?format=csv or
+// ?format=xlsx it returns an empty template with the header row instead.
+func GetPetImportColumns(c *fiber.Ctx) error {
+	format := strings.ToLower(c.Query("format"))
+	if format == "" {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "200",
+			Message: "Pet import columns",
+			Data:    petImportColumns,
+		})
+	}
+
+	header := make([]string, len(petImportColumns))
+	for i, column := range petImportColumns {
+		header[i] = column.Name
+	}
+	return sendPetSheet(c, format, "pet-import-template", [][]string{header})
+}
+
+// PreviewPetImport validates an import without saving anything and reports
+// the errors found on each row. The sheet is sent as "file" (CSV or XLSX)
+// with an optional "photos" ZIP.
+func PreviewPetImport(c *fiber.Ctx) error {
+	shelterID, err := strconv.ParseUint(c.Params("shelter_id"), 10, 32)
+	if err != nil || !callerIsShelter(c, uint(shelterID)) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "You can only import pets into your own shelter",
+			Data:    nil,
+		})
+	}
+
+	report, retCode, err := readPetImport(c, uint(shelterID))
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: fmt.Sprintf("%d of %d rows are ready to import", report.ValidRows, report.TotalRows),
+		Data:    report,
+	})
+}
+
+// ImportPets creates every pet in an import, or none of them if any row
+// has an error. It takes the same upload as PreviewPetImport.
+func ImportPets(c *fiber.Ctx) error {
+	shelterID, err := strconv.ParseUint(c.Params("shelter_id"), 10, 32)
+	if err != nil || !callerIsShelter(c, uint(shelterID)) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "You can only import pets into your own shelter",
+			Data:    nil,
+		})
+	}
+
+	report, retCode, err := readPetImport(c, uint(shelterID))
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	if report.ErrorRows > 0 {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: fmt.Sprintf("%d rows have errors; nothing was imported", report.ErrorRows),
+			Data:    report,
+		})
+	}
+
+	petIDs := make([]uint, 0, len(report.Rows))
+	err = middleware.DBConn.Transaction(func(tx *gorm.DB) error {
+		for i := range report.Rows {
+			row := &report.Rows[i]
+			if err := tx.Create(&row.Pet).Error; err != nil {
+				return fmt.Errorf("row %d: %w", row.Row, err)
+			}
+			if err := recordPetStatus(tx, row.Pet.PetID, "", row.Pet.Status, byShelter(row.Pet.ShelterID, "Intake (bulk import)")); err != nil {
+				return fmt.Errorf("row %d: %w", row.Row, err)
+			}
+			if err := tx.Create(&models.PetMedia{PetID: row.Pet.PetID, PetImage1: row.Photo}).Error; err != nil {
+				return fmt.Errorf("row %d: %w", row.Row, err)
+			}
+			petIDs = append(petIDs, row.Pet.PetID)
+		}
+		return nil
+	})
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to import pets; nothing was imported",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "201",
+		Message: fmt.Sprintf("%d pets imported", len(petIDs)),
+		Data: fiber.Map{
+			"imported": len(petIDs),
+			"pet_ids":  petIDs,
+		},
+	})
+}
+
+// ExportPets downloads a shelter's full pet list, in every status, as CSV
+// or, with ?format=xlsx, as XLSX. The columns are the import layout plus
+// pet_id, status and length_of_stay_days; photos are not included.
+func ExportPets(c *fiber.Ctx) error {
+	shelterID, err := strconv.ParseUint(c.Params("shelter_id"), 10, 32)
+	if err != nil || !callerIsShelter(c, uint(shelterID)) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "You can only export your own shelter's pets",
+			Data:    nil,
+		})
+	}
+	format := strings.ToLower(c.Query("format", "csv"))
+
+	pets := []models.PetInfo{}
+	if err := middleware.DBConn.Preload("Breeds").Where("shelter_id = ?", shelterID).
+		Order("pet_id").Find(&pets).Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to fetch pets",
+			Data:    err.Error(),
+		})
+	}
+
+	header := []string{"pet_id", "status"}
+	for _, column := range petImportColumns {
+		if column.Name != "photo" {
+			header = append(header, column.Name)
+		}
+	}
+	header = append(header, "length_of_stay_days")
+
+	rows := [][]string{header}
+	for _, pet := range pets {
+		values := petExportValues(pet)
+		row := make([]string, len(header))
+		for i, name := range header {
+			row[i] = values[name]
+		}
+		rows = append(rows, row)
+	}
+	return sendPetSheet(c, format, fmt.Sprintf("pets-%d-%s", shelterID, time.Now().Format("2006-01-02")), rows)
+}
+
+// petExportValues lays a pet out by export column.
+func petExportValues(pet models.PetInfo) map[string]string {
+	date := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format("2006-01-02")
+	}
+	triState := func(b *bool) string {
+		switch {
+		case b == nil:
+			return ""
+		case *b:
+			return "yes"
+		}
+		return "no"
+	}
+	breeds := make([]string, len(pet.Breeds))
+	for i, breed := range pet.Breeds {
+		breeds[i] = breed.Name
+	}
+	weight := ""
+	if pet.WeightKg > 0 {
+		weight = strconv.FormatFloat(pet.WeightKg, 'f', -1, 64)
+	}
+
+	return map[string]string{
+		"pet_id":               strconv.FormatUint(uint64(pet.PetID), 10),
+		"status":               string(pet.Status),
+		"pet_name":             pet.PetName,
+		"pet_type":             pet.PetType,
+		"pet_sex":              pet.PetSex,
+		"pet_size":             pet.PetSize,
+		"pet_descriptions":     pet.PetDescriptions,
+		"birth_date":           date(pet.BirthDate),
+		"birth_date_estimated": strconv.FormatBool(pet.BirthDateEstimated),
+		"pet_age":              strconv.Itoa(pet.PetAge),
+		"age_type":             pet.AgeType,
+		"breeds":               strings.Join(breeds, ", "),
+		"color":                pet.Color,
+		"coat":                 pet.Coat,
+		"weight_kg":            weight,
+		"spayed_neutered":      triState(pet.SpayedNeutered),
+		"microchip_number":     pet.MicrochipNumber,
+		"house_trained":        triState(pet.HouseTrained),
+		"good_with_kids":       triState(pet.GoodWithKids),
+		"good_with_dogs":       triState(pet.GoodWithDogs),
+		"good_with_cats":       triState(pet.GoodWithCats),
+		"energy_level":         pet.EnergyLevel,
+		"special_needs":        pet.SpecialNeeds,
+		"intake_date":          date(pet.IntakeDate),
+		"intake_source":        pet.IntakeSource,
+		"intake_notes":         pet.IntakeNotes,
+		"length_of_stay_days":  strconv.Itoa(pet.LengthOfStayDays),
+	}
+}
+
+// sendPetSheet sends rows as a CSV or XLSX download named filename.
+func sendPetSheet(c *fiber.Ctx, format, filename string, rows [][]string) error {
+	switch format {
+	case "csv":
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		if err := w.WriteAll(rows); err != nil {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "500",
+				Message: "Failed to write CSV",
+				Data:    err.Error(),
+			})
+		}
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+		return c.Send(buf.Bytes())
+	case "xlsx":
+		data, err := middleware.WriteXLSX("Pets", rows)
+		if err != nil {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "500",
+				Message: "Failed to write XLSX",
+				Data:    err.Error(),
+			})
+		}
+		c.Set(fiber.HeaderContentType, xlsxContentType)
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.xlsx"`, filename))
+		return c.Send(data)
+	}
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "400",
+		Message: "format must be csv or xlsx",
+		Data:    nil,
+	})
+}
+
+// readPetImport parses the uploaded sheet and photos and validates every
+// row. Problems with the upload as a whole are returned as an error; row
+// problems are listed in the report.
+func readPetImport(c *fiber.Ctx, shelterID uint) (petImportReport, string, error) {
+	report := petImportReport{Rows: []petImportRow{}, UnusedPhotos: []string{}}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return report, "400", errors.New("file is required")
+	}
+	sheet, err := readPetSheet(file)
+	if err != nil {
+		return report, "400", err
+	}
+
+	// The header row names the columns
+	if len(sheet) == 0 {
+		return report, "400", errors.New("The file is empty")
+	}
+	columns := map[string]int{}
+	for i, name := range sheet[0] {
+		name = strings.TrimPrefix(name, "\ufeff") // byte order mark
+		name = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "_")
+		if _, seen := columns[name]; name != "" && !seen {
+			columns[name] = i
+		}
+	}
+	for _, column := range petImportColumns {
+		if _, ok := columns[column.Name]; column.Required && !ok {
+			return report, "400", fmt.Errorf("The header row has no %s column", column.Name)
+		}
+	}
+	if len(sheet)-1 > maxPetImportRows {
+		return report, "400", fmt.Errorf("An import can have at most %d rows", maxPetImportRows)
+	}
+
+	// Only the photos the sheet names are unpacked
+	usedPhotos := map[string]bool{}
+	for _, cells := range sheet[1:] {
+		if name, ok := rowFields(columns, cells)("photo"); ok {
+			usedPhotos[strings.ToLower(path.Base(name))] = true
+		}
+	}
+	photos := map[string][]byte{}
+	if archive, err := c.FormFile("photos"); err == nil {
+		if photos, report.UnusedPhotos, err = readPetPhotos(archive, usedPhotos); err != nil {
+			return report, "400", err
+		}
+	}
+
+	microchips := map[string]int{}
+	for i, cells := range sheet[1:] {
+		if isBlankRow(cells) {
+			continue
+		}
+		fields := rowFields(columns, cells)
+		row := readPetImportRow(fields, shelterID, photos)
+		row.Row = i + 2
+
+		if chip := row.Pet.MicrochipNumber; chip != "" {
+			if first, seen := microchips[strings.ToUpper(chip)]; seen {
+				row.Errors = append(row.Errors, fmt.Sprintf("microchip_number is also on row %d", first))
+			} else {
+				microchips[strings.ToUpper(chip)] = row.Row
+			}
+		}
+		report.Rows = append(report.Rows, row)
+	}
+	if len(report.Rows) == 0 {
+		return report, "400", errors.New("The file has no pets")
+	}
+
+	// Microchips already registered to the shelter's pets
+	if len(microchips) > 0 {
+		chips := make([]string, 0, len(microchips))
+		for chip := range microchips {
+			chips = append(chips, chip)
+		}
+		var existing []string
+		if err := middleware.DBConn.Model(&models.PetInfo{}).
+			Where("shelter_id = ? AND UPPER(microchip_number) IN ?", shelterID, chips).
+			Pluck("UPPER(microchip_number)", &existing).Error; err != nil {
+			return report, "500", errors.New("Database error while checking microchips")
+		}
+		for _, chip := range existing {
+			row := &report.Rows[indexOfImportRow(report.Rows, microchips[chip])]
+			row.Errors = append(row.Errors, "microchip_number belongs to a pet already in your shelter")
+		}
+	}
+
+	for _, row := range report.Rows {
+		if len(row.Errors) > 0 {
+			report.ErrorRows++
+		}
+	}
+	report.TotalRows = len(report.Rows)
+	report.ValidRows = report.TotalRows - report.ErrorRows
+	return report, "200", nil
+}
+
+// readPetImportRow builds the pet for one row and collects its errors,
+// with the same rules as AddPetInfo.
+func readPetImportRow(fields petFields, shelterID uint, photos map[string][]byte) petImportRow {
+	value := func(key string) string {
+		v, _ := fields(key)
+		return v
+	}
+	row := petImportRow{
+		PetName: value("pet_name"),
+		Errors:  []string{},
+		Pet: models.PetInfo{
+			ShelterID:       shelterID,
+			PetType:         value("pet_type"),
+			PetName:         value("pet_name"),
+			PetSex:          value("pet_sex"),
+			PetSize:         value("pet_size"),
+			PetDescriptions: value("pet_descriptions"),
+			Status:          models.PetAvailable,
+			CreatedAt:       time.Now(),
+		},
+	}
+	pet := &row.Pet
+
+	if pet.PetName == "" {
+		row.Errors = append(row.Errors, "pet_name is required")
+	}
+	if pet.PetType == "" {
+		row.Errors = append(row.Errors, "pet_type is required")
+	}
+	if _, err := readPetProfile(fields, pet); err != nil {
+		row.Errors = append(row.Errors, err.Error())
+	} else if pet.BirthDate == nil {
+		row.Errors = append(row.Errors, "birth_date or pet_age is required")
+	}
+	if pet.PetType != "" {
+		breeds, _, err := readPetBreeds(fields, middleware.DBConn, pet.PetType)
+		if err != nil {
+			row.Errors = append(row.Errors, err.Error())
+		}
+		pet.Breeds = breeds
+	}
+	if _, err := readPetIntake(fields, pet); err != nil {
+		row.Errors = append(row.Errors, err.Error())
+	}
+	if pet.IntakeDate == nil {
+		today := time.Now()
+		pet.IntakeDate = &today
+	}
+
+	if name, ok := fields("photo"); ok {
+		photo, found := photos[strings.ToLower(path.Base(name))]
+		if found {
+			row.Photo = base64.StdEncoding.EncodeToString(photo)
+		} else {
+			row.Errors = append(row.Errors, fmt.Sprintf("photo '%s' is not in the photos ZIP", name))
+		}
+	}
+	return row
+}
+
+// rowFields reads a sheet row by column name. Blank cells count as not
+// sent, and date columns also accept the serial numbers spreadsheets use.
+func rowFields(columns map[string]int, cells []string) petFields {
+	return func(key string) (string, bool) {
+		i, ok := columns[key]
+		if !ok || i >= len(cells) {
+			return "", false
+		}
+		value := strings.TrimSpace(cells[i])
+		if value == "" {
+			return "", false
+		}
+		if key == "birth_date" || key == "intake_date" {
+			if serial, err := strconv.ParseFloat(value, 64); err == nil {
+				value = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(serial)).Format("2006-01-02")
+			}
+		}
+		return value, true
+	}
+}
+
+func isBlankRow(cells []string) bool {
+	for _, cell := range cells {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func indexOfImportRow(rows []petImportRow, line int) int {
+	for i, row := range rows {
+		if row.Row == line {
+			return i
+		}
+	}
+	return -1
+}
+
+// readPetSheet reads an uploaded CSV or XLSX into rows of cells.
+func readPetSheet(file *multipart.FileHeader) ([][]string, error) {
+	if file.Size > maxPetImportSize {
+		return nil, errors.New("The file is larger than 5MB")
+	}
+	f, err := file.Open()
+	if err != nil {
+		return nil, errors.New("Failed to open the file")
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, errors.New("Failed to read the file")
+	}
+
+	// XLSX files are ZIP archives
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return middleware.ReadXLSX(data, maxPetImportRows+1)
+	}
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("The file is not a valid CSV: %v", err)
+	}
+	return rows, nil
+}
+
+// readPetPhotos reads the images in an uploaded ZIP that the sheet uses,
+// keyed by lower-cased file name without folders. The names of the other
+// files are returned unread.
+func readPetPhotos(file *multipart.FileHeader, used map[string]bool) (map[string][]byte, []string, error) {
+	unused := []string{}
+	if file.Size > maxPetPhotosSize {
+		return nil, unused, errors.New("The photos ZIP is larger than 50MB")
+	}
+	f, err := file.Open()
+	if err != nil {
+		return nil, unused, errors.New("Failed to open the photos ZIP")
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, unused, errors.New("Failed to read the photos ZIP")
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, unused, errors.New("photos must be a ZIP file")
+	}
+	if len(archive.File) > maxPetPhotoFiles {
+		return nil, unused, fmt.Errorf("The photos ZIP can have at most %d files", maxPetPhotoFiles)
+	}
+
+	photos := map[string][]byte{}
+	total := 0
+	for _, entry := range archive.File {
+		name := path.Base(entry.Name)
+		if entry.FileInfo().IsDir() || strings.HasPrefix(entry.Name, "__MACOSX/") || strings.HasPrefix(name, ".") {
+			continue
+		}
+		key := strings.ToLower(name)
+		if !used[key] {
+			unused = append(unused, key)
+			continue
+		}
+		if _, seen := photos[key]; seen {
+			continue
+		}
+		if entry.UncompressedSize64 > maxPetPhotoSize {
+			return nil, unused, fmt.Errorf("Photo %s is larger than 5MB", name)
+		}
+		r, err := entry.Open()
+		if err != nil {
+			return nil, unused, fmt.Errorf("Failed to read photo %s", name)
+		}
+		photo, err := io.ReadAll(io.LimitReader(r, maxPetPhotoSize+1))
+		r.Close()
+		if err != nil {
+			return nil, unused, fmt.Errorf("Failed to read photo %s", name)
+		}
+		if len(photo) > maxPetPhotoSize {
+			return nil, unused, fmt.Errorf("Photo %s is larger than 5MB", name)
+		}
+		if total += len(photo); total > maxPetPhotosTotal {
+			return nil, unused, errors.New("The photos in the ZIP add up to more than 100MB")
+		}
+		if contentType := http.DetectContentType(photo); contentType != "image/png" && contentType != "image/jpeg" {
+			return nil, unused, fmt.Errorf("Photo %s must be a PNG or JPEG", name)
+		}
+		photos[key] = photo
+	}
+	return photos, unused, nil
+}
@@ -0,0 +1,398 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"pethub_api/middleware"
+	"pethub_api/models"
+	"pethub_api/models/response"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var petIntakeSources = map[string]bool{
+	"stray":           true,
+	"surrender":       true,
+	"transfer":        true,
+	"born_in_shelter": true,
+	"returned":        true,
+}
+
+// readPetIntake applies the intake fields present in fields to pet and
+// returns them as column updates.
+func readPetIntake(fields petFields, pet *models.PetInfo) (map[string]interface{}, error) {
+	columns := map[string]interface{}{}
+
+	if value, ok := fields("intake_date"); ok {
+		intakeDate, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return nil, errors.New("intake_date must be YYYY-MM-DD")
+		}
+		if intakeDate.After(time.Now()) {
+			return nil, errors.New("intake_date cannot be in the future")
+		}
+		pet.IntakeDate = &intakeDate
+		columns["intake_date"] = pet.IntakeDate
+	}
+	if value, ok := fields("intake_source"); ok {
+		value = strings.ToLower(strings.ReplaceAll(value, "-", "_"))
+		if value != "" && !petIntakeSources[value] {
+			return nil, errors.New("intake_source must be stray, surrender, transfer, born_in_shelter or returned")
+		}
+		pet.IntakeSource = value
+		columns["intake_source"] = value
+	}
+	if value, ok := fields("intake_notes"); ok {
+		pet.IntakeNotes = value
+		columns["intake_notes"] = value
+	}
+	return columns, nil
+}
+
+// petStatusChange says who changed a pet's status and why. ActorID is the
+// shelter or adopter, and nil for changes made by the system.
+type petStatusChange struct {
+	ActorRole     string
+	ActorID       *uint
+	ApplicationID *uint
+	Reason        string
+}
+
+// byShelter, byAdopter and bySystem build a petStatusChange for the usual
+// actors.
+func byShelter(shelterID uint, reason string) petStatusChange {
+	return petStatusChange{ActorRole: "shelter", ActorID: &shelterID, Reason: reason}
+}
+
+func byAdopter(adopterID uint, reason string) petStatusChange {
+	return petStatusChange{ActorRole: "adopter", ActorID: &adopterID, Reason: reason}
+}
+
+func bySystem(reason string) petStatusChange {
+	return petStatusChange{ActorRole: "system", Reason: reason}
+}
+
+// forApplication ties the change to the application that caused it.
+func (change petStatusChange) forApplication(applicationID uint) petStatusChange {
+	change.ApplicationID = &applicationID
+	return change
+}
+
+// recordPetStatus appends a status change to the pet's history.
+func recordPetStatus(db *gorm.DB, petID uint, oldStatus, newStatus models.PetStatus, change petStatusChange) error {
+	return db.Create(&models.PetStatusHistory{
+		PetID:         petID,
+		OldStatus:     oldStatus,
+		NewStatus:     newStatus,
+		ActorRole:     change.ActorRole,
+		ActorID:       change.ActorID,
+		ApplicationID: change.ApplicationID,
+		Reason:        change.Reason,
+		CreatedAt:     time.Now(),
+	}).Error
+}
+
+// setPetStatus moves pet to status and records the change. Every pet status
+// change goes through here, so the transition rules in pet_status.go are
+// always applied and the history stays complete. The update only applies
+// if the pet is still in the status it was loaded with.
+func setPetStatus(db *gorm.DB, pet *models.PetInfo, status models.PetStatus, change petStatusChange) error {
+	oldStatus := pet.Status
+	if oldStatus == status {
+		return nil
+	}
+	facts, err := loadPetStatusFacts(db, *pet, status)
+	if err != nil {
+		return err
+	}
+	if err := validatePetTransition(oldStatus, status, facts); err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{"status": status}
+	leftShelterAt := pet.LeftShelterAt
+	switch {
+	case petOutStatuses[status] && !petOutStatuses[oldStatus]:
+		now := time.Now()
+		leftShelterAt = &now
+	case !petOutStatuses[status]:
+		leftShelterAt = nil
+	}
+	updates["left_shelter_at"] = leftShelterAt
+	if status != models.PetAvailable {
+		updates["priority_status"] = false
+	}
+
+	result := db.Model(&models.PetInfo{}).
+		Where("pet_id = ? AND status = ?", pet.PetID, oldStatus).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("Pet status was changed by someone else")
+	}
+	if err := recordPetStatus(db, pet.PetID, oldStatus, status, change); err != nil {
+		return err
+	}
+
+	pet.Status = status
+	pet.LeftShelterAt = leftShelterAt
+	if status != models.PetAvailable {
+		pet.PriorityStatus = false
+	}
+	pet.LengthOfStayDays = pet.LengthOfStay(time.Now())
+	return nil
+}
+
+// RecordPetReturn records an adopted pet coming back to the shelter and
+// makes it available again, starting a new stay from the return date.
+func RecordPetReturn(c *fiber.Ctx) error {
+	pet, retCode, err := findShelterPet(c)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	if pet.Status != models.PetAdopted {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "409",
+			Message: "Only an adopted pet can be returned",
+			Data:    nil,
+		})
+	}
+
+	var body struct {
+		ReturnedOn string `json:"returned_on"`
+		Reason     string `json:"reason"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		})
+	}
+	body.Reason = strings.TrimSpace(body.Reason)
+	if body.Reason == "" {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "reason is required",
+			Data:    nil,
+		})
+	}
+	returnedOn := time.Now()
+	if body.ReturnedOn != "" {
+		if returnedOn, err = time.Parse("2006-01-02", body.ReturnedOn); err != nil {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "400",
+				Message: "returned_on must be YYYY-MM-DD",
+				Data:    nil,
+			})
+		}
+		if returnedOn.After(time.Now()) {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "400",
+				Message: "returned_on cannot be in the future",
+				Data:    nil,
+			})
+		}
+	}
+
+	petReturn := models.PetReturn{
+		PetID:      pet.PetID,
+		ShelterID:  pet.ShelterID,
+		ReturnedOn: returnedOn,
+		Reason:     body.Reason,
+		CreatedAt:  time.Now(),
+	}
+	var adoption models.AdoptionSubmission
+	if err := middleware.DBConn.Where("pet_id = ? AND status = ?", pet.PetID, "completed").
+		Order("updated_at DESC").First(&adoption).Error; err == nil {
+		petReturn.ApplicationID = &adoption.ApplicationID
+		petReturn.AdopterID = &adoption.AdopterID
+	}
+
+	err = middleware.DBConn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&petReturn).Error; err != nil {
+			return err
+		}
+		pet.IntakeDate = &returnedOn
+		pet.IntakeSource = "returned"
+		pet.IntakeNotes = body.Reason
+		if err := tx.Model(&models.PetInfo{}).Where("pet_id = ?", pet.PetID).Updates(map[string]interface{}{
+			"intake_date":   pet.IntakeDate,
+			"intake_source": pet.IntakeSource,
+			"intake_notes":  pet.IntakeNotes,
+		}).Error; err != nil {
+			return err
+		}
+		change := byShelter(pet.ShelterID, "Returned: "+body.Reason)
+		if petReturn.ApplicationID != nil {
+			change = change.forApplication(*petReturn.ApplicationID)
+		}
+		return setPetStatus(tx, &pet, models.PetAvailable, change)
+	})
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: petStatusRetCode(err),
+			Message: "Failed to record return",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Return recorded; the pet is available again",
+		Data: fiber.Map{
+			"return": petReturn,
+			"pet":    pet,
+		},
+	})
+}
+
+// GetPetStatusHistory returns a pet's intake details and every status
+// change since, oldest first.
+func GetPetStatusHistory(c *fiber.Ctx) error {
+	pet, retCode, err := findShelterPet(c)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	history := []models.PetStatusHistory{}
+	if err := middleware.DBConn.Where("pet_id = ?", pet.PetID).
+		Order("created_at, history_id").Find(&history).Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to fetch status history",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Status history fetched successfully",
+		Data: fiber.Map{
+			"pet_id":  pet.PetID,
+			"status":  pet.Status,
+			"intake":  pet.PetIntake,
+			"history": history,
+		},
+	})
+}
+
+// lengthOfStayStats summarises how long a group of pets stayed in care.
+type lengthOfStayStats struct {
+	PetType              *string  `json:"pet_type"` // nil for the shelter-wide row
+	InCare               int      `json:"in_care"`
+	AvgDaysInCare        *float64 `json:"avg_days_in_care"`
+	Adopted              int      `json:"adopted"`
+	AvgDaysToAdoption    *float64 `json:"avg_days_to_adoption"`
+	MedianDaysToAdoption *float64 `json:"median_days_to_adoption"`
+	LongestDaysInCare    *int     `json:"longest_days_in_care"`
+}
+
+// GetShelterLengthOfStay reports days in care for a shelter's pets, overall
+// and per pet type, along with the pets that have waited longest. An
+// optional ?adopted_from= and ?adopted_to= (YYYY-MM-DD) limit the adoption
+// figures to pets adopted in that range.
+func GetShelterLengthOfStay(c *fiber.Ctx) error {
+	shelterID, err := strconv.ParseUint(c.Params("shelter_id"), 10, 32)
+	if err != nil || !callerIsShelter(c, uint(shelterID)) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "You can only view your own shelter's analytics",
+			Data:    nil,
+		})
+	}
+
+	adoptedFrom := time.Time{}
+	adoptedTo := time.Now().AddDate(100, 0, 0)
+	if value := c.Query("adopted_from"); value != "" {
+		if adoptedFrom, err = time.Parse("2006-01-02", value); err != nil {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "400",
+				Message: "adopted_from must be YYYY-MM-DD",
+				Data:    nil,
+			})
+		}
+	}
+	if value := c.Query("adopted_to"); value != "" {
+		if adoptedTo, err = time.Parse("2006-01-02", value); err != nil {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "400",
+				Message: "adopted_to must be YYYY-MM-DD",
+				Data:    nil,
+			})
+		}
+		adoptedTo = adoptedTo.AddDate(0, 0, 1)
+	}
+
+	stats := []lengthOfStayStats{}
+	if err := middleware.DBConn.Raw(`SELECT pet_type,
+			COUNT(*) FILTER (WHERE in_care) AS in_care,
+			ROUND(AVG(days) FILTER (WHERE in_care), 1) AS avg_days_in_care,
+			COUNT(*) FILTER (WHERE adopted) AS adopted,
+			ROUND(AVG(days) FILTER (WHERE adopted), 1) AS avg_days_to_adoption,
+			PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY days) FILTER (WHERE adopted) AS median_days_to_adoption,
+			MAX(days) FILTER (WHERE in_care) AS longest_days_in_care
+		FROM (
+			SELECT LOWER(pet_type) AS pet_type,
+				COALESCE(left_shelter_at::date, CURRENT_DATE) - intake_date AS days,
+				left_shelter_at IS NULL AS in_care,
+				status = 'adopted' AND left_shelter_at >= ? AND left_shelter_at < ? AS adopted
+			FROM petinfo
+			WHERE shelter_id = ? AND intake_date IS NOT NULL
+		) stays
+		GROUP BY GROUPING SETS ((pet_type), ())
+		ORDER BY pet_type NULLS FIRST`, adoptedFrom, adoptedTo, shelterID).
+		Scan(&stats).Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to compute length of stay",
+			Data:    err.Error(),
+		})
+	}
+
+	longest := []models.PetInfo{}
+	if err := middleware.DBConn.
+		Where("shelter_id = ? AND intake_date IS NOT NULL AND left_shelter_at IS NULL", shelterID).
+		Order("intake_date, pet_id").Limit(10).Find(&longest).Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to fetch longest-staying pets",
+			Data:    err.Error(),
+		})
+	}
+	longestStays := make([]fiber.Map, 0, len(longest))
+	for _, pet := range longest {
+		longestStays = append(longestStays, fiber.Map{
+			"pet_id":              pet.PetID,
+			"pet_name":            pet.PetName,
+			"pet_type":            pet.PetType,
+			"status":              pet.Status,
+			"intake_date":         pet.IntakeDate,
+			"length_of_stay_days": pet.LengthOfStayDays,
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Length of stay fetched successfully",
+		Data: fiber.Map{
+			"shelter_id":    shelterID,
+			"stats":         stats,
+			"longest_stays": longestStays,
+		},
+	})
+}
@@ -0,0 +1,348 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"pethub_api/middleware"
+	"pethub_api/models"
+	"pethub_api/models/response"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// maxPetBreeds caps how many breeds a mixed pet can be listed with.
+const maxPetBreeds = 3
+
+var (
+	petCoats         = map[string]bool{"hairless": true, "short": true, "medium": true, "long": true, "wire": true, "curly": true}
+	petEnergyLevels  = map[string]bool{"low": true, "medium": true, "high": true}
+	microchipPattern = regexp.MustCompile(`^[0-9A-Za-z]{9,15}$`)
+)
+
+// formField returns a form value and whether it was sent at all, so an
+// update can tell "clear this" from "leave it alone".
+func formField(c *fiber.Ctx, key string) (string, bool) {
+	if form, err := c.MultipartForm(); err == nil {
+		if values, ok := form.Value[key]; ok && len(values) > 0 {
+			return strings.TrimSpace(values[0]), true
+		}
+		return "", false
+	}
+	args := c.Request().PostArgs()
+	if args.Has(key) {
+		return strings.TrimSpace(string(args.Peek(key))), true
+	}
+	return "", false
+}
+
+// petFields looks up an incoming pet field and reports whether it was sent.
+type petFields func(key string) (string, bool)
+
+// formFields reads pet fields from the request form.
+func formFields(c *fiber.Ctx) petFields {
+	return func(key string) (string, bool) {
+		return formField(c, key)
+	}
+}
+
+// parseTriState reads a yes/no answer where blank or "unknown" means not
+// known yet.
+func parseTriState(raw string) (*bool, error) {
+	switch strings.ToLower(raw) {
+	case "", "unknown":
+		return nil, nil
+	case "true", "yes", "1":
+		value := true
+		return &value, nil
+	case "false", "no", "0":
+		value := false
+		return &value, nil
+	}
+	return nil, fmt.Errorf("'%s' is not yes, no or unknown", raw)
+}
+
+// estimateBirthDate turns the old numeric age into an estimated birthdate.
+func estimateBirthDate(age int, ageType string, now time.Time) time.Time {
+	unit := strings.ToLower(ageType)
+	switch {
+	case strings.HasPrefix(unit, "week"):
+		return now.AddDate(0, 0, -7*age)
+	case strings.HasPrefix(unit, "month"):
+		return now.AddDate(0, -age, 0)
+	}
+	return now.AddDate(-age, 0, 0)
+}
+
+// readPetProfile applies the profile fields present in fields to pet and
+// returns them as column updates. A pet_age with age_type is still taken
+// when no birth_date is sent, as an estimated birthdate.
+func readPetProfile(fields petFields, pet *models.PetInfo) (map[string]interface{}, error) {
+	columns := map[string]interface{}{}
+
+	if value, ok := fields("color"); ok {
+		pet.Color = value
+		columns["color"] = value
+	}
+	if value, ok := fields("coat"); ok {
+		value = strings.ToLower(value)
+		if value != "" && !petCoats[value] {
+			return nil, errors.New("coat must be hairless, short, medium, long, wire or curly")
+		}
+		pet.Coat = value
+		columns["coat"] = value
+	}
+	if value, ok := fields("weight_kg"); ok {
+		weight := 0.0
+		if value != "" {
+			var err error
+			weight, err = strconv.ParseFloat(value, 64)
+			if err != nil || weight < 0 || weight > 200 {
+				return nil, errors.New("weight_kg must be a number between 0 and 200")
+			}
+		}
+		pet.WeightKg = weight
+		columns["weight_kg"] = weight
+	}
+
+	if value, ok := fields("birth_date"); ok {
+		pet.BirthDate = nil
+		if value != "" {
+			birthDate, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				return nil, errors.New("birth_date must be YYYY-MM-DD")
+			}
+			if birthDate.After(time.Now()) {
+				return nil, errors.New("birth_date cannot be in the future")
+			}
+			pet.BirthDate = &birthDate
+		}
+		columns["birth_date"] = pet.BirthDate
+		estimated, _ := fields("birth_date_estimated")
+		pet.BirthDateEstimated = estimated == "true" || estimated == "1"
+		columns["birth_date_estimated"] = pet.BirthDateEstimated
+	} else if value, ok := fields("pet_age"); ok {
+		age, err := strconv.Atoi(value)
+		if err != nil || age < 0 {
+			return nil, errors.New("Invalid pet age")
+		}
+		ageType, _ := fields("age_type")
+		birthDate := estimateBirthDate(age, ageType, time.Now())
+		pet.BirthDate = &birthDate
+		pet.BirthDateEstimated = true
+		columns["birth_date"] = pet.BirthDate
+		columns["birth_date_estimated"] = true
+	}
+
+	triStates := []struct {
+		key    string
+		target **bool
+	}{
+		{"spayed_neutered", &pet.SpayedNeutered},
+		{"house_trained", &pet.HouseTrained},
+		{"good_with_kids", &pet.GoodWithKids},
+		{"good_with_dogs", &pet.GoodWithDogs},
+		{"good_with_cats", &pet.GoodWithCats},
+	}
+	for _, field := range triStates {
+		value, ok := fields(field.key)
+		if !ok {
+			continue
+		}
+		parsed, err := parseTriState(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", field.key, err)
+		}
+		*field.target = parsed
+		columns[field.key] = parsed
+	}
+
+	if value, ok := fields("microchip_number"); ok {
+		value = strings.ReplaceAll(value, " ", "")
+		if value != "" && !microchipPattern.MatchString(value) {
+			return nil, errors.New("microchip_number must be 9 to 15 letters or digits")
+		}
+		pet.MicrochipNumber = value
+		columns["microchip_number"] = value
+	}
+	if value, ok := fields("energy_level"); ok {
+		value = strings.ToLower(value)
+		if value != "" && !petEnergyLevels[value] {
+			return nil, errors.New("energy_level must be low, medium or high")
+		}
+		pet.EnergyLevel = value
+		columns["energy_level"] = value
+	}
+	if value, ok := fields("special_needs"); ok {
+		pet.SpecialNeeds = value
+		columns["special_needs"] = value
+	}
+
+	if pet.BirthDate != nil {
+		pet.PetAge, pet.AgeType = models.PetAgeAt(*pet.BirthDate, time.Now())
+		columns["pet_age"] = pet.PetAge
+		columns["age_type"] = pet.AgeType
+	}
+	return columns, nil
+}
+
+// readPetBreeds resolves the comma-separated "breeds" field against
+// the reference list for species. ok is false when no breeds were sent.
+func readPetBreeds(fields petFields, db *gorm.DB, species string) ([]models.Breed, bool, error) {
+	raw, ok := fields("breeds")
+	if !ok {
+		return nil, false, nil
+	}
+	var names []string
+	for _, name := range strings.Split(raw, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	breeds := []models.Breed{}
+	if len(names) == 0 {
+		return breeds, true, nil
+	}
+	if len(names) > maxPetBreeds {
+		return nil, true, fmt.Errorf("A pet can have at most %d breeds", maxPetBreeds)
+	}
+
+	for _, name := range names {
+		var breed models.Breed
+		if err := db.Where("LOWER(species) = LOWER(?) AND LOWER(name) = LOWER(?)", species, name).
+			First(&breed).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, true, fmt.Errorf("'%s' is not a known %s breed", name, strings.ToLower(species))
+			}
+			return nil, true, err
+		}
+		breeds = append(breeds, breed)
+	}
+	return breeds, true, nil
+}
+
+// queryList splits a comma-separated filter into lower-case values.
+func queryList(raw string) []string {
+	var values []string
+	for _, value := range strings.Split(raw, ",") {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// applyPetProfileFilters narrows a petinfo query by the profile filters in
+// the query string: breed, color, coat, energy_level, the good_with_* and
+// other yes/no attributes, special_needs, min/max_age_years and
+// min/max_weight_kg.
+func applyPetProfileFilters(c *fiber.Ctx, query *gorm.DB) *gorm.DB {
+	if breed := c.Query("breed"); breed != "" {
+		query = query.Where(`EXISTS (SELECT 1 FROM pet_breeds
+			JOIN breeds ON breeds.breed_id = pet_breeds.breed_id
+			WHERE pet_breeds.pet_id = petinfo.pet_id AND LOWER(breeds.name) IN ?)`,
+			queryList(breed))
+	}
+	if color := c.Query("color"); color != "" {
+		query = query.Where("color ILIKE ?", "%"+color+"%")
+	}
+	if coat := c.Query("coat"); coat != "" {
+		query = query.Where("coat IN ?", queryList(coat))
+	}
+	if energy := c.Query("energy_level"); energy != "" {
+		query = query.Where("energy_level IN ?", queryList(energy))
+	}
+	for _, column := range []string{"good_with_kids", "good_with_dogs", "good_with_cats", "house_trained", "spayed_neutered"} {
+		if value, err := parseTriState(c.Query(column)); err == nil && value != nil {
+			query = query.Where(column+" = ?", *value)
+		}
+	}
+	if value, err := parseTriState(c.Query("special_needs")); err == nil && value != nil {
+		if *value {
+			query = query.Where("special_needs <> ''")
+		} else {
+			query = query.Where("(special_needs IS NULL OR special_needs = '')")
+		}
+	}
+
+	now := time.Now()
+	if minAge, err := strconv.Atoi(c.Query("min_age_years")); err == nil && minAge > 0 {
+		query = query.Where("birth_date <= ?", now.AddDate(-minAge, 0, 0))
+	}
+	if maxAge, err := strconv.Atoi(c.Query("max_age_years")); err == nil && maxAge >= 0 {
+		query = query.Where("birth_date > ?", now.AddDate(-(maxAge+1), 0, 0))
+	}
+	if minWeight, err := strconv.ParseFloat(c.Query("min_weight_kg"), 64); err == nil {
+		query = query.Where("weight_kg >= ?", minWeight)
+	}
+	if maxWeight, err := strconv.ParseFloat(c.Query("max_weight_kg"), 64); err == nil {
+		query = query.Where("weight_kg <= ?", maxWeight)
+	}
+	return query
+}
+
+// GetBreeds lists the breed reference list, optionally for one ?species=.
+func GetBreeds(c *fiber.Ctx) error {
+	query := middleware.DBConn.Order("species, name")
+	if species := c.Query("species"); species != "" {
+		query = query.Where("LOWER(species) = LOWER(?)", species)
+	}
+	breeds := []models.Breed{}
+	if err := query.Find(&breeds).Error; err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to fetch breeds",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Breeds fetched successfully",
+		Data:    breeds,
+	})
+}
+
+// AddBreed adds a breed to the reference list.
+func AddBreed(c *fiber.Ctx) error {
+	var body struct {
+		Species string `json:"species"`
+		Name    string `json:"name"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+	breed := models.Breed{
+		Species: strings.ToLower(strings.TrimSpace(body.Species)),
+		Name:    strings.TrimSpace(body.Name),
+	}
+	if breed.Species == "" || breed.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "species and name are required",
+		})
+	}
+
+	if err := middleware.DBConn.Create(&breed).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": "Breed already exists",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to add breed",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Breed added successfully",
+		"data":    breed,
+	})
+}
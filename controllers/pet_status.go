@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"pethub_api/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrPetStatusChange is returned, wrapped with the reason, for a pet status
+// change that the rules below do not allow.
+var ErrPetStatusChange = errors.New("Pet status change not allowed")
+
+// petTransitions lists, for each pet status, the statuses a pet can move
+// to from it. Every pet status change is checked against this table and
+// the preconditions in validatePetTransition, through setPetStatus.
+var petTransitions = map[models.PetStatus][]models.PetStatus{
+	models.PetAvailable:   {models.PetUnavailable, models.PetPending, models.PetAdopted, models.PetArchived},
+	models.PetUnavailable: {models.PetAvailable, models.PetPending, models.PetAdopted, models.PetArchived},
+	models.PetPending:     {models.PetAvailable, models.PetAdopted, models.PetArchived},
+	models.PetArchived:    {models.PetAvailable},
+	models.PetAdopted:     {models.PetAvailable},
+}
+
+// petOutStatuses are the statuses of a pet that is no longer in care; its
+// length of stay stops counting when it enters one.
+var petOutStatuses = map[models.PetStatus]bool{
+	models.PetAdopted:  true,
+	models.PetArchived: true,
+}
+
+// isPetStatus reports whether status is one of the known pet statuses.
+func isPetStatus(status models.PetStatus) bool {
+	_, ok := petTransitions[status]
+	return ok
+}
+
+// petStatusFacts is what the transition preconditions depend on. Only the
+// facts the target status needs are filled in by loadPetStatusFacts.
+type petStatusFacts struct {
+	ActiveInterview      bool // an interview is scheduled or rescheduled for the pet
+	InterviewApplication bool // an application for the pet is in the interview stage
+	ApprovedApplication  bool // an application for the pet is approved or completed
+	ReturnRecorded       bool // a return was recorded since the pet was adopted
+}
+
+// validatePetTransition checks that a pet may move from one status to
+// another, given the facts about it.
+func validatePetTransition(from, to models.PetStatus, facts petStatusFacts) error {
+	if !isPetStatus(to) {
+		return fmt.Errorf("%w: '%s' is not a pet status", ErrPetStatusChange, to)
+	}
+	allowed := false
+	for _, next := range petTransitions[from] {
+		if next == to {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("%w: a pet can't go from %s to %s", ErrPetStatusChange, from, to)
+	}
+
+	switch {
+	case to == models.PetArchived && facts.ActiveInterview:
+		return fmt.Errorf("%w: a pet with an active interview can't be archived", ErrPetStatusChange)
+	case to == models.PetPending && !facts.InterviewApplication:
+		return fmt.Errorf("%w: a pet can only be held as pending for an application in the interview stage", ErrPetStatusChange)
+	case to == models.PetAdopted && !facts.ApprovedApplication:
+		return fmt.Errorf("%w: a pet can only be adopted through an approved application", ErrPetStatusChange)
+	case from == models.PetAdopted && to == models.PetAvailable && !facts.ReturnRecorded:
+		return fmt.Errorf("%w: an adopted pet can only be made available again once its return is recorded", ErrPetStatusChange)
+	}
+	return nil
+}
+
+// petStatusRetCode is the RetCode for an error from setPetStatus: 409 when
+// the change broke the rules, 500 otherwise.
+func petStatusRetCode(err error) string {
+	if errors.Is(err, ErrPetStatusChange) {
+		return "409"
+	}
+	return "500"
+}
+
+// validatePetPriority checks that a pet with status may be prioritized.
+func validatePetPriority(status models.PetStatus) error {
+	if status != models.PetAvailable {
+		return fmt.Errorf("%w: only available pets can be prioritized", ErrPetStatusChange)
+	}
+	return nil
+}
+
+// loadPetStatusFacts reads the facts validatePetTransition needs for pet
+// to move to status.
+func loadPetStatusFacts(db *gorm.DB, pet models.PetInfo, status models.PetStatus) (petStatusFacts, error) {
+	var facts petStatusFacts
+	exists := func(query string, args ...interface{}) (bool, error) {
+		var found bool
+		err := db.Raw("SELECT EXISTS ("+query+")", args...).Scan(&found).Error
+		return found, err
+	}
+
+	var err error
+	switch status {
+	case models.PetArchived:
+		facts.ActiveInterview, err = exists(`SELECT 1 FROM schedule_interview i
+			JOIN adoption_submissions a ON a.application_id = i.application_id
+			WHERE a.pet_id = ? AND a.status IN ? AND i.interview_status IN ?`,
+			pet.PetID, activeApplicationStatuses, []string{"scheduled", "rescheduled"})
+	case models.PetPending:
+		facts.InterviewApplication, err = exists(`SELECT 1 FROM adoption_submissions
+			WHERE pet_id = ? AND status = ?`, pet.PetID, "interview")
+	case models.PetAdopted:
+		facts.ApprovedApplication, err = exists(`SELECT 1 FROM adoption_submissions
+			WHERE pet_id = ? AND status IN ?`, pet.PetID, []string{"approved", "completed"})
+	case models.PetAvailable:
+		if pet.Status != models.PetAdopted {
+			break
+		}
+		since := time.Time{}
+		if pet.LeftShelterAt != nil {
+			since = *pet.LeftShelterAt
+		}
+		facts.ReturnRecorded, err = exists(`SELECT 1 FROM pet_returns WHERE pet_id = ? AND created_at >= ?`,
+			pet.PetID, since)
+	}
+	return facts, err
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+
+	"pethub_api/models"
+)
+
+var allPetStatuses = []models.PetStatus{
+	models.PetAvailable,
+	models.PetUnavailable,
+	models.PetPending,
+	models.PetAdopted,
+	models.PetArchived,
+}
+
+// satisfied meets every precondition, so only the transition table decides.
+var satisfied = petStatusFacts{
+	InterviewApplication: true,
+	ApprovedApplication:  true,
+	ReturnRecorded:       true,
+}
+
+func TestPetTransitionTable(t *testing.T) {
+	allowed := map[[2]models.PetStatus]bool{
+		{models.PetAvailable, models.PetUnavailable}: true,
+		{models.PetAvailable, models.PetPending}:     true,
+		{models.PetAvailable, models.PetAdopted}:     true,
+		{models.PetAvailable, models.PetArchived}:    true,
+		{models.PetUnavailable, models.PetAvailable}: true,
+		{models.PetUnavailable, models.PetPending}:   true,
+		{models.PetUnavailable, models.PetAdopted}:   true,
+		{models.PetUnavailable, models.PetArchived}:  true,
+		{models.PetPending, models.PetAvailable}:     true,
+		{models.PetPending, models.PetAdopted}:       true,
+		{models.PetPending, models.PetArchived}:      true,
+		{models.PetArchived, models.PetAvailable}:    true,
+		{models.PetAdopted, models.PetAvailable}:     true,
+	}
+
+	for _, from := range allPetStatuses {
+		for _, to := range allPetStatuses {
+			if from == to {
+				continue
+			}
+			err := validatePetTransition(from, to, satisfied)
+			if allowed[[2]models.PetStatus{from, to}] {
+				if err != nil {
+					t.Errorf("%s -> %s: unexpected error %v", from, to, err)
+				}
+			} else if !errors.Is(err, ErrPetStatusChange) {
+				t.Errorf("%s -> %s: want ErrPetStatusChange, got %v", from, to, err)
+			}
+		}
+	}
+}
+
+func TestPetTransitionUnknownStatus(t *testing.T) {
+	if err := validatePetTransition(models.PetAvailable, "sold", satisfied); !errors.Is(err, ErrPetStatusChange) {
+		t.Fatalf("want ErrPetStatusChange for an unknown status, got %v", err)
+	}
+	if err := validatePetTransition("", models.PetAvailable, satisfied); !errors.Is(err, ErrPetStatusChange) {
+		t.Fatalf("want ErrPetStatusChange from an unknown status, got %v", err)
+	}
+}
+
+func TestPetTransitionPreconditions(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    models.PetStatus
+		to      models.PetStatus
+		facts   petStatusFacts
+		wantErr bool
+	}{
+		{"archive with active interview", models.PetPending, models.PetArchived, petStatusFacts{ActiveInterview: true}, true},
+		{"archive without active interview", models.PetPending, models.PetArchived, petStatusFacts{}, false},
+		{"hold without interview application", models.PetAvailable, models.PetPending, petStatusFacts{}, true},
+		{"hold for interview application", models.PetAvailable, models.PetPending, petStatusFacts{InterviewApplication: true}, false},
+		{"adopt without approved application", models.PetPending, models.PetAdopted, petStatusFacts{}, true},
+		{"adopt through approved application", models.PetPending, models.PetAdopted, petStatusFacts{ApprovedApplication: true}, false},
+		{"make adopted pet available without return", models.PetAdopted, models.PetAvailable, petStatusFacts{}, true},
+		{"make adopted pet available after return", models.PetAdopted, models.PetAvailable, petStatusFacts{ReturnRecorded: true}, false},
+		{"unarchive needs no return", models.PetArchived, models.PetAvailable, petStatusFacts{}, false},
+		{"release hold", models.PetPending, models.PetAvailable, petStatusFacts{}, false},
+		{"application cap", models.PetAvailable, models.PetUnavailable, petStatusFacts{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePetTransition(tt.from, tt.to, tt.facts)
+			if tt.wantErr && !errors.Is(err, ErrPetStatusChange) {
+				t.Fatalf("want ErrPetStatusChange, got %v", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestPetPriority(t *testing.T) {
+	for _, status := range allPetStatuses {
+		err := validatePetPriority(status)
+		if status == models.PetAvailable && err != nil {
+			t.Errorf("%s: unexpected error %v", status, err)
+		}
+		if status != models.PetAvailable && !errors.Is(err, ErrPetStatusChange) {
+			t.Errorf("%s: want ErrPetStatusChange, got %v", status, err)
+		}
+	}
+}
+
+func TestPetStatusRetCode(t *testing.T) {
+	if code := petStatusRetCode(validatePetPriority(models.PetArchived)); code != "409" {
+		t.Errorf("rule violation: want 409, got %s", code)
+	}
+	if code := petStatusRetCode(errors.New("connection reset")); code != "500" {
+		t.Errorf("other error: want 500, got %s", code)
+	}
+}
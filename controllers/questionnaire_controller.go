@@ -0,0 +1,346 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"pethub_api/middleware"
+	"pethub_api/models"
+	"pethub_api/models/response"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var questionTypes = map[string]bool{
+	"text":   true,
+	"choice": true,
+	"yes_no": true,
+	"number": true,
+	"file":   true,
+}
+
+// PublishQuestionnaire saves a new version of a shelter's questionnaire for a
+// pet type and deactivates the version it replaces.
+func PublishQuestionnaire(c *fiber.Ctx) error {
+	shelterID, err := strconv.ParseUint(c.Params("shelter_id"), 10, 32)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid shelter ID",
+			Data:    nil,
+		})
+	}
+	if !callerIsShelter(c, uint(shelterID)) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "You can only publish your own shelter's questionnaires",
+			Data:    nil,
+		})
+	}
+
+	var body struct {
+		PetType   string `json:"pet_type"`
+		Title     string `json:"title"`
+		Questions []struct {
+			Label        string   `json:"label"`
+			QuestionType string   `json:"question_type"`
+			Options      []string `json:"options"`
+			Required     bool     `json:"required"`
+		} `json:"questions"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		})
+	}
+
+	if len(body.Questions) == 0 {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "At least one question is required",
+			Data:    nil,
+		})
+	}
+
+	questions := make([]models.QuestionnaireQuestion, 0, len(body.Questions))
+	for i, q := range body.Questions {
+		if strings.TrimSpace(q.Label) == "" {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "400",
+				Message: fmt.Sprintf("Question %d has no label", i+1),
+				Data:    nil,
+			})
+		}
+		if !questionTypes[q.QuestionType] {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "400",
+				Message: fmt.Sprintf("Question %d has an invalid type '%s'", i+1, q.QuestionType),
+				Data:    nil,
+			})
+		}
+		if q.QuestionType == "choice" && len(q.Options) == 0 {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "400",
+				Message: fmt.Sprintf("Question %d needs at least one option", i+1),
+				Data:    nil,
+			})
+		}
+		questions = append(questions, models.QuestionnaireQuestion{
+			Position:     i + 1,
+			Label:        q.Label,
+			QuestionType: q.QuestionType,
+			Options:      q.Options,
+			Required:     q.Required,
+		})
+	}
+
+	tx := middleware.DBConn.Begin()
+
+	var latest models.Questionnaire
+	err = tx.Where("shelter_id = ? AND pet_type = ?", shelterID, body.PetType).
+		Order("version DESC").
+		First(&latest).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		tx.Rollback()
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching questionnaire",
+			Data:    err.Error(),
+		})
+	}
+
+	// Older versions stay in place so existing answers keep their questions
+	if err := tx.Model(&models.Questionnaire{}).
+		Where("shelter_id = ? AND pet_type = ? AND is_active = ?", shelterID, body.PetType, true).
+		Update("is_active", false).Error; err != nil {
+		tx.Rollback()
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to deactivate previous questionnaire",
+			Data:    err.Error(),
+		})
+	}
+
+	questionnaire := models.Questionnaire{
+		ShelterID: uint(shelterID),
+		PetType:   body.PetType,
+		Title:     body.Title,
+		Version:   latest.Version + 1,
+		IsActive:  true,
+		CreatedAt: time.Now(),
+		Questions: questions,
+	}
+	if err := tx.Create(&questionnaire).Error; err != nil {
+		tx.Rollback()
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to save questionnaire",
+			Data:    err.Error(),
+		})
+	}
+
+	tx.Commit()
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Questionnaire published successfully",
+		Data:    questionnaire,
+	})
+}
+
+// GetShelterQuestionnaires lists a shelter's active questionnaires, or every
+// version when ?all=true.
+func GetShelterQuestionnaires(c *fiber.Ctx) error {
+	shelterID := c.Params("shelter_id")
+
+	query := middleware.DBConn.Preload("Questions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).Where("shelter_id = ?", shelterID)
+
+	if c.Query("all") != "true" {
+		query = query.Where("is_active = ?", true)
+	}
+
+	questionnaires := []models.Questionnaire{}
+	if err := query.Order("pet_type").Order("version DESC").Find(&questionnaires).Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching questionnaires",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data:    questionnaires,
+	})
+}
+
+// GetActiveQuestionnaire returns the form an adopter has to fill in to apply
+// for a pet.
+func GetActiveQuestionnaire(c *fiber.Ctx) error {
+	var pet models.PetInfo
+	if err := middleware.DBConn.Where("pet_id = ?", c.Params("pet_id")).First(&pet).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(response.AdopterResponseModel{
+				RetCode: "404",
+				Message: "Pet not found",
+				Data:    nil,
+			})
+		}
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "500",
+			Message: "Database error",
+			Data:    nil,
+		})
+	}
+
+	questionnaire, err := findActiveQuestionnaire(middleware.DBConn, pet.ShelterID, pet.PetType)
+	if err != nil {
+		return c.JSON(response.AdopterResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching questionnaire",
+			Data:    nil,
+		})
+	}
+
+	return c.JSON(response.AdopterResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data:    questionnaire,
+	})
+}
+
+// DeactivateQuestionnaire retires a questionnaire without publishing a
+// replacement; applications then fall back to the shelter's generic form.
+func DeactivateQuestionnaire(c *fiber.Ctx) error {
+	shelterID, err := strconv.ParseUint(c.Params("shelter_id"), 10, 32)
+	if err != nil || !callerIsShelter(c, uint(shelterID)) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "You can only retire your own shelter's questionnaires",
+			Data:    nil,
+		})
+	}
+
+	result := middleware.DBConn.Model(&models.Questionnaire{}).
+		Where("questionnaire_id = ? AND shelter_id = ?", c.Params("questionnaire_id"), shelterID).
+		Update("is_active", false)
+
+	if result.Error != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to deactivate questionnaire",
+			Data:    result.Error.Error(),
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "404",
+			Message: "Questionnaire not found",
+			Data:    nil,
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Questionnaire deactivated",
+		Data:    nil,
+	})
+}
+
+// findActiveQuestionnaire prefers a form specific to the pet type and falls
+// back to the shelter's generic one. It returns nil when neither exists.
+func findActiveQuestionnaire(db *gorm.DB, shelterID uint, petType string) (*models.Questionnaire, error) {
+	var questionnaire models.Questionnaire
+	err := db.Preload("Questions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).
+		Where("shelter_id = ? AND is_active = ? AND pet_type IN ?", shelterID, true, []string{petType, ""}).
+		Order("pet_type DESC").
+		First(&questionnaire).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &questionnaire, nil
+}
+
+// collectAnswers reads one "question_<id>" form field (or file) per question
+// and validates it against the question's type and required flag.
+func collectAnswers(c *fiber.Ctx, questionnaire *models.Questionnaire) ([]models.ApplicationAnswer, error) {
+	answers := []models.ApplicationAnswer{}
+
+	for _, q := range questionnaire.Questions {
+		field := fmt.Sprintf("question_%d", q.QuestionID)
+		value := strings.TrimSpace(c.FormValue(field))
+
+		if q.QuestionType == "file" {
+			if file, err := c.FormFile(field); err == nil {
+				f, err := file.Open()
+				if err != nil {
+					return nil, fmt.Errorf("failed to open file for '%s'", q.Label)
+				}
+				data, err := io.ReadAll(f)
+				f.Close()
+				if err != nil {
+					return nil, fmt.Errorf("failed to read file for '%s'", q.Label)
+				}
+				value = base64.StdEncoding.EncodeToString(data)
+			}
+		}
+
+		if value == "" {
+			if q.Required {
+				return nil, fmt.Errorf("'%s' is required", q.Label)
+			}
+			continue
+		}
+
+		switch q.QuestionType {
+		case "number":
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return nil, fmt.Errorf("'%s' must be a number", q.Label)
+			}
+		case "yes_no":
+			switch strings.ToLower(value) {
+			case "yes", "true":
+				value = "yes"
+			case "no", "false":
+				value = "no"
+			default:
+				return nil, fmt.Errorf("'%s' must be yes or no", q.Label)
+			}
+		case "choice":
+			valid := false
+			for _, option := range q.Options {
+				if option == value {
+					valid = true
+					break
+				}
+			}
+			if !valid {
+				return nil, fmt.Errorf("'%s' must be one of: %s", q.Label, strings.Join(q.Options, ", "))
+			}
+		}
+
+		answers = append(answers, models.ApplicationAnswer{
+			QuestionID: q.QuestionID,
+			Value:      value,
+		})
+	}
+
+	return answers, nil
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"pethub_api/middleware"
+	"pethub_api/models/response"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// streamHeartbeat keeps idle streams from being closed by proxies.
+const streamHeartbeat = 25 * time.Second
+
+// pushApplicationStatus tells both sides of an application that its status
+// changed, once the caller's transaction commits. Clients treat the push as a
+// cue to refetch rather than as the record.
+func pushApplicationStatus(db *gorm.DB, event middleware.DomainEvent) error {
+	e := event.(ApplicationEvent)
+	data := fiber.Map{
+		"application_id": e.ApplicationID,
+		"pet_id":         e.PetID,
+		"old_status":     e.OldStatus,
+		"new_status":     e.NewStatus,
+	}
+	middleware.Realtime.PublishAfterCommit(db, middleware.RealtimeChannel(RecipientAdopter, e.AdopterID), "application_status", data)
+	middleware.Realtime.PublishAfterCommit(db, middleware.RealtimeChannel(RecipientShelter, e.ShelterID), "application_status", data)
+	return nil
+}
+
+// writeStreamEvent writes one server-sent event.
+func writeStreamEvent(w *bufio.Writer, event string, data []byte) error {
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// StreamEvents is a server-sent events stream of new notifications, unread
+// counts and application status changes for the caller. Shelter tokens open
+// the shelter's stream; browsers that cannot set headers on EventSource may
+// pass the token as ?access_token=.
+func StreamEvents(c *fiber.Ctx) error {
+	callerID, err := middleware.GetAdopterIDFromJWT(c)
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "401",
+			Message: "Unauthorized",
+			Data:    nil,
+		})
+	}
+	role := middleware.GetRoleFromJWT(c)
+	if role != RecipientAdopter && role != RecipientShelter {
+		return c.JSON(response.ResponseModel{
+			RetCode: "403",
+			Message: "Only adopters and shelters have an event stream",
+			Data:    nil,
+		})
+	}
+
+	// Subscribe before reading the count so nothing slips in between
+	channel := middleware.RealtimeChannel(role, callerID)
+	messages, unsubscribe := middleware.Realtime.Subscribe(channel)
+
+	count, err := unreadNotificationCount(middleware.DBConn, role, callerID)
+	if err != nil {
+		unsubscribe()
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to count unread notifications",
+			Data:    err.Error(),
+		})
+	}
+	readyData, _ := json.Marshal(fiber.Map{"channel": channel, "unread_count": count})
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+		if err := writeStreamEvent(w, "ready", readyData); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case payload := <-messages:
+				var message middleware.RealtimeMessage
+				if err := json.Unmarshal(payload, &message); err != nil {
+					continue
+				}
+				if err := writeStreamEvent(w, message.Type, message.Data); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				// A failed write means the client has gone
+				if _, err := w.WriteString(": ping\n\n"); err != nil {
+					return
+				}
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+		}
+	})
+	return nil
+}
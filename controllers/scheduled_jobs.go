@@ -0,0 +1,318 @@
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"pethub_api/middleware"
+	"pethub_api/models"
+	"pethub_api/models/response"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultPendingSLAHours applies to shelters that have not set their own.
+const defaultPendingSLAHours = 72
+
+// RegisterScheduledJobs registers this package's background jobs. Each runs
+// on one instance at a time; see middleware.StartScheduler.
+func RegisterScheduledJobs() {
+	middleware.ScheduleJob("outbox", 15*time.Second, middleware.DispatchOutbox)
+	middleware.ScheduleJob("notification_digests", time.Minute, sendDailyDigests)
+	middleware.ScheduleJob("interview_reminders", time.Minute, sendInterviewReminders)
+	middleware.ScheduleJob("pending_application_nudges", 15*time.Minute, nudgeOverdueApplications)
+	middleware.ScheduleJob("expire_applications", time.Hour, expireAbandonedApplications)
+	middleware.ScheduleJob("medical_overdue_alerts", time.Hour, alertOverdueMedicalRecords)
+}
+
+// interviewReminders are sent this long before an interview. A reminder is
+// skipped once the next one is due, so an interview booked for the same
+// afternoon only gets the 1h reminder.
+var interviewReminders = []struct {
+	kind  string
+	lead  time.Duration
+	until time.Duration
+}{
+	{"24h", 24 * time.Hour, time.Hour},
+	{"1h", time.Hour, 0},
+}
+
+// sendInterviewReminders reminds both parties of upcoming interviews.
+func sendInterviewReminders(db *gorm.DB) error {
+	now := time.Now()
+	for _, r := range interviewReminders {
+		var interviews []models.ScheduleInterview
+		if err := db.Where("interview_status IN ? AND interview_at > ? AND interview_at <= ?",
+			[]string{"scheduled", "rescheduled"}, now.Add(r.until), now.Add(r.lead)).
+			Find(&interviews).Error; err != nil {
+			return err
+		}
+
+		for _, interview := range interviews {
+			tx := db.Begin()
+			reminder := models.InterviewReminder{
+				InterviewID: interview.InterviewID,
+				Kind:        r.kind,
+				InterviewAt: interview.InterviewAt,
+				SentAt:      now,
+			}
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&reminder)
+			if result.Error != nil {
+				tx.Rollback()
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				tx.Rollback()
+				continue
+			}
+			if err := middleware.PublishEvent(tx, InterviewReminderEvent{Interview: interview, Kind: r.kind}); err != nil {
+				tx.Rollback()
+				return err
+			}
+			if err := tx.Commit().Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// pendingSLAHours is APPLICATION_SLA_HOURS, or 72 if unset.
+func pendingSLAHours() int {
+	if hours, err := strconv.Atoi(middleware.GetEnv("APPLICATION_SLA_HOURS")); err == nil && hours > 0 {
+		return hours
+	}
+	return defaultPendingSLAHours
+}
+
+// nudgeOverdueApplications tells each shelter about applications that have
+// been pending longer than its SLA, then again every SLA period after that.
+func nudgeOverdueApplications(db *gorm.DB) error {
+	var overdue []struct {
+		ApplicationID uint
+		ShelterID     uint
+		SLAHours      int
+	}
+	if err := db.Raw(`SELECT a.application_id, a.shelter_id, COALESCE(NULLIF(s.pending_sla_hours, 0), ?) AS sla_hours
+		FROM adoption_submissions a
+		JOIN shelterinfo s ON s.shelter_id = a.shelter_id
+		WHERE a.status = 'pending'
+		AND a.created_at < NOW() - make_interval(hours => COALESCE(NULLIF(s.pending_sla_hours, 0), ?))
+		AND (a.sla_nudged_at IS NULL
+			OR a.sla_nudged_at < NOW() - make_interval(hours => COALESCE(NULLIF(s.pending_sla_hours, 0), ?)))
+		ORDER BY a.shelter_id, a.created_at`,
+		pendingSLAHours(), pendingSLAHours(), pendingSLAHours()).Scan(&overdue).Error; err != nil {
+		return err
+	}
+
+	byShelter := make(map[uint][]uint)
+	slaHours := make(map[uint]int)
+	var shelters []uint
+	for _, app := range overdue {
+		if _, seen := byShelter[app.ShelterID]; !seen {
+			shelters = append(shelters, app.ShelterID)
+		}
+		byShelter[app.ShelterID] = append(byShelter[app.ShelterID], app.ApplicationID)
+		slaHours[app.ShelterID] = app.SLAHours
+	}
+
+	for _, shelterID := range shelters {
+		tx := db.Begin()
+		// UpdateColumn leaves updated_at alone; a nudge is not progress
+		if err := tx.Model(&models.AdoptionSubmission{}).
+			Where("application_id IN ?", byShelter[shelterID]).
+			UpdateColumn("sla_nudged_at", time.Now()).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := middleware.PublishEvent(tx, ApplicationsOverdueEvent{
+			ShelterID:      shelterID,
+			ApplicationIDs: byShelter[shelterID],
+			SLAHours:       slaHours[shelterID],
+		}); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit().Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expireAbandonedApplications expires applications that made no progress
+// for as long as the shelter's policy allows: pending applications, and
+// applications in the interview stage with no interview booked.
+func expireAbandonedApplications(db *gorm.DB) error {
+	var stale []struct {
+		models.AdoptionSubmission
+		ExpireAfterDays int
+	}
+	if err := db.Raw(`SELECT a.*, s.expire_applications_after_days AS expire_after_days
+		FROM adoption_submissions a
+		JOIN shelterinfo s ON s.shelter_id = a.shelter_id
+		WHERE s.expire_applications_after_days > 0
+		AND a.updated_at < NOW() - make_interval(days => s.expire_applications_after_days)
+		AND (a.status = 'pending'
+			OR (a.status = 'interview' AND NOT EXISTS (
+				SELECT 1 FROM schedule_interview i
+				WHERE i.application_id = a.application_id
+				AND i.interview_status IN ('scheduled', 'rescheduled', 'completed'))))`).
+		Scan(&stale).Error; err != nil {
+		return err
+	}
+
+	for _, row := range stale {
+		application := row.AdoptionSubmission
+		oldStatus := application.Status
+
+		tx := db.Begin()
+		application.Status = "expired"
+		application.ReasonForRejection = fmt.Sprintf("No progress for %d days", row.ExpireAfterDays)
+		// Only if nobody moved the application on since it was read
+		result := tx.Model(&application).Where("status = ?", oldStatus).Updates(map[string]interface{}{
+			"status":               application.Status,
+			"reason_for_rejection": application.ReasonForRejection,
+		})
+		if result.Error != nil {
+			tx.Rollback()
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			tx.Rollback()
+			continue
+		}
+		if oldStatus == "interview" {
+			if err := releasePetHold(tx, application); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		if err := publishApplicationStatus(tx, application, oldStatus); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit().Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releasePetHold undoes advanceToInterview when the application in the
+// interview stage drops out: the slot is freed, the pet is available again
+// and queued applications go back to pending.
+func releasePetHold(db *gorm.DB, application models.AdoptionSubmission) error {
+	if err := releaseInterviewSlot(db, application.ApplicationID); err != nil {
+		return err
+	}
+	var pet models.PetInfo
+	if err := db.Where("pet_id = ?", application.PetID).First(&pet).Error; err != nil {
+		return err
+	}
+	if pet.Status == models.PetPending {
+		change := bySystem("Application in interview " + application.Status).forApplication(application.ApplicationID)
+		if err := setPetStatus(db, &pet, models.PetAvailable, change); err != nil {
+			return err
+		}
+	}
+
+	var queued []models.AdoptionSubmission
+	if err := db.Where("pet_id = ? AND application_id != ? AND status = ?",
+		application.PetID, application.ApplicationID, "in queue").
+		Find(&queued).Error; err != nil {
+		return err
+	}
+	for _, app := range queued {
+		app.Status = "pending"
+		if err := db.Model(&app).Update("status", app.Status).Error; err != nil {
+			return err
+		}
+		if err := publishApplicationStatus(db, app, "in queue"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateApplicationPolicy sets how long pending applications may wait
+// before the shelter is nudged and after how many idle days applications
+// expire. 0 means the default SLA and never expiring respectively.
+func UpdateApplicationPolicy(c *fiber.Ctx) error {
+	shelterID, err := strconv.ParseUint(c.Params("shelter_id"), 10, 32)
+	if err != nil || !callerIsShelter(c, uint(shelterID)) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "You can only change your own shelter's application policy",
+			Data:    nil,
+		})
+	}
+
+	var body struct {
+		PendingSLAHours             *int `json:"pending_sla_hours"`
+		ExpireApplicationsAfterDays *int `json:"expire_applications_after_days"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		})
+	}
+
+	updates := map[string]interface{}{}
+	if body.PendingSLAHours != nil {
+		if *body.PendingSLAHours < 0 {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "400",
+				Message: "pending_sla_hours cannot be negative",
+				Data:    nil,
+			})
+		}
+		updates["pending_sla_hours"] = *body.PendingSLAHours
+	}
+	if body.ExpireApplicationsAfterDays != nil {
+		if *body.ExpireApplicationsAfterDays < 0 {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "400",
+				Message: "expire_applications_after_days cannot be negative",
+				Data:    nil,
+			})
+		}
+		updates["expire_applications_after_days"] = *body.ExpireApplicationsAfterDays
+	}
+	if len(updates) == 0 {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Nothing to update",
+			Data:    nil,
+		})
+	}
+
+	result := middleware.DBConn.Model(&models.ShelterInfo{}).
+		Where("shelter_id = ?", shelterID).
+		Updates(updates)
+	if result.Error != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to update application policy",
+			Data:    result.Error.Error(),
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "404",
+			Message: "Shelter not found",
+			Data:    nil,
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Application policy updated",
+		Data:    updates,
+	})
+}
@@ -0,0 +1,525 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"pethub_api/middleware"
+	"pethub_api/models"
+	"pethub_api/models/response"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var screeningOperators = map[string]bool{
+	"equals":       true,
+	"not_equals":   true,
+	"contains":     true,
+	"not_contains": true,
+	"gte":          true,
+	"lte":          true,
+	"is_true":      true,
+	"is_false":     true,
+	"not_empty":    true,
+}
+
+var screeningProfileFields = map[string]bool{
+	"housing_type":              true,
+	"other_pets":                true,
+	"allergies":                 true,
+	"experience":                true,
+	"family_support":            true,
+	"verified_email":            true,
+	"prior_completed_adoptions": true,
+}
+
+// RuleResult explains how one screening rule contributed to a score.
+type RuleResult struct {
+	RuleID  uint    `json:"rule_id"`
+	Name    string  `json:"name"`
+	Field   string  `json:"field"`
+	Matched bool    `json:"matched"`
+	Points  float64 `json:"points"`
+}
+
+// ScreeningScore is the total score of an application and its breakdown.
+type ScreeningScore struct {
+	Score     float64      `json:"score"`
+	MaxScore  float64      `json:"max_score"`
+	Breakdown []RuleResult `json:"breakdown"`
+}
+
+func validateScreeningRule(rule models.ScreeningRule) error {
+	if strings.TrimSpace(rule.Name) == "" {
+		return errors.New("rule name is required")
+	}
+	if !screeningOperators[rule.Operator] {
+		return fmt.Errorf("invalid operator '%s'", rule.Operator)
+	}
+	if strings.HasPrefix(rule.Field, "question:") {
+		if _, err := strconv.ParseUint(strings.TrimPrefix(rule.Field, "question:"), 10, 32); err != nil {
+			return fmt.Errorf("invalid question field '%s'", rule.Field)
+		}
+	} else if !screeningProfileFields[rule.Field] {
+		return fmt.Errorf("invalid field '%s'", rule.Field)
+	}
+	if (rule.Operator == "gte" || rule.Operator == "lte") && rule.Value != "" {
+		if _, err := strconv.ParseFloat(rule.Value, 64); err != nil {
+			return fmt.Errorf("operator '%s' needs a numeric value", rule.Operator)
+		}
+	}
+	return nil
+}
+
+func CreateScreeningRule(c *fiber.Ctx) error {
+	shelterID, err := strconv.ParseUint(c.Params("shelter_id"), 10, 32)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid shelter ID",
+			Data:    nil,
+		})
+	}
+	if !callerIsShelter(c, uint(shelterID)) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "You can only change your own shelter's screening rules",
+			Data:    nil,
+		})
+	}
+
+	var rule models.ScreeningRule
+	if err := c.BodyParser(&rule); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		})
+	}
+	if err := validateScreeningRule(rule); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	rule.RuleID = 0
+	rule.ShelterID = uint(shelterID)
+	rule.IsActive = true
+	rule.CreatedAt = time.Now()
+
+	if err := middleware.DBConn.Create(&rule).Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to save screening rule",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Screening rule created",
+		Data:    rule,
+	})
+}
+
+func GetScreeningRules(c *fiber.Ctx) error {
+	rules := []models.ScreeningRule{}
+	if err := middleware.DBConn.Where("shelter_id = ?", c.Params("shelter_id")).
+		Order("created_at").
+		Find(&rules).Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching screening rules",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data:    rules,
+	})
+}
+
+func UpdateScreeningRule(c *fiber.Ctx) error {
+	shelterID, err := strconv.ParseUint(c.Params("shelter_id"), 10, 32)
+	if err != nil || !callerIsShelter(c, uint(shelterID)) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "You can only change your own shelter's screening rules",
+			Data:    nil,
+		})
+	}
+
+	var rule models.ScreeningRule
+	result := middleware.DBConn.Where("rule_id = ? AND shelter_id = ?", c.Params("rule_id"), shelterID).First(&rule)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "404",
+			Message: "Screening rule not found",
+			Data:    nil,
+		})
+	} else if result.Error != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Database error while fetching screening rule",
+			Data:    result.Error.Error(),
+		})
+	}
+
+	var body struct {
+		Name     *string  `json:"name"`
+		Field    *string  `json:"field"`
+		Operator *string  `json:"operator"`
+		Value    *string  `json:"value"`
+		Weight   *float64 `json:"weight"`
+		IsActive *bool    `json:"is_active"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		})
+	}
+
+	if body.Name != nil {
+		rule.Name = *body.Name
+	}
+	if body.Field != nil {
+		rule.Field = *body.Field
+	}
+	if body.Operator != nil {
+		rule.Operator = *body.Operator
+	}
+	if body.Value != nil {
+		rule.Value = *body.Value
+	}
+	if body.Weight != nil {
+		rule.Weight = *body.Weight
+	}
+	if body.IsActive != nil {
+		rule.IsActive = *body.IsActive
+	}
+
+	if err := validateScreeningRule(rule); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	if err := middleware.DBConn.Model(&rule).Updates(map[string]interface{}{
+		"name":      rule.Name,
+		"field":     rule.Field,
+		"operator":  rule.Operator,
+		"value":     rule.Value,
+		"weight":    rule.Weight,
+		"is_active": rule.IsActive,
+	}).Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to update screening rule",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Screening rule updated",
+		Data:    rule,
+	})
+}
+
+func DeleteScreeningRule(c *fiber.Ctx) error {
+	shelterID, err := strconv.ParseUint(c.Params("shelter_id"), 10, 32)
+	if err != nil || !callerIsShelter(c, uint(shelterID)) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "You can only change your own shelter's screening rules",
+			Data:    nil,
+		})
+	}
+
+	result := middleware.DBConn.Where("rule_id = ? AND shelter_id = ?", c.Params("rule_id"), shelterID).
+		Delete(&models.ScreeningRule{})
+	if result.Error != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to delete screening rule",
+			Data:    result.Error.Error(),
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "404",
+			Message: "Screening rule not found",
+			Data:    nil,
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Screening rule deleted",
+		Data:    nil,
+	})
+}
+
+// screeningFacts flattens an application into the values screening rules can
+// refer to, using the answers loaded on the application.
+func screeningFacts(app models.AdoptionSubmission, verifiedEmail bool, completedAdoptions int64) map[string]string {
+	facts := map[string]string{
+		"housing_type":              app.HousingSituation,
+		"other_pets":                app.PetsAtHome,
+		"allergies":                 app.Allergies,
+		"experience":                app.PastPets,
+		"family_support":            app.FamilySupport,
+		"verified_email":            strconv.FormatBool(verifiedEmail),
+		"prior_completed_adoptions": strconv.FormatInt(completedAdoptions, 10),
+	}
+	for _, answer := range app.Answers {
+		facts[fmt.Sprintf("question:%d", answer.QuestionID)] = answer.Value
+	}
+	return facts
+}
+
+func isTruthy(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "yes", "true", "1", "y":
+		return true
+	}
+	return false
+}
+
+func ruleMatches(rule models.ScreeningRule, fact string) bool {
+	fact = strings.TrimSpace(fact)
+	switch rule.Operator {
+	case "equals":
+		return strings.EqualFold(fact, rule.Value)
+	case "not_equals":
+		return !strings.EqualFold(fact, rule.Value)
+	case "contains":
+		return strings.Contains(strings.ToLower(fact), strings.ToLower(rule.Value))
+	case "not_contains":
+		return !strings.Contains(strings.ToLower(fact), strings.ToLower(rule.Value))
+	case "gte", "lte":
+		got, err := strconv.ParseFloat(fact, 64)
+		if err != nil {
+			return false
+		}
+		want, _ := strconv.ParseFloat(rule.Value, 64)
+		if rule.Operator == "gte" {
+			return got >= want
+		}
+		return got <= want
+	case "is_true":
+		return isTruthy(fact)
+	case "is_false":
+		return fact != "" && !isTruthy(fact)
+	case "not_empty":
+		return fact != ""
+	}
+	return false
+}
+
+// scoreApplication applies every active rule to the facts. Negative weights
+// act as penalties and are left out of MaxScore.
+func scoreApplication(rules []models.ScreeningRule, facts map[string]string) ScreeningScore {
+	score := ScreeningScore{Breakdown: []RuleResult{}}
+	for _, rule := range rules {
+		if !rule.IsActive {
+			continue
+		}
+		matched := ruleMatches(rule, facts[rule.Field])
+		points := 0.0
+		if matched {
+			points = rule.Weight
+		}
+		if rule.Weight > 0 {
+			score.MaxScore += rule.Weight
+		}
+		score.Score += points
+		score.Breakdown = append(score.Breakdown, RuleResult{
+			RuleID:  rule.RuleID,
+			Name:    rule.Name,
+			Field:   rule.Field,
+			Matched: matched,
+			Points:  points,
+		})
+	}
+	return score
+}
+
+// scoreApplications scores a batch of applications belonging to one shelter,
+// keyed by application ID. Questionnaire answers are loaded here, so callers
+// need not preload them.
+func scoreApplications(db *gorm.DB, shelterID uint, applications []models.AdoptionSubmission) (map[uint]ScreeningScore, error) {
+	scores := make(map[uint]ScreeningScore, len(applications))
+	if len(applications) == 0 {
+		return scores, nil
+	}
+
+	var rules []models.ScreeningRule
+	if err := db.Where("shelter_id = ? AND is_active = ?", shelterID, true).Find(&rules).Error; err != nil {
+		return nil, err
+	}
+
+	adopterIDs := make([]uint, 0, len(applications))
+	applicationIDs := make([]uint, 0, len(applications))
+	for _, app := range applications {
+		adopterIDs = append(adopterIDs, app.AdopterID)
+		applicationIDs = append(applicationIDs, app.ApplicationID)
+	}
+
+	var answers []models.ApplicationAnswer
+	if err := db.Where("application_id IN ?", applicationIDs).Find(&answers).Error; err != nil {
+		return nil, err
+	}
+	answersByApplication := make(map[uint][]models.ApplicationAnswer, len(applications))
+	for _, answer := range answers {
+		answersByApplication[answer.ApplicationID] = append(answersByApplication[answer.ApplicationID], answer)
+	}
+
+	var completed []struct {
+		AdopterID uint
+		Total     int64
+	}
+	if err := db.Model(&models.AdoptionSubmission{}).
+		Select("adopter_id, count(*) AS total").
+		Where("adopter_id IN ? AND status = ?", adopterIDs, "completed").
+		Group("adopter_id").
+		Scan(&completed).Error; err != nil {
+		return nil, err
+	}
+	completedByAdopter := make(map[uint]int64, len(completed))
+	for _, row := range completed {
+		completedByAdopter[row.AdopterID] = row.Total
+	}
+
+	var accounts []models.AdopterAccount
+	if err := db.Select("adopter_id, email_verified").Where("adopter_id IN ?", adopterIDs).Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+	verified := make(map[uint]bool, len(accounts))
+	for _, account := range accounts {
+		verified[account.AdopterID] = account.EmailVerified
+	}
+
+	for _, app := range applications {
+		app.Answers = answersByApplication[app.ApplicationID]
+		facts := screeningFacts(app, verified[app.AdopterID], completedByAdopter[app.AdopterID])
+		scores[app.ApplicationID] = scoreApplication(rules, facts)
+	}
+	return scores, nil
+}
+
+// ScoredSubmission is an application in a shelter's applicant list with its
+// screening score.
+type ScoredSubmission struct {
+	models.AdoptionSubmission
+	Score          float64      `json:"score"`
+	MaxScore       float64      `json:"max_score"`
+	ScoreBreakdown []RuleResult `json:"score_breakdown"`
+}
+
+// withScores pairs applications with their scores, in order.
+func withScores(applications []models.AdoptionSubmission, scores map[uint]ScreeningScore) []ScoredSubmission {
+	scored := make([]ScoredSubmission, len(applications))
+	for i, app := range applications {
+		score := scores[app.ApplicationID]
+		scored[i] = ScoredSubmission{
+			AdoptionSubmission: app,
+			Score:              score.Score,
+			MaxScore:           score.MaxScore,
+			ScoreBreakdown:     score.Breakdown,
+		}
+	}
+	return scored
+}
+
+// sortByScore reports whether ?sort= ranks applicants by screening score:
+// "-score" puts the best matches first, "score" the weakest.
+func sortByScore(c *fiber.Ctx) (byScore, desc bool) {
+	switch strings.TrimSpace(c.Query("sort")) {
+	case "-score":
+		return true, true
+	case "score":
+		return true, false
+	}
+	return false, false
+}
+
+// paginateByScore pages a shelter's applications ranked by screening score.
+// Scores follow the shelter's current rules and are not stored, so every
+// application matching query is scored before the page is cut; ties keep the
+// newest first. Only page mode is available for this order.
+func paginateByScore(c *fiber.Ctx, query *gorm.DB, shelterID uint, spec listSpec, desc bool) ([]models.AdoptionSubmission, map[uint]ScreeningScore, pageInfo, string, error) {
+	info := pageInfo{Limit: listLimit(c), Page: listPageNumber(c), Sort: strings.TrimSpace(c.Query("sort"))}
+	if c.Context().QueryArgs().Has("cursor") {
+		return nil, nil, info, "400", errors.New("Cursor paging is not available when sorting by score; use page")
+	}
+
+	var ranked []models.AdoptionSubmission
+	if err := applyListFilters(c, query.Model(&models.AdoptionSubmission{}), spec).
+		Order("created_at DESC, application_id DESC").
+		Find(&ranked).Error; err != nil {
+		return nil, nil, info, "500", errors.New("Database error while listing results")
+	}
+	scores, err := scoreApplications(middleware.DBConn, shelterID, ranked)
+	if err != nil {
+		return nil, nil, info, "500", errors.New("Failed to score adoption applications")
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := scores[ranked[i].ApplicationID].Score, scores[ranked[j].ApplicationID].Score
+		if desc {
+			return a > b
+		}
+		return a < b
+	})
+
+	info.Total = int64(len(ranked))
+	start := (info.Page - 1) * info.Limit
+	if start > len(ranked) {
+		start = len(ranked)
+	}
+	end := start + info.Limit
+	if end > len(ranked) {
+		end = len(ranked)
+	}
+	info.HasMore = end < len(ranked)
+	if start == end {
+		return []models.AdoptionSubmission{}, scores, info, "200", nil
+	}
+
+	ids := make([]uint, 0, end-start)
+	for _, app := range ranked[start:end] {
+		ids = append(ids, app.ApplicationID)
+	}
+	load := middleware.DBConn
+	for _, preload := range spec.Preloads {
+		load = load.Preload(preload)
+	}
+	var loaded []models.AdoptionSubmission
+	if err := load.Where("application_id IN ?", ids).Find(&loaded).Error; err != nil {
+		return nil, nil, info, "500", errors.New("Database error while loading results")
+	}
+	byID := make(map[uint]models.AdoptionSubmission, len(loaded))
+	for _, app := range loaded {
+		byID[app.ApplicationID] = app
+	}
+	page := make([]models.AdoptionSubmission, 0, len(ids))
+	for _, id := range ids {
+		if app, ok := byID[id]; ok {
+			page = append(page, app)
+		}
+	}
+	return page, scores, info, "200", nil
+}
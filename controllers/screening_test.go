@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"testing"
+
+	"pethub_api/models"
+)
+
+func TestRuleMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		operator string
+		value    string
+		fact     string
+		want     bool
+	}{
+		{"equals ignores case", "equals", "House", "house", true},
+		{"equals trims the fact", "equals", "house", "  house ", true},
+		{"equals mismatch", "equals", "house", "condo", false},
+		{"not_equals", "not_equals", "condo", "house", true},
+		{"contains", "contains", "yard", "House with a big Yard", true},
+		{"contains missing", "contains", "yard", "apartment", false},
+		{"not_contains", "not_contains", "cat", "two dogs", true},
+		{"not_contains present", "not_contains", "cat", "one Cat", false},
+		{"gte met", "gte", "2", "3", true},
+		{"gte equal", "gte", "2", "2", true},
+		{"gte below", "gte", "2", "1", false},
+		{"gte non-numeric fact", "gte", "2", "many", false},
+		{"lte met", "lte", "1", "0", true},
+		{"lte above", "lte", "1", "2", false},
+		{"is_true yes", "is_true", "", "Yes", true},
+		{"is_true 1", "is_true", "", "1", true},
+		{"is_true no", "is_true", "", "no", false},
+		{"is_false no", "is_false", "", "no", true},
+		{"is_false empty", "is_false", "", "", false},
+		{"is_false yes", "is_false", "", "yes", false},
+		{"not_empty", "not_empty", "", "something", true},
+		{"not_empty blank", "not_empty", "", "   ", false},
+		{"unknown operator", "matches", "x", "x", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := models.ScreeningRule{Operator: tt.operator, Value: tt.value}
+			if got := ruleMatches(rule, tt.fact); got != tt.want {
+				t.Fatalf("ruleMatches(%s %q, %q) = %v, want %v", tt.operator, tt.value, tt.fact, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoreApplication(t *testing.T) {
+	rules := []models.ScreeningRule{
+		{RuleID: 1, Name: "Owns a house", Field: "housing_type", Operator: "equals", Value: "house", Weight: 5, IsActive: true},
+		{RuleID: 2, Name: "Verified email", Field: "verified_email", Operator: "is_true", Weight: 2, IsActive: true},
+		{RuleID: 3, Name: "Allergies", Field: "allergies", Operator: "not_empty", Weight: -3, IsActive: true},
+		{RuleID: 4, Name: "Inactive", Field: "experience", Operator: "not_empty", Weight: 10, IsActive: false},
+	}
+
+	tests := []struct {
+		name      string
+		facts     map[string]string
+		wantScore float64
+		matched   []uint
+	}{
+		{"all positive rules match", map[string]string{"housing_type": "House", "verified_email": "true", "experience": "dogs"}, 7, []uint{1, 2}},
+		{"penalty applies", map[string]string{"housing_type": "house", "allergies": "cats"}, 2, []uint{1, 3}},
+		{"nothing matches", map[string]string{"housing_type": "condo", "verified_email": "false"}, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := scoreApplication(rules, tt.facts)
+			if score.Score != tt.wantScore {
+				t.Errorf("score = %v, want %v", score.Score, tt.wantScore)
+			}
+			if score.MaxScore != 7 {
+				t.Errorf("max score = %v, want 7: penalties and inactive rules are left out", score.MaxScore)
+			}
+			if len(score.Breakdown) != 3 {
+				t.Fatalf("breakdown has %d rules, want the 3 active ones", len(score.Breakdown))
+			}
+			want := make(map[uint]bool)
+			for _, id := range tt.matched {
+				want[id] = true
+			}
+			for _, result := range score.Breakdown {
+				if result.Matched != want[result.RuleID] {
+					t.Errorf("rule %d matched = %v, want %v", result.RuleID, result.Matched, want[result.RuleID])
+				}
+			}
+		})
+	}
+}
+
+func TestScreeningFacts(t *testing.T) {
+	app := models.AdoptionSubmission{
+		HousingSituation: "house",
+		PetsAtHome:       "one dog",
+		Answers: []models.ApplicationAnswer{
+			{QuestionID: 7, Value: "yes"},
+		},
+	}
+	facts := screeningFacts(app, true, 2)
+
+	want := map[string]string{
+		"housing_type":              "house",
+		"other_pets":                "one dog",
+		"verified_email":            "true",
+		"prior_completed_adoptions": "2",
+		"question:7":                "yes",
+	}
+	for field, value := range want {
+		if facts[field] != value {
+			t.Errorf("%s = %q, want %q", field, facts[field], value)
+		}
+	}
+}
+
+func TestValidateScreeningRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    models.ScreeningRule
+		wantErr bool
+	}{
+		{"profile field", models.ScreeningRule{Name: "House", Field: "housing_type", Operator: "equals", Value: "house"}, false},
+		{"question field", models.ScreeningRule{Name: "Q", Field: "question:12", Operator: "is_true"}, false},
+		{"missing name", models.ScreeningRule{Field: "housing_type", Operator: "equals"}, true},
+		{"unknown operator", models.ScreeningRule{Name: "X", Field: "housing_type", Operator: "like"}, true},
+		{"unknown field", models.ScreeningRule{Name: "X", Field: "salary", Operator: "equals"}, true},
+		{"bad question field", models.ScreeningRule{Name: "X", Field: "question:abc", Operator: "equals"}, true},
+		{"non-numeric gte", models.ScreeningRule{Name: "X", Field: "prior_completed_adoptions", Operator: "gte", Value: "two"}, true},
+		{"numeric gte", models.ScreeningRule{Name: "X", Field: "prior_completed_adoptions", Operator: "gte", Value: "2"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateScreeningRule(tt.rule)
+			if tt.wantErr && err == nil {
+				t.Fatal("want an error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+		})
+	}
+}
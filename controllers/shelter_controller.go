@@ -8,6 +8,7 @@ import (
 	"pethub_api/middleware"
 	"pethub_api/models"
 	"pethub_api/models/response"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -67,6 +68,15 @@ func RegisterShelter(c *fiber.Ctx) error {
 		})
 	}
 
+	shelterContact, err := normalizeContactNumber(requestBody.ShelterContact)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
 	// Check if username exists
 	var existingUser models.ShelterAccount
 	result := middleware.DBConn.Where("username = ?", requestBody.Username).First(&existingUser)
@@ -115,7 +125,7 @@ func RegisterShelter(c *fiber.Ctx) error {
 		ShelterName:        requestBody.ShelterName,
 		ShelterAddress:     requestBody.ShelterAddress,
 		ShelterLandmark:    requestBody.ShelterLandmark,
-		ShelterContact:     requestBody.ShelterContact,
+		ShelterContact:     shelterContact,
 		ShelterEmail:       requestBody.ShelterEmail,
 		ShelterOwner:       requestBody.ShelterOwner,
 		ShelterDescription: requestBody.ShelterDescription,
@@ -193,7 +203,7 @@ func LoginShelter(c *fiber.Ctx) error {
 		})
 	}
 
-	token, err := middleware.GenerateJWT(ShelterAccount.ShelterID)
+	token, err := middleware.GenerateJWT(ShelterAccount.ShelterID, middleware.RoleShelter)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
 			RetCode: "500",
@@ -269,7 +279,15 @@ func UpdateShelterDetails(c *fiber.Ctx) error {
 		updateData["shelter_landmark"] = updateRequest.ShelterLandmark
 	}
 	if updateRequest.ShelterContact != "" {
-		updateData["shelter_contact"] = updateRequest.ShelterContact
+		shelterContact, err := normalizeContactNumber(updateRequest.ShelterContact)
+		if err != nil {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "400",
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		updateData["shelter_contact"] = shelterContact
 	}
 	if updateRequest.ShelterEmail != "" {
 		updateData["shelter_email"] = updateRequest.ShelterEmail
@@ -283,6 +301,16 @@ func UpdateShelterDetails(c *fiber.Ctx) error {
 	if updateRequest.ShelterSocial != "" {
 		updateData["shelter_social"] = updateRequest.ShelterSocial
 	}
+	if updateRequest.Timezone != "" {
+		if err := validateTimezoneField(updateRequest.Timezone); err != nil {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "400",
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		updateData["timezone"] = updateRequest.Timezone
+	}
 
 	// Debugging log
 	fmt.Printf("Update Data: %+v\n", updateData)
@@ -748,52 +776,85 @@ func GetShelterByName(c *fiber.Ctx) error {
 }
 
 func GetAdoptionSubmissionsByShelterAndStatus(c *fiber.Ctx) error {
-	shelterID := c.Params("shelter_id")
+	shelterID, err := strconv.ParseUint(c.Params("shelter_id"), 10, 32)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid shelter ID",
+			Data:    nil,
+		})
+	}
 	status := c.Query("status") // Example: ?status=approved or ?status=rejected
 
-	var submissions []models.AdoptionSubmission
-
-	query := middleware.DBConn.Debug().
-		Preload("Adopter").
-		Preload("Adopter.AdopterMedia").
-		Preload("Pet").
-		Preload("Pet.PetMedia").
-		Preload("ScheduleInterview").
-		Where("shelter_id = ?", shelterID)
+	query := middleware.DBConn.Where("shelter_id = ?", shelterID)
 
 	if status == "rejected" {
 		// Fetch all reject types
 		query = query.Where("status IN ?", []string{
 			"application_reject", "interview_reject", "approved_reject",
 		})
-	} else {
+	} else if status != "" {
 		// Normal single status filter
 		query = query.Where("status = ?", status)
 	}
 
-	result := query.Find(&submissions)
-
-	if result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(response.ShelterResponseModel{
-			RetCode: "500",
-			Message: "Database error while fetching submissions",
-			Data:    result.Error.Error(),
-		})
+	spec := listSpec{
+		IDColumn: "adoption_submissions.application_id",
+		Sorts: map[string]string{
+			"created_at":     "adoption_submissions.created_at",
+			"updated_at":     "adoption_submissions.updated_at",
+			"status":         "adoption_submissions.status",
+			"application_id": "adoption_submissions.application_id",
+		},
+		DefaultSort: "-created_at",
+		Filters: map[string]listFilter{
+			"pet_id":     {Column: "pet_id"},
+			"adopter_id": {Column: "adopter_id"},
+		},
+		Preloads: []string{"Adopter", "Adopter.AdopterMedia", "Pet", "Pet.PetMedia", "ScheduleInterview"},
 	}
 
-	if len(submissions) == 0 {
-		return c.Status(fiber.StatusNotFound).JSON(response.ShelterResponseModel{
-			RetCode: "404",
-			Message: "No adoption submissions found",
+	// ?sort=-score ranks applicants by screening score; every other order is
+	// paged in the database
+	var submissions []models.AdoptionSubmission
+	var scores map[uint]ScreeningScore
+	var pagination pageInfo
+	var retCode string
+	if byScore, desc := sortByScore(c); byScore {
+		submissions, scores, pagination, retCode, err = paginateByScore(c, query, uint(shelterID), spec, desc)
+	} else {
+		submissions, pagination, retCode, err = paginateList[models.AdoptionSubmission](c, query, spec)
+		if err == nil {
+			if scores, err = scoreApplications(middleware.DBConn, uint(shelterID), submissions); err != nil {
+				retCode, err = "500", errors.New("Failed to score adoption applications")
+			}
+		}
+	}
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
 			Data:    nil,
 		})
 	}
 
+	shelterZone := ""
+	for i := range submissions {
+		if submissions[i].ScheduleInterview.InterviewID == 0 {
+			continue
+		}
+		if shelterZone == "" {
+			shelterZone = shelterTimezone(middleware.DBConn, submissions[i].ShelterID)
+		}
+		setInterviewLocalTimes(&submissions[i].ScheduleInterview, shelterZone, submissions[i].Adopter.Timezone)
+	}
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "Success",
 		"data": fiber.Map{
-			"submissions": submissions,
+			"submissions": withScores(submissions, scores),
 		},
+		"pagination": pagination,
 	})
 }
 
@@ -808,6 +869,7 @@ func GetApplicationByApplicationID(c *fiber.Ctx) error {
 		Preload("Pet.PetMedia").
 		Preload("Shelter").
 		Preload("Shelter.ShelterMedia").
+		Preload("Answers.Question").
 		First(&adoptionSubmission)
 
 	if infoResult.Error != nil {
@@ -848,6 +910,24 @@ func GetApplicationByApplicationID(c *fiber.Ctx) error {
 func SetInterviewSchedule(c *fiber.Ctx) error {
 	applicationID := c.Params("application_id")
 
+	// Parse JSON input
+	type InterviewInput struct {
+		InterviewAt    string `json:"interview_at"`   // RFC 3339, e.g. 2025-06-01T14:00:00+08:00
+		InterviewDate  string `json:"interview_date"` // or Format: YYYY-MM-DD
+		InterviewTime  string `json:"interview_time"` // Format: HH:MM:SS, on the wall clock of timezone
+		Timezone       string `json:"timezone"`       // IANA zone, defaults to the shelter's
+		InterviewNotes string `json:"interview_notes"`
+	}
+
+	var input InterviewInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid JSON input",
+			Data:    err.Error(),
+		})
+	}
+
 	var application models.AdoptionSubmission
 	Result := middleware.DBConn.Debug().Where("application_id = ?", applicationID).First(&application)
 	if Result.Error != nil {
@@ -865,15 +945,40 @@ func SetInterviewSchedule(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if interview schedule already exists for this application
-	var existingInterview models.ScheduleInterview
-	if err := middleware.DBConn.Where("application_id = ?", application.ApplicationID).First(&existingInterview).Error; err == nil {
-		// Found existing interview schedule
+	zone := input.Timezone
+	if zone == "" {
+		zone = shelterTimezone(middleware.DBConn, application.ShelterID)
+	}
+	interviewAt, err := parseInterviewInstant(input.InterviewAt, input.InterviewDate, input.InterviewTime, zone)
+	if err == nil {
+		_, err = loadTimezone(zone)
+	}
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: err.Error(),
+		})
+	}
+	if retCode, err := validateInterviewInstant(middleware.DBConn, application.ShelterID, interviewAt); err != nil {
 		return c.JSON(response.ShelterResponseModel{
-			RetCode: "409",
-			Message: "Interview schedule already exists for this application",
-			Data:    existingInterview,
+			RetCode: retCode,
+			Message: err.Error(),
 		})
+	}
+
+	// Check if interview schedule already exists for this application. A
+	// cancelled or missed interview can be scheduled again.
+	var existingInterview models.ScheduleInterview
+	reopen := false
+	if err := middleware.DBConn.Where("application_id = ?", application.ApplicationID).First(&existingInterview).Error; err == nil {
+		if existingInterview.InterviewStatus != "cancelled" && existingInterview.InterviewStatus != "no_show" {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "409",
+				Message: "Interview schedule already exists for this application; reschedule it instead",
+				Data:    existingInterview,
+			})
+		}
+		reopen = true
 	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
 		// Some other DB error
 		return c.JSON(response.ShelterResponseModel{
@@ -883,90 +988,87 @@ func SetInterviewSchedule(c *fiber.Ctx) error {
 		})
 	}
 
-	// Update the selected application status to 'interview'
-	application.Status = "interview"
-	if err := middleware.DBConn.Save(&application).Error; err != nil {
-		return c.JSON(response.ShelterResponseModel{
-			RetCode: "500",
-			Message: "Failed to update application status",
-			Data:    err,
-		})
+	// Save interview schedule
+	newInterview := models.ScheduleInterview{
+		ApplicationID:  application.ApplicationID,
+		ShelterID:      application.ShelterID,
+		AdopterID:      application.AdopterID,
+		InterviewAt:    interviewAt.UTC(),
+		Timezone:       zone,
+		InterviewNotes: input.InterviewNotes,
+		CreatedAt:      time.Now(),
+	}
+	if reopen {
+		newInterview = existingInterview
+		newInterview.InterviewAt = interviewAt.UTC()
+		newInterview.Timezone = zone
+		newInterview.InterviewNotes = input.InterviewNotes
+		newInterview.InterviewStatus = "scheduled"
+		newInterview.CancelReason = ""
 	}
 
-	// Set all other applications for the same pet to 'in queue'
-	if err := middleware.DBConn.Model(&models.AdoptionSubmission{}).
-		Where("pet_id = ? AND application_id != ?", application.PetID, application.ApplicationID).
-		Update("status", "in queue").Error; err != nil {
+	tx := middleware.DBConn.Begin()
+	if retCode, err := advanceToInterview(tx, &application); err != nil {
+		tx.Rollback()
 		return c.JSON(response.ShelterResponseModel{
-			RetCode: "500",
-			Message: "Failed to update other applications",
-			Data:    err,
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
 		})
 	}
 
-	// Update pet status to 'pending'
-	if err := middleware.DBConn.Model(&models.PetInfo{}).
-		Where("pet_id = ?", application.PetID).
-		Update("status", "pending").Error; err != nil {
+	if err := tx.Save(&newInterview).Error; err != nil {
+		tx.Rollback()
 		return c.JSON(response.ShelterResponseModel{
 			RetCode: "500",
-			Message: "Failed to update pet status",
+			Message: "Failed to create interview schedule",
 			Data:    err,
 		})
 	}
 
-	// Parse JSON input
-	type InterviewInput struct {
-		InterviewDate  string `json:"interview_date"` // Format: YYYY-MM-DD
-		InterviewTime  string `json:"interview_time"` // Format: HH:MM:SS
-		InterviewNotes string `json:"interview_notes"`
-	}
-
-	var input InterviewInput
-	if err := c.BodyParser(&input); err != nil {
+	// Hold a seat when the time is one of the shelter's published slots
+	if err := reserveInterviewSlot(tx, newInterview); err != nil {
+		tx.Rollback()
+		if errors.Is(err, ErrSlotFull) {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "409",
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
 		return c.JSON(response.ShelterResponseModel{
-			RetCode: "400",
-			Message: "Invalid JSON input",
+			RetCode: "500",
+			Message: "Failed to reserve interview slot",
 			Data:    err.Error(),
 		})
 	}
 
-	// Convert strings to time.Time
-	interviewDate, err := time.Parse("2006-01-02", input.InterviewDate)
-	if err != nil {
+	if err := recordInterviewHistory(tx, newInterview, "scheduled", "shelter", nil, input.InterviewNotes); err != nil {
+		tx.Rollback()
 		return c.JSON(response.ShelterResponseModel{
-			RetCode: "400",
-			Message: "Invalid interview_date format. Use YYYY-MM-DD",
+			RetCode: "500",
+			Message: "Failed to record interview history",
+			Data:    err.Error(),
 		})
 	}
 
-	interviewTime, err := time.Parse("15:04:05", input.InterviewTime)
-	if err != nil {
+	if err := announceInterviewChange(tx, newInterview, "scheduled"); err != nil {
+		tx.Rollback()
 		return c.JSON(response.ShelterResponseModel{
-			RetCode: "400",
-			Message: "Invalid interview_time format. Use HH:MM:SS",
+			RetCode: "500",
+			Message: "Failed to queue interview notifications",
+			Data:    err.Error(),
 		})
 	}
-
-	// Save interview schedule
-	newInterview := models.ScheduleInterview{
-		ApplicationID:  application.ApplicationID,
-		ShelterID:      application.ShelterID,
-		AdopterID:      application.AdopterID,
-		InterviewDate:  interviewDate,
-		InterviewTime:  interviewTime.Format("15:04:05"), // Convert time.Time to string
-		InterviewNotes: input.InterviewNotes,
-		CreatedAt:      time.Now(),
-	}
-
-	if err := middleware.DBConn.Create(&newInterview).Error; err != nil {
+	if err := tx.Commit().Error; err != nil {
 		return c.JSON(response.ShelterResponseModel{
 			RetCode: "500",
 			Message: "Failed to create interview schedule",
-			Data:    err,
+			Data:    err.Error(),
 		})
 	}
 
+	localizeInterview(middleware.DBConn, &newInterview)
 	return c.JSON(response.ShelterResponseModel{
 		RetCode: "200",
 		Message: "Interview scheduled. Application marked as 'interview'. Others set to 'in queue'. Pet status set to 'pending'.",
@@ -1005,9 +1107,18 @@ func RejectApplication(c *fiber.Ctx) error {
 		})
 	}
 
+	if !callerIsShelter(c, application.ShelterID) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "Only the application's shelter can reject it",
+			Data:    nil,
+		})
+	}
+
 	reasonStr := strings.Join(body.ReasonForRejection, ", ")
 
 	// Set application status based on current status
+	oldStatus := application.Status
 	switch application.Status {
 	case "pending":
 		application.Status = "application_reject"
@@ -1025,78 +1136,77 @@ func RejectApplication(c *fiber.Ctx) error {
 
 	application.ReasonForRejection = reasonStr
 
-	if err := middleware.DBConn.Save(&application).Error; err != nil {
-		return c.JSON(response.ShelterResponseModel{
-			RetCode: "500",
-			Message: "Failed to reject application",
-			Data:    err,
-		})
-	}
+	// The rejection, the pet and the queue change together or not at all
+	message := "Failed to reject application"
+	err := middleware.DBConn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&application).Error; err != nil {
+			return err
+		}
+		if err := publishApplicationStatus(tx, application, oldStatus); err != nil {
+			message = "Failed to publish status change"
+			return err
+		}
 
-	// Handle interview-specific logic
-	if application.Status == "interview_reject" {
-		// Change interview_status to rejected only if current interview_status is interview
-		if application.ScheduleInterview.InterviewStatus == "interview" {
-			if err := middleware.DBConn.Model(&models.ScheduleInterview{}).
-				Where("application_id = ? AND interview_status = ?", applicationID, "interview").
-				Update("interview_status", "rejected").Error; err != nil {
-				return c.JSON(response.ShelterResponseModel{
-					RetCode: "500",
-					Message: "Failed to update interview status",
-					Data:    err,
-				})
+		// Handle interview-specific logic
+		if application.Status == "interview_reject" {
+			// Give the interview slot back to the shelter
+			if err := releaseInterviewSlot(tx, application.ApplicationID); err != nil {
+				message = "Failed to release interview slot"
+				return err
 			}
-		}
 
-		// Update pet status to available if currently pending
-		var pet models.PetInfo
-		if err := middleware.DBConn.Debug().Where("pet_id = ?", application.PetID).First(&pet).Error; err == nil {
-			if pet.Status == "pending" {
-				pet.Status = "available"
-				if err := middleware.DBConn.Save(&pet).Error; err != nil {
-					return c.JSON(response.ShelterResponseModel{
-						RetCode: "500",
-						Message: "Failed to update pet status",
-						Data:    err,
-					})
+			// Change interview_status to rejected unless the interview was called off
+			if application.ScheduleInterview.InterviewStatus != "cancelled" && application.ScheduleInterview.InterviewStatus != "no_show" {
+				if err := tx.Model(&models.ScheduleInterview{}).
+					Where("application_id = ? AND interview_status IN ?", applicationID, []string{"scheduled", "rescheduled", "completed"}).
+					Update("interview_status", "rejected").Error; err != nil {
+					message = "Failed to update interview status"
+					return err
 				}
 			}
 		}
-	}
 
-	// Update pet status if current status is "pending" and application was in interview or approved
-	if application.Status == "interview_reject" || application.Status == "approved_reject" {
-		var pet models.PetInfo
-		if err := middleware.DBConn.Debug().Where("pet_id = ?", application.PetID).First(&pet).Error; err == nil {
-			if pet.Status == "pending" {
-				pet.Status = "available"
-				if err := middleware.DBConn.Save(&pet).Error; err != nil {
-					return c.JSON(response.ShelterResponseModel{
-						RetCode: "500",
-						Message: "Failed to update pet status",
-						Data:    err,
-					})
+		// Update pet status if current status is "pending" and application was in interview or approved
+		if application.Status == "interview_reject" || application.Status == "approved_reject" {
+			var pet models.PetInfo
+			if err := tx.Where("pet_id = ?", application.PetID).First(&pet).Error; err == nil {
+				if pet.Status == models.PetPending {
+					change := byShelter(application.ShelterID, "Application rejected").forApplication(application.ApplicationID)
+					if err := setPetStatus(tx, &pet, models.PetAvailable, change); err != nil {
+						message = "Failed to update pet status"
+						return err
+					}
 				}
 			}
 		}
-	}
-
-	// Update other applications from "in queue" to "pending"
-	var count int64
-	middleware.DBConn.Model(&models.AdoptionSubmission{}).
-		Where("pet_id = ? AND application_id != ? AND status = ?", application.PetID, application.ApplicationID, "in queue").
-		Count(&count)
 
-	if count > 0 {
-		if err := middleware.DBConn.Model(&models.AdoptionSubmission{}).
+		// Update other applications from "in queue" to "pending"
+		var queued []models.AdoptionSubmission
+		if err := tx.
 			Where("pet_id = ? AND application_id != ? AND status = ?", application.PetID, application.ApplicationID, "in queue").
-			Update("status", "pending").Error; err != nil {
-			return c.JSON(response.ShelterResponseModel{
-				RetCode: "500",
-				Message: "Failed to update other applications",
-				Data:    err,
-			})
+			Find(&queued).Error; err != nil {
+			message = "Failed to update other applications"
+			return err
 		}
+		for _, app := range queued {
+			app.Status = "pending"
+			if err := tx.Model(&app).Update("status", app.Status).Error; err != nil {
+				message = "Failed to update other applications"
+				return err
+			}
+			if err := publishApplicationStatus(tx, app, "in queue"); err != nil {
+				message = "Failed to publish status change"
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: message,
+			Data:    err.Error(),
+		})
 	}
 
 	return c.JSON(response.ShelterResponseModel{
@@ -1126,46 +1236,57 @@ func ApproveApplication(c *fiber.Ctx) error {
 		})
 	}
 
-	if submission.Status == "interview" {
-		submission.Status = "approved"
+	if !callerIsShelter(c, submission.ShelterID) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "Only the application's shelter can approve it",
+			Data:    nil,
+		})
+	}
 
-		var schedule models.ScheduleInterview
-		if err := middleware.DBConn.Debug().Where("application_id = ?", submission.ApplicationID).First(&schedule).Error; err == nil {
-			schedule.InterviewStatus = "approved"
-			if err := middleware.DBConn.Save(&schedule).Error; err != nil {
-				return c.JSON(response.ShelterResponseModel{
-					RetCode: "500",
-					Message: "Failed to update interview status",
-					Data:    err,
-				})
-			}
+	oldStatus := submission.Status
+	if submission.Status == "interview" {
+		// Shelters with the home-visit stage need a passing visit first
+		cleared, err := hasPassingHomeVisit(middleware.DBConn, submission)
+		if err != nil {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "500",
+				Message: "Database error while checking home visits",
+				Data:    err.Error(),
+			})
 		}
-	} else if submission.Status == "approved" {
-		submission.Status = "completed"
-
-		var pet models.PetInfo
-		if err := middleware.DBConn.Debug().Where("pet_id = ?", submission.PetID).First(&pet).Error; err == nil {
-			pet.Status = "adopted"
-			if err := middleware.DBConn.Save(&pet).Error; err != nil {
-				return c.JSON(response.ShelterResponseModel{
-					RetCode: "500",
-					Message: "Failed to update pet status",
-					Data:    err,
-				})
-			}
+		if !cleared {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "400",
+				Message: "A passing home visit is required before this application can be approved",
+				Data:    nil,
+			})
 		}
 
-		// Update other applications for the same pet
-		var otherApplications []models.AdoptionSubmission
-		err := middleware.DBConn.Debug().
-			Where("pet_id = ? AND status = ?", submission.PetID, "in queue").
-			Find(&otherApplications).Error
-		if err == nil {
-			for _, app := range otherApplications {
-				app.Status = "rejected"
-				middleware.DBConn.Save(&app)
-			}
+		submission.Status = "approved"
+		approvedAt := time.Now()
+		submission.ApprovedAt = &approvedAt
+	} else if submission.Status == "approved" {
+		// Completion needs a contract signed by both the adopter and the shelter
+		var signedCount int64
+		if err := middleware.DBConn.Model(&models.SignedContract{}).
+			Where("application_id = ?", submission.ApplicationID).
+			Count(&signedCount).Error; err != nil {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "500",
+				Message: "Database error while checking signed contract",
+				Data:    err.Error(),
+			})
+		}
+		if signedCount == 0 {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "400",
+				Message: "The adoption contract must be signed by both parties before completing",
+				Data:    nil,
+			})
 		}
+
+		submission.Status = "completed"
 	} else {
 		return c.JSON(response.ShelterResponseModel{
 			RetCode: "400",
@@ -1174,11 +1295,65 @@ func ApproveApplication(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := middleware.DBConn.Save(&submission).Error; err != nil {
+	// The application, interview, pet and queue change together or not at all
+	retCode, message := "500", "Failed to update application status"
+	err := middleware.DBConn.Transaction(func(tx *gorm.DB) error {
+		if submission.Status == "approved" {
+			var schedule models.ScheduleInterview
+			if err := tx.Where("application_id = ?", submission.ApplicationID).First(&schedule).Error; err == nil {
+				schedule.InterviewStatus = "approved"
+				if err := tx.Save(&schedule).Error; err != nil {
+					message = "Failed to update interview status"
+					return err
+				}
+			}
+		}
+
+		if submission.Status == "completed" {
+			var pet models.PetInfo
+			if err := tx.Where("pet_id = ?", submission.PetID).First(&pet).Error; err == nil {
+				change := byShelter(submission.ShelterID, "Adoption completed").forApplication(submission.ApplicationID)
+				if err := setPetStatus(tx, &pet, models.PetAdopted, change); err != nil {
+					retCode, message = petStatusRetCode(err), "Failed to update pet status"
+					return err
+				}
+			}
+
+			// Update other applications for the same pet
+			var otherApplications []models.AdoptionSubmission
+			if err := tx.
+				Where("pet_id = ? AND status = ?", submission.PetID, "in queue").
+				Find(&otherApplications).Error; err != nil {
+				message = "Failed to update other applications"
+				return err
+			}
+			for _, app := range otherApplications {
+				app.Status = "rejected"
+				if err := tx.Save(&app).Error; err != nil {
+					message = "Failed to update other applications"
+					return err
+				}
+				if err := publishApplicationStatus(tx, app, "in queue"); err != nil {
+					message = "Failed to publish status change"
+					return err
+				}
+			}
+		}
+
+		if err := tx.Save(&submission).Error; err != nil {
+			return err
+		}
+		if err := publishApplicationStatus(tx, submission, oldStatus); err != nil {
+			message = "Failed to publish status change"
+			return err
+		}
+		return nil
+	})
+	if err != nil {
 		return c.JSON(response.ShelterResponseModel{
-			RetCode: "500",
-			Message: "Failed to update application status",
-			Data:    err,
+			RetCode: retCode,
+			Message: message,
+			Data:    err.Error(),
 		})
 	}
 
@@ -1295,20 +1470,23 @@ func GetAdoptionApplicationsByPetID(c *fiber.Ctx) error {
 
 	// Create a custom struct just for the response
 	type AdoptionApplicationResponse struct {
-		ApplicationID  uint   `json:"application_id"`
-		FirstName      string `json:"first_name"`
-		LastName       string `json:"last_name"`
-		AdopterProfile string `json:"adopter_profile"`
-		PetName        string `json:"pet_name"`
-		Address        string `json:"address"`
-		ContactNumber  string `json:"contact_number"`
-		Email          string `json:"email"`
-		Status         string `json:"status"`
-		CreatedAt      string `json:"created_at"`
+		ApplicationID  uint         `json:"application_id"`
+		FirstName      string       `json:"first_name"`
+		LastName       string       `json:"last_name"`
+		AdopterProfile string       `json:"adopter_profile"`
+		PetName        string       `json:"pet_name"`
+		Address        string       `json:"address"`
+		ContactNumber  string       `json:"contact_number"`
+		Email          string       `json:"email"`
+		Status         string       `json:"status"`
+		CreatedAt      string       `json:"created_at"`
+		Score          float64      `json:"score"`
+		MaxScore       float64      `json:"max_score"`
+		ScoreBreakdown []RuleResult `json:"score_breakdown"`
 	}
 
 	var applications []models.AdoptionSubmission
-	var responses []AdoptionApplicationResponse
+	responses := []AdoptionApplicationResponse{}
 
 	// Fetch the adoption submissions for the given pet_id and optional status
 	query := middleware.DBConn.Debug().
@@ -1327,8 +1505,22 @@ func GetAdoptionApplicationsByPetID(c *fiber.Ctx) error {
 		})
 	}
 
+	var scores map[uint]ScreeningScore
+	if len(applications) > 0 {
+		var err error
+		scores, err = scoreApplications(middleware.DBConn, applications[0].ShelterID, applications)
+		if err != nil {
+			return c.JSON(response.ResponseModel{
+				RetCode: "500",
+				Message: "Failed to score adoption applications",
+				Data:    err.Error(),
+			})
+		}
+	}
+
 	// Format the response to match the required fields
 	for _, app := range applications {
+		score := scores[app.ApplicationID]
 		responses = append(responses, AdoptionApplicationResponse{
 			ApplicationID:  app.ApplicationID,
 			FirstName:      app.Adopter.FirstName,
@@ -1340,6 +1532,20 @@ func GetAdoptionApplicationsByPetID(c *fiber.Ctx) error {
 			PetName:        app.Pet.PetName,                         // Assuming `PetName` is the pet's name field
 			Status:         app.Status,
 			CreatedAt:      app.CreatedAt.Format(time.RFC3339), // Format the date as needed
+			Score:          score.Score,
+			MaxScore:       score.MaxScore,
+			ScoreBreakdown: score.Breakdown,
+		})
+	}
+
+	// ?sort=-score ranks the best-matching applicants first and ?sort=score the
+	// weakest; ties keep submission order
+	if byScore, desc := sortByScore(c); byScore {
+		sort.SliceStable(responses, func(i, j int) bool {
+			if desc {
+				return responses[i].Score > responses[j].Score
+			}
+			return responses[i].Score < responses[j].Score
 		})
 	}
 
@@ -0,0 +1,623 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"pethub_api/middleware"
+	"pethub_api/models"
+	"pethub_api/models/response"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Things a staff note can be attached to
+const (
+	NoteSubjectApplication = "application"
+	NoteSubjectPet         = "pet"
+)
+
+var (
+	staffHandlePattern = regexp.MustCompile(`^[a-z0-9_.]{2,30}$`)
+	// An @ that starts a word, so email addresses are not read as mentions
+	staffMentionPattern = regexp.MustCompile(`(^|[^\w@])@([A-Za-z0-9_.]{2,30})`)
+)
+
+// mentionedHandles returns the distinct @handles in body, lower-cased.
+func mentionedHandles(body string) []string {
+	seen := map[string]bool{}
+	var handles []string
+	for _, match := range staffMentionPattern.FindAllStringSubmatch(body, -1) {
+		handle := strings.TrimRight(strings.ToLower(match[2]), ".")
+		if handle != "" && !seen[handle] {
+			seen[handle] = true
+			handles = append(handles, handle)
+		}
+	}
+	return handles
+}
+
+// GetShelterStaff lists the staff members of a shelter.
+func GetShelterStaff(c *fiber.Ctx) error {
+	shelterID, err := strconv.ParseUint(c.Params("shelter_id"), 10, 32)
+	if err != nil || !callerIsShelter(c, uint(shelterID)) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "You can only view your own staff",
+			Data:    nil,
+		})
+	}
+
+	staff := []models.ShelterStaff{}
+	if err := middleware.DBConn.Where("shelter_id = ?", shelterID).Order("name").Find(&staff).Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to fetch staff",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Staff fetched successfully",
+		Data:    staff,
+	})
+}
+
+// AddShelterStaff adds a staff member who can author notes and be
+// mentioned by @handle.
+func AddShelterStaff(c *fiber.Ctx) error {
+	shelterID, err := strconv.ParseUint(c.Params("shelter_id"), 10, 32)
+	if err != nil || !callerIsShelter(c, uint(shelterID)) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "You can only manage your own staff",
+			Data:    nil,
+		})
+	}
+
+	var body struct {
+		Handle string `json:"handle"`
+		Name   string `json:"name"`
+		Email  string `json:"email"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		})
+	}
+	staff := models.ShelterStaff{
+		ShelterID: uint(shelterID),
+		Handle:    strings.ToLower(strings.TrimPrefix(strings.TrimSpace(body.Handle), "@")),
+		Name:      strings.TrimSpace(body.Name),
+		Email:     strings.TrimSpace(body.Email),
+		Active:    true,
+		CreatedAt: time.Now(),
+	}
+	if !staffHandlePattern.MatchString(staff.Handle) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "handle must be 2-30 lowercase letters, digits, dots or underscores",
+			Data:    nil,
+		})
+	}
+	if staff.Name == "" {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "name is required",
+			Data:    nil,
+		})
+	}
+
+	if err := middleware.DBConn.Create(&staff).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "409",
+				Message: fmt.Sprintf("@%s is already taken", staff.Handle),
+				Data:    nil,
+			})
+		}
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to add staff member",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Staff member added",
+		Data:    staff,
+	})
+}
+
+// UpdateShelterStaff changes a staff member's name or email, or
+// deactivates them. Their handle stays fixed so old mentions keep reading
+// right.
+func UpdateShelterStaff(c *fiber.Ctx) error {
+	var staff models.ShelterStaff
+	if err := middleware.DBConn.Where("staff_id = ? AND shelter_id = ?", c.Params("staff_id"), c.Params("shelter_id")).
+		First(&staff).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(response.ShelterResponseModel{
+				RetCode: "404",
+				Message: "Staff member not found",
+				Data:    nil,
+			})
+		}
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to fetch staff member",
+			Data:    err.Error(),
+		})
+	}
+	if !callerIsShelter(c, staff.ShelterID) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "You can only manage your own staff",
+			Data:    nil,
+		})
+	}
+
+	var body struct {
+		Name   *string `json:"name"`
+		Email  *string `json:"email"`
+		Active *bool   `json:"active"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		})
+	}
+	if body.Name != nil && strings.TrimSpace(*body.Name) != "" {
+		staff.Name = strings.TrimSpace(*body.Name)
+	}
+	if body.Email != nil {
+		staff.Email = strings.TrimSpace(*body.Email)
+	}
+	if body.Active != nil {
+		staff.Active = *body.Active
+	}
+
+	if err := middleware.DBConn.Save(&staff).Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to update staff member",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Staff member updated",
+		Data:    staff,
+	})
+}
+
+// noteSubjectShelter returns the shelter that owns the application or pet
+// a note is about, after checking the caller is that shelter.
+func noteSubjectShelter(c *fiber.Ctx, subjectType string, subjectID uint64) (uint, string, error) {
+	var shelterID uint
+	var err error
+	notFound := "Application not found"
+	switch subjectType {
+	case NoteSubjectApplication:
+		var application models.AdoptionSubmission
+		err = middleware.DBConn.Select("shelter_id").Where("application_id = ?", subjectID).First(&application).Error
+		shelterID = application.ShelterID
+	case NoteSubjectPet:
+		var pet models.PetInfo
+		err = middleware.DBConn.Select("shelter_id").Where("pet_id = ?", subjectID).First(&pet).Error
+		shelterID = pet.ShelterID
+		notFound = "Pet not found"
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, "404", errors.New(notFound)
+	}
+	if err != nil {
+		return 0, "500", fmt.Errorf("Database error while fetching %s", subjectType)
+	}
+	if !callerIsShelter(c, shelterID) {
+		return 0, "403", errors.New("Notes are only visible to the owning shelter")
+	}
+	return shelterID, "200", nil
+}
+
+// noteAuthor resolves who is writing a note. Without a staff ID the note is
+// attributed to the signed-in shelter account. A staff ID cannot be checked
+// against the token, which only names the shelter, so it is taken on the
+// client's word and the note is marked self-declared.
+func noteAuthor(db *gorm.DB, shelterID uint, staffID *uint) (*uint, string, error) {
+	if staffID == nil || *staffID == 0 {
+		var shelter models.ShelterInfo
+		if err := db.Select("shelter_name").Where("shelter_id = ?", shelterID).First(&shelter).Error; err != nil {
+			return nil, "", err
+		}
+		return nil, shelter.ShelterName, nil
+	}
+	var staff models.ShelterStaff
+	if err := db.Where("staff_id = ? AND shelter_id = ? AND active = ?", *staffID, shelterID, true).First(&staff).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", errors.New("Unknown or inactive staff member")
+		}
+		return nil, "", err
+	}
+	return &staff.StaffID, staff.Name, nil
+}
+
+// recordNoteMentions stores the staff members newly mentioned in note and
+// emails each of them a link to it through the outbox.
+func recordNoteMentions(db *gorm.DB, note models.StaffNote) error {
+	handles := mentionedHandles(note.Body)
+	if len(handles) == 0 {
+		return nil
+	}
+
+	var staff []models.ShelterStaff
+	if err := db.Where("shelter_id = ? AND handle IN ? AND active = ?", note.ShelterID, handles, true).
+		Find(&staff).Error; err != nil {
+		return err
+	}
+	for _, member := range staff {
+		if note.AuthorStaffID != nil && *note.AuthorStaffID == member.StaffID {
+			continue
+		}
+		mention := models.StaffNoteMention{
+			NoteID:    note.NoteID,
+			StaffID:   member.StaffID,
+			Handle:    member.Handle,
+			CreatedAt: time.Now(),
+		}
+		result := db.Where("note_id = ? AND staff_id = ?", note.NoteID, member.StaffID).FirstOrCreate(&mention)
+		if result.Error != nil {
+			return result.Error
+		}
+		// Only a first mention is news; re-saving a note does not ping again
+		if result.RowsAffected == 0 || member.Email == "" {
+			continue
+		}
+		if err := queueEmail(db, fmt.Sprintf("staff:%d", member.StaffID), outboxEmail{
+			To:      member.Email,
+			Subject: fmt.Sprintf("%s mentioned you in a note", note.AuthorName),
+			Body: fmt.Sprintf("Hello %s,\n\n%s mentioned you in a note on %s #%d:\n\n%s\n",
+				member.Name, note.AuthorName, note.SubjectType, note.SubjectID, note.Body),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetApplicationStaffNotes lists the shelter's private notes on an
+// application.
+func GetApplicationStaffNotes(c *fiber.Ctx) error {
+	return getStaffNotes(c, NoteSubjectApplication, c.Params("application_id"))
+}
+
+// GetPetStaffNotes lists the shelter's private notes on a pet.
+func GetPetStaffNotes(c *fiber.Ctx) error {
+	return getStaffNotes(c, NoteSubjectPet, c.Params("pet_id"))
+}
+
+func getStaffNotes(c *fiber.Ctx, subjectType, rawSubjectID string) error {
+	subjectID, err := strconv.ParseUint(rawSubjectID, 10, 32)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid ID",
+			Data:    nil,
+		})
+	}
+	shelterID, retCode, err := noteSubjectShelter(c, subjectType, subjectID)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	notes := []models.StaffNote{}
+	if err := middleware.DBConn.Preload("Mentions").
+		Where("shelter_id = ? AND subject_type = ? AND subject_id = ?", shelterID, subjectType, subjectID).
+		Order("created_at DESC").
+		Find(&notes).Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to fetch notes",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Notes fetched successfully",
+		Data:    notes,
+	})
+}
+
+// AddApplicationStaffNote adds a private note to an application.
+func AddApplicationStaffNote(c *fiber.Ctx) error {
+	return addStaffNote(c, NoteSubjectApplication, c.Params("application_id"))
+}
+
+// AddPetStaffNote adds a private note to a pet.
+func AddPetStaffNote(c *fiber.Ctx) error {
+	return addStaffNote(c, NoteSubjectPet, c.Params("pet_id"))
+}
+
+func addStaffNote(c *fiber.Ctx, subjectType, rawSubjectID string) error {
+	subjectID, err := strconv.ParseUint(rawSubjectID, 10, 32)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid ID",
+			Data:    nil,
+		})
+	}
+	shelterID, retCode, err := noteSubjectShelter(c, subjectType, subjectID)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	var body struct {
+		Body          string `json:"body"`
+		AuthorStaffID *uint  `json:"author_staff_id"` // self-declared, see noteAuthor
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		})
+	}
+	body.Body = strings.TrimSpace(body.Body)
+	if body.Body == "" {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "body is required",
+			Data:    nil,
+		})
+	}
+
+	authorID, authorName, err := noteAuthor(middleware.DBConn, shelterID, body.AuthorStaffID)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	note := models.StaffNote{
+		ShelterID:          shelterID,
+		SubjectType:        subjectType,
+		SubjectID:          uint(subjectID),
+		AuthorStaffID:      authorID,
+		AuthorName:         authorName,
+		AuthorSelfDeclared: authorID != nil,
+		Body:               body.Body,
+		CreatedAt:          time.Now(),
+	}
+	tx := middleware.DBConn.Begin()
+	if err := tx.Create(&note).Error; err != nil {
+		tx.Rollback()
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to save note",
+			Data:    err.Error(),
+		})
+	}
+	if err := recordNoteMentions(tx, note); err != nil {
+		tx.Rollback()
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to record mentions",
+			Data:    err.Error(),
+		})
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to save note",
+			Data:    err.Error(),
+		})
+	}
+
+	middleware.DBConn.Preload("Mentions").First(&note, note.NoteID)
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Note added",
+		Data:    note,
+	})
+}
+
+// findStaffNote loads the note in :note_id for its own shelter.
+func findStaffNote(c *fiber.Ctx) (models.StaffNote, string, error) {
+	var note models.StaffNote
+	if err := middleware.DBConn.Where("note_id = ?", c.Params("note_id")).First(&note).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return note, "404", errors.New("Note not found")
+		}
+		return note, "500", errors.New("Database error while fetching note")
+	}
+	if !callerIsShelter(c, note.ShelterID) {
+		return note, "403", errors.New("Notes are only visible to the owning shelter")
+	}
+	return note, "200", nil
+}
+
+// EditStaffNote replaces a note's text, keeping the old text in its
+// history. Staff mentioned for the first time are notified.
+func EditStaffNote(c *fiber.Ctx) error {
+	note, retCode, err := findStaffNote(c)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	var body struct {
+		Body          string `json:"body"`
+		EditorStaffID *uint  `json:"editor_staff_id"` // self-declared, see noteAuthor
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data:    err.Error(),
+		})
+	}
+	body.Body = strings.TrimSpace(body.Body)
+	if body.Body == "" {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: "body is required",
+			Data:    nil,
+		})
+	}
+	if body.Body == note.Body {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "200",
+			Message: "Note unchanged",
+			Data:    note,
+		})
+	}
+
+	editorID, editorName, err := noteAuthor(middleware.DBConn, note.ShelterID, body.EditorStaffID)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "400",
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	tx := middleware.DBConn.Begin()
+	if err := tx.Create(&models.StaffNoteRevision{
+		NoteID:             note.NoteID,
+		Body:               note.Body,
+		EditedByStaffID:    editorID,
+		EditedByName:       editorName,
+		EditorSelfDeclared: editorID != nil,
+		ReplacedAt:         time.Now(),
+	}).Error; err != nil {
+		tx.Rollback()
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to save note history",
+			Data:    err.Error(),
+		})
+	}
+	note.Body = body.Body
+	note.Edited = true
+	if err := tx.Model(&note).Updates(map[string]interface{}{"body": note.Body, "edited": true}).Error; err != nil {
+		tx.Rollback()
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to update note",
+			Data:    err.Error(),
+		})
+	}
+	if err := recordNoteMentions(tx, note); err != nil {
+		tx.Rollback()
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to record mentions",
+			Data:    err.Error(),
+		})
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to update note",
+			Data:    err.Error(),
+		})
+	}
+
+	middleware.DBConn.Preload("Mentions").First(&note, note.NoteID)
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Note updated",
+		Data:    note,
+	})
+}
+
+// GetStaffNoteHistory returns a note with every earlier version of its
+// text, oldest first.
+func GetStaffNoteHistory(c *fiber.Ctx) error {
+	note, retCode, err := findStaffNote(c)
+	if err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: retCode,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	revisions := []models.StaffNoteRevision{}
+	if err := middleware.DBConn.Where("note_id = ?", note.NoteID).Order("replaced_at, revision_id").
+		Find(&revisions).Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to fetch note history",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Note history fetched successfully",
+		Data: fiber.Map{
+			"note":      note,
+			"revisions": revisions,
+		},
+	})
+}
+
+// GetStaffMentions lists the notes a staff member was mentioned in, newest
+// first.
+func GetStaffMentions(c *fiber.Ctx) error {
+	shelterID, err := strconv.ParseUint(c.Params("shelter_id"), 10, 32)
+	if err != nil || !callerIsShelter(c, uint(shelterID)) {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "403",
+			Message: "Notes are only visible to the owning shelter",
+			Data:    nil,
+		})
+	}
+
+	notes := []models.StaffNote{}
+	if err := middleware.DBConn.Preload("Mentions").
+		Joins("JOIN staff_note_mentions ON staff_note_mentions.note_id = staff_notes.note_id").
+		Where("staff_notes.shelter_id = ? AND staff_note_mentions.staff_id = ?", shelterID, c.Params("staff_id")).
+		Order("staff_note_mentions.created_at DESC").
+		Find(&notes).Error; err != nil {
+		return c.JSON(response.ShelterResponseModel{
+			RetCode: "500",
+			Message: "Failed to fetch mentions",
+			Data:    err.Error(),
+		})
+	}
+
+	return c.JSON(response.ShelterResponseModel{
+		RetCode: "200",
+		Message: "Mentions fetched successfully",
+		Data:    notes,
+	})
+}
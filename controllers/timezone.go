@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"errors"
+	"time"
+
+	"pethub_api/middleware"
+	"pethub_api/models"
+
+	"gorm.io/gorm"
+)
+
+// Used when neither the shelter nor DEFAULT_TIMEZONE names a zone
+const fallbackTimezone = "Asia/Manila"
+
+// loadTimezone only accepts named IANA zones, so an interview is never tied
+// to whatever zone the server happens to run in.
+func loadTimezone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, errors.New("timezone must be an IANA name such as Asia/Manila")
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, errors.New("unknown timezone '" + name + "'")
+	}
+	return loc, nil
+}
+
+func defaultTimezone() string {
+	if name := middleware.GetEnv("DEFAULT_TIMEZONE"); name != "" {
+		if _, err := loadTimezone(name); err == nil {
+			return name
+		}
+	}
+	return fallbackTimezone
+}
+
+// mustLocation resolves a stored zone name, falling back to the default for
+// rows saved before the zone was recorded.
+func mustLocation(name string) *time.Location {
+	if loc, err := loadTimezone(name); err == nil {
+		return loc
+	}
+	loc, _ := loadTimezone(defaultTimezone())
+	if loc == nil {
+		return time.UTC
+	}
+	return loc
+}
+
+func shelterTimezone(db *gorm.DB, shelterID uint) string {
+	var info models.ShelterInfo
+	if err := db.Select("shelter_id, timezone").Where("shelter_id = ?", shelterID).First(&info).Error; err == nil {
+		if _, err := loadTimezone(info.Timezone); err == nil {
+			return info.Timezone
+		}
+	}
+	return defaultTimezone()
+}
+
+// adopterTimezone falls back to the given zone when the adopter has not set
+// one, which is usually the shelter's.
+func adopterTimezone(db *gorm.DB, adopterID uint, fallback string) string {
+	var info models.AdopterInfo
+	if err := db.Select("adopter_id, timezone").Where("adopter_id = ?", adopterID).First(&info).Error; err == nil {
+		if _, err := loadTimezone(info.Timezone); err == nil {
+			return info.Timezone
+		}
+	}
+	return fallback
+}
+
+func localTime(t time.Time, zone string) models.InterviewLocalTime {
+	local := t.In(mustLocation(zone))
+	return models.InterviewLocalTime{
+		Timezone: zone,
+		DateTime: local.Format(time.RFC3339),
+		Date:     local.Format("2006-01-02"),
+		Time:     local.Format("15:04"),
+		Display:  local.Format("Mon, Jan 2 2006 3:04 PM MST"),
+	}
+}
+
+// localizeInterview fills in the interview time as the shelter and the
+// adopter each see it.
+func localizeInterview(db *gorm.DB, interview *models.ScheduleInterview) {
+	if interview.InterviewAt.IsZero() {
+		return
+	}
+	shelterZone := shelterTimezone(db, interview.ShelterID)
+	setInterviewLocalTimes(interview, shelterZone, adopterTimezone(db, interview.AdopterID, shelterZone))
+}
+
+// setInterviewLocalTimes is localizeInterview for callers that already know
+// both zones, e.g. when listing many applications of one shelter.
+func setInterviewLocalTimes(interview *models.ScheduleInterview, shelterZone, adopterZone string) {
+	if interview.InterviewAt.IsZero() {
+		return
+	}
+	if _, err := loadTimezone(adopterZone); err != nil {
+		adopterZone = shelterZone
+	}
+	interview.ShelterLocal = localTime(interview.InterviewAt, shelterZone)
+	interview.AdopterLocal = localTime(interview.InterviewAt, adopterZone)
+}
+
+// parseInterviewInstant reads an interview time either as an RFC 3339
+// instant, or as a date (YYYY-MM-DD) and clock time (HH:MM or HH:MM:SS) on
+// the wall clock of zone.
+func parseInterviewInstant(at, date, clock, zone string) (time.Time, error) {
+	if at != "" {
+		instant, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			return time.Time{}, errors.New("Invalid interview_at format. Use RFC 3339, e.g. 2025-06-01T14:00:00+08:00")
+		}
+		return instant, nil
+	}
+
+	loc, err := loadTimezone(zone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	day, err := time.ParseInLocation("2006-01-02", date, loc)
+	if err != nil {
+		return time.Time{}, errors.New("Invalid interview_date format. Use YYYY-MM-DD")
+	}
+	t, err := time.Parse("15:04:05", clock)
+	if err != nil {
+		if t, err = time.Parse("15:04", clock); err != nil {
+			return time.Time{}, errors.New("Invalid interview_time format. Use HH:MM or HH:MM:SS")
+		}
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc), nil
+}
+
+// validateInterviewInstant checks the interview is in the future and, when
+// the shelter has published hours, that it starts and ends inside them on a
+// day that is not blacked out.
+func validateInterviewInstant(db *gorm.DB, shelterID uint, at time.Time) (string, error) {
+	if !at.After(time.Now()) {
+		return "400", errors.New("Interview time must be in the future")
+	}
+
+	windows, blackoutDays, err := loadAvailability(db, shelterID)
+	if err != nil {
+		return "500", errors.New("Database error while fetching availability")
+	}
+	if len(windows) == 0 {
+		return "200", nil
+	}
+
+	loc := mustLocation(shelterTimezone(db, shelterID))
+	local := at.In(loc)
+	end := local.Add(interviewLength(windows, blackoutDays, at, loc))
+	if blackoutDays[local.Format("2006-01-02")] || blackoutDays[end.Format("2006-01-02")] {
+		return "400", errors.New("The shelter is closed for interviews on that date")
+	}
+	for _, window := range windows {
+		if int(local.Weekday()) != window.Weekday {
+			continue
+		}
+		if !local.Before(clockOn(local, window.StartTime)) && !end.After(clockOn(local, window.EndTime)) {
+			return "200", nil
+		}
+	}
+	return "400", errors.New("Interview would run outside the shelter's interview hours")
+}
+
+// validateTimezoneField checks an optional timezone sent on a profile update.
+func validateTimezoneField(name string) error {
+	if name == "" {
+		return nil
+	}
+	_, err := loadTimezone(name)
+	return err
+}
@@ -3,6 +3,7 @@ package controllers
 import (
 	"encoding/base64"
 	"errors"
+	"log"
 	"pethub_api/middleware"
 	"pethub_api/models"
 	"pethub_api/models/response"
@@ -156,21 +157,27 @@ func GetAllSheltersByID(c *fiber.Ctx) error {
 }
 
 func GetAllShelters(c *fiber.Ctx) error {
-	var AllShelters []models.ShelterAccount
-	result := middleware.DBConn.Debug().Preload("ShelterInfo").Preload("ShelterInfo.ShelterMedia").Where("reg_status = ? AND status = ?", "approved", "active").Find(&AllShelters)
-
-	if result.Error != nil {
-		return c.JSON(response.AdopterResponseModel{
-			RetCode: "500",
-			Message: "Database error",
-			Data:    nil,
-		})
-	}
-
-	if len(AllShelters) == 0 {
+	query := middleware.DBConn.
+		Joins("LEFT JOIN shelterinfo ON shelterinfo.shelter_id = shelteraccount.shelter_id").
+		Where("shelteraccount.reg_status = ? AND shelteraccount.status = ?", "approved", "active")
+	AllShelters, pagination, retCode, err := paginateList[models.ShelterAccount](c, query, listSpec{
+		IDColumn: "shelteraccount.shelter_id",
+		Sorts: map[string]string{
+			"shelter_name": "COALESCE(shelterinfo.shelter_name, '')",
+			"created_at":   "shelteraccount.created_at",
+			"shelter_id":   "shelteraccount.shelter_id",
+		},
+		DefaultSort: "shelter_name",
+		Filters: map[string]listFilter{
+			"shelter_name":    {Column: "shelterinfo.shelter_name", Contains: true},
+			"shelter_address": {Column: "shelterinfo.shelter_address", Contains: true},
+		},
+		Preloads: []string{"ShelterInfo", "ShelterInfo.ShelterMedia"},
+	})
+	if err != nil {
 		return c.JSON(response.AdopterResponseModel{
-			RetCode: "404",
-			Message: "No shelters found",
+			RetCode: retCode,
+			Message: err.Error(),
 			Data:    nil,
 		})
 	}
@@ -180,6 +187,7 @@ func GetAllShelters(c *fiber.Ctx) error {
 		"data": fiber.Map{
 			"shelters": AllShelters,
 		},
+		"pagination": pagination,
 	})
 }
 
@@ -217,23 +225,36 @@ func GetShelters(c *fiber.Ctx) error {
 func UpdatePetStatusToPending(c *fiber.Ctx) error {
 	petID := c.Params("id")
 
-	// Update the status of the pet to "pending"
-	result := middleware.DBConn.Table("petinfo").
-		Where("pet_id = ?", petID).
-		Update("status", "pending")
-
-	// Handle errors
-	if result.Error != nil {
+	var pet models.PetInfo
+	if err := middleware.DBConn.Where("pet_id = ?", petID).First(&pet).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Pet not found",
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": "Failed to update pet status",
-			"error":   result.Error.Error(),
+			"error":   err.Error(),
 		})
 	}
 
-	// Check if any rows were affected
-	if result.RowsAffected == 0 {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"message": "Pet not found",
+	// Only the pet's shelter can hold it
+	if !callerIsShelter(c, pet.ShelterID) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Only the pet's shelter can change its status",
+		})
+	}
+
+	// Update the status of the pet to "pending"
+	if err := setPetStatus(middleware.DBConn, &pet, models.PetPending, byShelter(pet.ShelterID, "Set to pending")); err != nil {
+		if errors.Is(err, ErrPetStatusChange) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to update pet status",
+			"error":   err.Error(),
 		})
 	}
 
@@ -270,6 +291,7 @@ func FetchAndSearchAllPetsforAdopter(c *fiber.Ctx) error {
 	if prioritystatus != "" {
 		query = query.Where("priority_status = ?", prioritystatus)
 	}
+	query = applyPetProfileFilters(c, query).Preload("Breeds")
 
 	result := query.Order("priority_status DESC").Order("created_at DESC").Find(&pets)
 	if result.Error != nil {
@@ -315,9 +337,15 @@ func FetchAndSearchAllPetsforAdopter(c *fiber.Ctx) error {
 			PetType:        pet.PetType,
 			PetName:        pet.PetName,
 			PetSex:         pet.PetSex,
+			PetAge:         pet.PetAge,
+			AgeType:        pet.AgeType,
+			PetSize:        pet.PetSize,
 			PriorityStatus: pet.PriorityStatus,
 			ShelterID:      pet.ShelterID,
 			PetImages:      petMediaMap[pet.PetID],
+			PetProfile:     pet.PetProfile,
+			PetIntake:      pet.PetIntake,
+			Breeds:         pet.Breeds,
 		})
 	}
 
@@ -330,44 +358,12 @@ func FetchAndSearchAllPetsforAdopter(c *fiber.Ctx) error {
 }
 
 func FetchAllPets(c *fiber.Ctx) error {
-	// Get filters from query parameters
-	petName := c.Query("pet_name")
-	petSex := c.Query("sex")
-	petType := c.Query("type")
-	prioritystatus := c.Query("priority_status")
-
-	var pets []models.PetInfo
-
-	// Start building the query without filtering by shelter_id
-	query := middleware.DBConn.Where("status = ?", "available")
-
-	if petName != "" {
-		query = query.Where("pet_name ILIKE ?", "%"+petName+"%")
-	}
-	if petSex != "" {
-		query = query.Where("pet_sex = ?", petSex)
-	}
-	if petType != "" {
-		query = query.Where("pet_type = ?", petType)
-	}
-	if prioritystatus != "" {
-		query = query.Where("priority_status = ?", prioritystatus)
-	}
-
-	// Execute the query
-	result := query.Order("priority_status DESC").Order("created_at DESC").Find(&pets)
-	if result.Error != nil {
-		return c.JSON(response.ShelterResponseModel{
-			RetCode: "500",
-			Message: "Database error while fetching pets",
-			Data:    result.Error,
-		})
-	}
-
-	if len(pets) == 0 {
+	query := applyPetProfileFilters(c, middleware.DBConn.Where("status = ?", models.PetAvailable))
+	pets, pagination, retCode, err := paginateList[models.PetInfo](c, query, petListSpec)
+	if err != nil {
 		return c.JSON(response.ShelterResponseModel{
-			RetCode: "404",
-			Message: "No pets found",
+			RetCode: retCode,
+			Message: err.Error(),
 			Data:    nil,
 		})
 	}
@@ -392,16 +388,22 @@ func FetchAllPets(c *fiber.Ctx) error {
 		}
 	}
 
-	var petResponses []PetResponse
+	petResponses := []PetResponse{}
 	for _, pet := range pets {
 		petResponses = append(petResponses, PetResponse{
 			PetID:          pet.PetID,
 			PetType:        pet.PetType,
 			PetName:        pet.PetName,
 			PetSex:         pet.PetSex,
+			PetAge:         pet.PetAge,
+			AgeType:        pet.AgeType,
+			PetSize:        pet.PetSize,
 			PriorityStatus: pet.PriorityStatus,
 			ShelterID:      pet.ShelterID,
 			PetImages:      petMediaMap[pet.PetID],
+			PetProfile:     pet.PetProfile,
+			PetIntake:      pet.PetIntake,
+			Breeds:         pet.Breeds,
 		})
 	}
 
@@ -410,6 +412,7 @@ func FetchAllPets(c *fiber.Ctx) error {
 		"data": fiber.Map{
 			"pets": petResponses,
 		},
+		"pagination": pagination,
 	})
 }
 
@@ -422,6 +425,7 @@ func GetApplicationByAdopterID(c *fiber.Ctx) error {
 		Preload("Adopter.AdopterMedia").
 		Preload("Pet").
 		Preload("Pet.PetMedia").
+		Preload("Answers.Question").
 		First(&adoptionSubmission)
 
 	if infoResult.Error != nil {
@@ -663,6 +667,13 @@ func SubmitReport(c *fiber.Ctx) error {
 			"error":   err.Error(),
 		})
 	}
+	if err := middleware.PublishEvent(middleware.DBConn, ReportFiledEvent{
+		ReportID:  report.ID,
+		ShelterID: uint(report.ShelterID),
+		Reason:    report.Reason,
+	}); err != nil {
+		log.Println("Submit report: failed to notify shelter:", err)
+	}
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message": "Report submitted successfully",
@@ -687,6 +698,7 @@ func ShowPetsByAdopterID(c *fiber.Ctx) error {
 		Status              string `json:"status"`
 		InterviewDate       string `json:"interview_date,omitempty"`
 		InterviewTime       string `json:"interview_time,omitempty"`
+		InterviewTimezone   string `json:"interview_timezone,omitempty"`
 		InterviewNotes string `json:"interview_notes,omitempty"`
 	}
 
@@ -734,13 +746,16 @@ func ShowPetsByAdopterID(c *fiber.Ctx) error {
 		}
 
 		// If status is Interview, fetch schedule info
-		if submission.Status == "Interview" {
+		if submission.Status == "interview" {
 			var interview models.ScheduleInterview
 			if err := middleware.DBConn.
 				Where("application_id = ?", submission.ApplicationID).
 				First(&interview).Error; err == nil {
-				response.InterviewDate = interview.InterviewDate.Format("2006-01-02") // format date as yyyy-mm-dd
-				response.InterviewTime = interview.InterviewTime
+				// Shown on the adopter's own clock
+				localizeInterview(middleware.DBConn, &interview)
+				response.InterviewDate = interview.AdopterLocal.Date
+				response.InterviewTime = interview.AdopterLocal.Time
+				response.InterviewTimezone = interview.AdopterLocal.Timezone
 				response.InterviewNotes = interview.InterviewNotes
 			}
 		}
@@ -778,38 +793,40 @@ func CheckApplicationExists(c *fiber.Ctx) error {
 		})
 	}
 
-	var submissions []models.AdoptionSubmission
-	result := middleware.DBConn.Find(&submissions)
-	if result.Error != nil {
+	var petExists, adopterExists, petAndAdopterMatch bool
+	var matchedApplicationID uint = 0
+
+	// Each flag is answered by an indexed lookup instead of scanning every submission
+	if err := middleware.DBConn.Model(&models.AdoptionSubmission{}).
+		Select("count(*) > 0").
+		Where("pet_id = ?", petId).
+		Scan(&petExists).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": result.Error.Error(),
+			"error": err.Error(),
 		})
 	}
 
-	petExists := false
-	adopterExists := false
-	petAndAdopterMatch := false
-	var matchedApplicationID uint = 0
-
-	rejectedStatuses := map[string]bool{
-		"application_reject": true,
-		"interview_reject":   true,
-		"approved_reject":    true,
+	if err := middleware.DBConn.Model(&models.AdoptionSubmission{}).
+		Select("count(*) > 0").
+		Where("adopter_id = ? AND status IN ?", adopterId, activeApplicationStatuses).
+		Scan(&adopterExists).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
 	}
 
-	for _, submission := range submissions {
-		if submission.PetID == uint(petId) {
-			petExists = true
-		}
-		if submission.AdopterID == uint(adopterId) {
-			if !rejectedStatuses[submission.Status] {
-				adopterExists = true
-				if submission.PetID == uint(petId) {
-					petAndAdopterMatch = true
-					matchedApplicationID = submission.ApplicationID
-				}
-			}
-		}
+	var matched models.AdoptionSubmission
+	err = middleware.DBConn.Select("application_id").
+		Where("adopter_id = ? AND pet_id = ? AND status IN ?", adopterId, petId, activeApplicationStatuses).
+		Order("created_at DESC").
+		First(&matched).Error
+	if err == nil {
+		petAndAdopterMatch = true
+		matchedApplicationID = matched.ApplicationID
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
 	}
 
 	return c.JSON(fiber.Map{
@@ -2,8 +2,10 @@ package main
 
 import (
 	"fmt"
+	"pethub_api/controllers"
 	"pethub_api/middleware"
 	"pethub_api/routes"
+	_ "time/tzdata" // interview timezones must resolve even without system zoneinfo
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -25,21 +27,22 @@ func init() {
 	} else {
 		fmt.Println("DB CONNECTION SUCCESSFUL!")
 
-		// NOTE: AutoMigrate disabled to prevent schema changes in production
-		// If you need to re-enable migration, uncomment the lines below:
-		/*
-			if err := middleware.DBConn.AutoMigrate(&models.AdoptionApplication{}, &models.Questionnaires{}); err != nil {
-				fmt.Println("Migration failed:", err)
-			} else {
-				fmt.Println("Migration successful!")
-			}
-		*/
+		// Schema migrations run inside middleware.ConnectDB
+		middleware.SetupRealtime()
+		middleware.SetupSMS()
+		middleware.StartScheduler()
 	}
+
+	controllers.RegisterEventHandlers()
+	controllers.RegisterOutboxHandlers()
+	controllers.RegisterScheduledJobs()
 }
 
 func main() {
 	app := fiber.New(fiber.Config{
 		AppName: middleware.GetEnv("PROJ_NAME"),
+		// Room for bulk pet imports with a ZIP of photos
+		BodyLimit: 64 * 1024 * 1024,
 	})
 
 	// CORS middleware
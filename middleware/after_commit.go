@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// hookedPool wraps the connection pool so the transactions it begins can run
+// callbacks once they commit.
+type hookedPool struct {
+	*sql.DB
+}
+
+func (p hookedPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	tx, err := p.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &hookedTx{Tx: tx, db: p.DB}, nil
+}
+
+// GetDBConn lets gorm's DB() reach the underlying pool.
+func (p hookedPool) GetDBConn() (*sql.DB, error) {
+	return p.DB, nil
+}
+
+// hookedTx is a transaction that collects callbacks to run after commit.
+type hookedTx struct {
+	*sql.Tx
+	db    *sql.DB
+	mu    sync.Mutex
+	hooks []func()
+}
+
+func (t *hookedTx) Commit() error {
+	if err := t.Tx.Commit(); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	hooks := t.hooks
+	t.hooks = nil
+	t.mu.Unlock()
+	for _, hook := range hooks {
+		hook()
+	}
+	return nil
+}
+
+func (t *hookedTx) Rollback() error {
+	t.mu.Lock()
+	t.hooks = nil
+	t.mu.Unlock()
+	return t.Tx.Rollback()
+}
+
+func (t *hookedTx) GetDBConn() (*sql.DB, error) {
+	return t.db, nil
+}
+
+// useCommitHooks swaps db's connection pool for one whose transactions
+// support AfterCommit.
+func useCommitHooks(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	db.ConnPool = hookedPool{DB: sqlDB}
+	db.Statement.ConnPool = db.ConnPool
+	return nil
+}
+
+// AfterCommit runs fn once the transaction db belongs to commits, and drops
+// it if the transaction rolls back. Outside a transaction fn runs right away.
+// Rolling back to a savepoint does not drop callbacks added after it.
+func AfterCommit(db *gorm.DB, fn func()) {
+	tx, ok := db.Statement.ConnPool.(*hookedTx)
+	if !ok {
+		fn()
+		return
+	}
+	tx.mu.Lock()
+	tx.hooks = append(tx.hooks, fn)
+	tx.mu.Unlock()
+}
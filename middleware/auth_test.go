@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// authTestApp serves /optional behind OptionalJWT and /admin behind
+// JWTMiddleware and RequireRole(RoleAdmin); both echo the caller's role.
+func authTestApp() *fiber.App {
+	echo := func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"RetCode": "200", "Data": GetRoleFromJWT(c)})
+	}
+	app := fiber.New()
+	app.Get("/optional", OptionalJWT(), echo)
+	app.Get("/admin", JWTMiddleware(), RequireRole(RoleAdmin), echo)
+	return app
+}
+
+func TestAdminAuth(t *testing.T) {
+	SecretKey = "test-secret"
+	token := func(role string) string {
+		signed, err := GenerateJWT(7, role)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + signed
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		auth     string
+		wantCode string
+		wantRole string
+	}{
+		{"optional without a token", "/optional", "", "200", ""},
+		{"optional with a shelter token", "/optional", token(RoleShelter), "200", RoleShelter},
+		{"optional with a bad token", "/optional", "Bearer nonsense", "401", ""},
+		{"admin route with an admin token", "/admin", token(RoleAdmin), "200", RoleAdmin},
+		{"admin route with a shelter token", "/admin", token(RoleShelter), "403", ""},
+		{"admin route with an adopter token", "/admin", token(RoleAdopter), "403", ""},
+		{"admin route without a token", "/admin", "", "401", ""},
+	}
+
+	app := authTestApp()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var body struct {
+				RetCode string
+				Data    interface{}
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.RetCode != tt.wantCode {
+				t.Fatalf("RetCode = %s, want %s", body.RetCode, tt.wantCode)
+			}
+			if tt.wantCode == "200" && body.Data != tt.wantRole {
+				t.Fatalf("role = %v, want %q", body.Data, tt.wantRole)
+			}
+		})
+	}
+}
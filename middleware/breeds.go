@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"pethub_api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultBreeds seeds the breed reference list. Shelters pick a pet's
+// breeds from the list for its species; admins can add more.
+var defaultBreeds = map[string][]string{
+	"dog": {
+		"Aspin", "Mixed Breed", "Beagle", "Border Collie", "Chihuahua", "Dachshund",
+		"Doberman Pinscher", "French Bulldog", "German Shepherd", "Golden Retriever",
+		"Japanese Spitz", "Labrador Retriever", "Maltese", "Pomeranian", "Poodle",
+		"Pug", "Rottweiler", "Shih Tzu", "Siberian Husky", "Yorkshire Terrier",
+	},
+	"cat": {
+		"Puspin", "Mixed Breed", "American Shorthair", "Bengal", "British Shorthair",
+		"Domestic Longhair", "Domestic Shorthair", "Maine Coon", "Persian", "Ragdoll",
+		"Russian Blue", "Scottish Fold", "Siamese", "Sphynx",
+	},
+	"rabbit": {
+		"Mixed Breed", "Holland Lop", "Lionhead", "Mini Rex", "Netherland Dwarf",
+	},
+}
+
+// seedBreeds adds any default breeds missing from the reference list.
+func seedBreeds(db *gorm.DB) error {
+	var breeds []models.Breed
+	for species, names := range defaultBreeds {
+		for _, name := range names {
+			breeds = append(breeds, models.Breed{Species: species, Name: name})
+		}
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&breeds).Error
+}
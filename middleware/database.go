@@ -3,6 +3,7 @@ package middleware
 import (
 	"fmt"
 	"pethub_api/models"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -13,18 +14,20 @@ var (
 	DBErr  error
 )
 
+// databaseDSN builds the connection string from the DB_* variables.
+func databaseDSN() string {
+	return fmt.Sprintf("host=%s port=%s dbname=%s user=%s password=%s sslmode=%s TimeZone=%s",
+		GetEnv("DB_HOST"), GetEnv("DB_PORT"), GetEnv("DB_NAME"),
+		GetEnv("DB_UNME"), GetEnv("DB_PWRD"), GetEnv("DB_SSLM"),
+		GetEnv("DB_TMEZ"))
+}
+
 // ConnectDB initializes the connection to the PostgreSQL database using
 // environment variables for configuration and assigns the connection
 // to the global variable DBConn. It returns true if there was an error
 // establishing the connection, otherwise false.
 func ConnectDB() bool {
-	// Database Confg
-	dns := fmt.Sprintf("host=%s port=%s dbname=%s user=%s password=%s sslmode=%s TimeZone=%s",
-		GetEnv("DB_HOST"), GetEnv("DB_PORT"), GetEnv("DB_NAME"),
-		GetEnv("DB_UNME"), GetEnv("DB_PWRD"), GetEnv("DB_SSLM"),
-		GetEnv("DB_TMEZ"))
-
-	DBConn, DBErr = gorm.Open(postgres.Open(dns), &gorm.Config{})
+	DBConn, DBErr = gorm.Open(postgres.Open(databaseDSN()), &gorm.Config{TranslateError: true})
 	if DBErr != nil {
 		fmt.Printf("Database connection error: %v\n", DBErr) // Debugging log
 		return true
@@ -33,8 +36,15 @@ func ConnectDB() bool {
 	// Debugging log to confirm successful connection
 	fmt.Println("Database connection established successfully")
 
+	// Lets realtime pushes wait for the transaction they describe to commit
+	if err := useCommitHooks(DBConn); err != nil {
+		fmt.Printf("Database connection error: %v\n", err)
+		DBErr = err
+		return true
+	}
+
 	// Auto-migrate models
-	DBConn.AutoMigrate(
+	if err := DBConn.AutoMigrate(
 		&models.AdopterAccount{},
 		&models.AdopterInfo{},
 		&models.ShelterAccount{},
@@ -43,6 +53,158 @@ func ConnectDB() bool {
 		&models.PetInfo{},
 		&models.PetMedia{},
 		&models.AdoptionSubmission{},
-	)
+		&models.Questionnaire{},
+		&models.QuestionnaireQuestion{},
+		&models.ApplicationAnswer{},
+		&models.ScreeningRule{},
+		&models.LetterTemplate{},
+		&models.ContractSignature{},
+		&models.SignedContract{},
+		&models.ScheduleInterview{},
+		&models.InterviewHistory{},
+		&models.InterviewRescheduleRequest{},
+		&models.ShelterAvailability{},
+		&models.ShelterBlackoutDate{},
+		&models.InterviewSlotBooking{},
+		&models.CalendarFeedToken{},
+		&models.HomeVisit{},
+		&models.HomeVisitItem{},
+		&models.HomeVisitPhoto{},
+		&models.Notification{},
+		&models.NotificationPreference{},
+		&models.NotificationSettings{},
+		&models.InterviewReminder{},
+		&models.OutboxMessage{},
+		&models.ApplicationMessage{},
+		&models.MessageAttachment{},
+		&models.ShelterStaff{},
+		&models.StaffNote{},
+		&models.StaffNoteRevision{},
+		&models.StaffNoteMention{},
+		&models.Breed{},
+		&models.PetMedicalRecord{},
+		&models.MedicalDocument{},
+		&models.MedicalOverdueAlert{},
+		&models.PetStatusHistory{},
+		&models.PetReturn{},
+	); err != nil {
+		fmt.Printf("Auto-migration failed: %v\n", err)
+	}
+
+	// Only one active application per adopter and pet; rejected and completed
+	// applications are left out so an adopter can re-apply later. Duplicates
+	// from before the rule would stop the index being built, so all but the
+	// furthest along (then newest) of each are expired first.
+	if runMigration(DBConn, "expire duplicate active applications", `UPDATE adoption_submissions
+		SET status = 'expired', reason_for_rejection = 'Duplicate of another active application for this pet'
+		WHERE application_id IN (
+			SELECT application_id FROM (
+				SELECT application_id, ROW_NUMBER() OVER (
+					PARTITION BY adopter_id, pet_id
+					ORDER BY CASE status WHEN 'approved' THEN 0 WHEN 'interview' THEN 1 WHEN 'in queue' THEN 2 ELSE 3 END,
+						created_at DESC, application_id DESC) AS rank
+				FROM adoption_submissions
+				WHERE status IN ('pending', 'in queue', 'interview', 'approved')
+			) ranked
+			WHERE rank > 1)`) {
+		runMigration(DBConn, "one active application per adopter and pet", `CREATE UNIQUE INDEX IF NOT EXISTS idx_adoption_submissions_active_adopter_pet
+		ON adoption_submissions (adopter_id, pet_id)
+		WHERE status IN ('pending', 'in queue', 'interview', 'approved')`)
+	}
+
+	// Contracts print the approval year in their document number; give
+	// applications approved before approval dates were recorded a fixed one.
+	runMigration(DBConn, "application approval dates", `UPDATE adoption_submissions
+		SET approved_at = updated_at
+		WHERE approved_at IS NULL AND status IN ('approved', 'completed')`)
+
+	// Interviews used to be stored as a UTC date plus a free HH:MM:SS string
+	// on the shelter's wall clock; carry those over to a single instant in
+	// each shelter's timezone, or the default zone where it has none.
+	if DBConn.Migrator().HasColumn("schedule_interview", "interview_time") {
+		defaultZone := GetEnv("DEFAULT_TIMEZONE")
+		if _, err := time.LoadLocation(defaultZone); err != nil || defaultZone == "" || defaultZone == "Local" {
+			defaultZone = "Asia/Manila"
+		}
+		runMigration(DBConn, "interview instants", `WITH zones AS (
+				SELECT i.interview_id, COALESCE((SELECT s.timezone FROM shelterinfo s
+					WHERE s.shelter_id = i.shelter_id
+						AND s.timezone IN (SELECT name FROM pg_timezone_names)), ?) AS zone
+				FROM schedule_interview i
+				WHERE i.interview_at IS NULL AND i.interview_date IS NOT NULL AND i.interview_time <> ''
+			)
+			UPDATE schedule_interview si
+			SET interview_at = ((si.interview_date AT TIME ZONE 'UTC')::date + si.interview_time::time) AT TIME ZONE zones.zone,
+				timezone = zones.zone
+			FROM zones
+			WHERE zones.interview_id = si.interview_id`, defaultZone)
+	}
+
+	// Notifications used to belong to adopters only; move them to the
+	// recipient columns and let adopter_id go empty for shelter notifications.
+	runMigration(DBConn, "notification recipients", `DO $$
+		BEGIN
+			IF EXISTS (SELECT 1 FROM information_schema.columns
+				WHERE table_name = 'notifications' AND column_name = 'adopter_id') THEN
+				UPDATE notifications
+				SET recipient_type = 'adopter', recipient_id = adopter_id
+				WHERE (recipient_id IS NULL OR recipient_id = 0) AND adopter_id IS NOT NULL;
+				ALTER TABLE notifications ALTER COLUMN adopter_id DROP NOT NULL;
+			END IF;
+		END $$`)
+
+	if err := seedBreeds(DBConn); err != nil {
+		fmt.Printf("Failed to seed breeds: %v\n", err)
+	}
+
+	// Pets used to carry only a static age; estimate a birthdate from it as
+	// of when the pet was listed so the age keeps counting up.
+	runMigration(DBConn, "pet birth dates", `UPDATE petinfo
+		SET birth_date = (created_at - CASE
+				WHEN age_type ILIKE 'week%' THEN make_interval(weeks => pet_age)
+				WHEN age_type ILIKE 'month%' THEN make_interval(months => pet_age)
+				ELSE make_interval(years => pet_age) END)::date,
+			birth_date_estimated = true
+		WHERE birth_date IS NULL AND pet_age > 0`)
+
+	// Pets listed before intake was tracked came in when they were listed,
+	// and left when they were last updated if adopted or archived since.
+	runMigration(DBConn, "pet intake dates", `UPDATE petinfo SET intake_date = created_at::date WHERE intake_date IS NULL`)
+	runMigration(DBConn, "pet departure dates", `UPDATE petinfo SET left_shelter_at = updated_at
+		WHERE left_shelter_at IS NULL AND status IN ('adopted', 'archived')`)
+	runMigration(DBConn, "baseline pet status history", `INSERT INTO pet_status_history (pet_id, old_status, new_status, actor_role, reason, created_at)
+		SELECT pet_id, '', status, 'system', 'Status when history tracking began', NOW()
+		FROM petinfo p
+		WHERE NOT EXISTS (SELECT 1 FROM pet_status_history h WHERE h.pet_id = p.pet_id)`)
+
+	// Pet status history is append-only.
+	runMigration(DBConn, "pet status history guard", `CREATE OR REPLACE FUNCTION prevent_pet_status_history_change() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'pet status history is append-only';
+		END;
+		$$ LANGUAGE plpgsql`)
+	runMigration(DBConn, "drop pet status history trigger", `DROP TRIGGER IF EXISTS pet_status_history_append_only ON pet_status_history`)
+	runMigration(DBConn, "pet status history trigger", `CREATE TRIGGER pet_status_history_append_only BEFORE UPDATE OR DELETE ON pet_status_history
+		FOR EACH ROW EXECUTE FUNCTION prevent_pet_status_history_change()`)
+
+	// Signed contracts are evidence; once written they must never change.
+	runMigration(DBConn, "signed contract guard", `CREATE OR REPLACE FUNCTION prevent_signed_contract_change() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'signed contracts are immutable';
+		END;
+		$$ LANGUAGE plpgsql`)
+	runMigration(DBConn, "drop signed contract trigger", `DROP TRIGGER IF EXISTS signed_contracts_immutable ON signed_contracts`)
+	runMigration(DBConn, "signed contract trigger", `CREATE TRIGGER signed_contracts_immutable BEFORE UPDATE OR DELETE ON signed_contracts
+		FOR EACH ROW EXECUTE FUNCTION prevent_signed_contract_change()`)
 	return false
 }
+
+// runMigration runs one raw SQL migration step and logs it when it fails,
+// so a constraint or backfill that did not apply is not missed.
+func runMigration(db *gorm.DB, name, sql string, args ...interface{}) bool {
+	if err := db.Exec(sql, args...).Error; err != nil {
+		fmt.Printf("Migration %q failed: %v\n", name, err)
+		return false
+	}
+	return true
+}
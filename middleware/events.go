@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"log"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// DomainEvent is something that happened in the adoption flow that other
+// parts of the API react to, e.g. an application changing status.
+type DomainEvent interface {
+	EventName() string
+}
+
+// EventHandler reacts to an event. db is the connection or transaction the
+// event was published on, so work done by the handler commits or rolls back
+// together with the change that caused it.
+type EventHandler func(db *gorm.DB, event DomainEvent) error
+
+var eventHandlers = struct {
+	sync.RWMutex
+	byName map[string][]EventHandler
+}{byName: make(map[string][]EventHandler)}
+
+// SubscribeEvent registers a handler for every event with the given name.
+func SubscribeEvent(name string, handler EventHandler) {
+	eventHandlers.Lock()
+	defer eventHandlers.Unlock()
+	eventHandlers.byName[name] = append(eventHandlers.byName[name], handler)
+}
+
+// PublishEvent runs the handlers for event in registration order and stops at
+// the first error.
+func PublishEvent(db *gorm.DB, event DomainEvent) error {
+	eventHandlers.RLock()
+	handlers := append([]EventHandler(nil), eventHandlers.byName[event.EventName()]...)
+	eventHandlers.RUnlock()
+
+	for _, handler := range handlers {
+		if err := handler(db, event); err != nil {
+			log.Printf("Event %s: handler failed: %v", event.EventName(), err)
+			return err
+		}
+	}
+	return nil
+}
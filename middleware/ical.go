@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ICSEvent is one VEVENT of an iCalendar file.
+type ICSEvent struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Start       time.Time
+	End         time.Time
+	Cancelled   bool
+	Sequence    int
+	Updated     time.Time
+	Organizer   string // email address, optional
+	Attendees   []string
+}
+
+// BuildICS renders events as an RFC 5545 calendar. method is "PUBLISH" for
+// downloads and feeds, "REQUEST" or "CANCEL" for emailed invites.
+func BuildICS(name, method string, events []ICSEvent) []byte {
+	var b bytes.Buffer
+	line := func(format string, args ...interface{}) {
+		writeICSLine(&b, fmt.Sprintf(format, args...))
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//PetHub//Interviews//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:%s", method)
+	if name != "" {
+		line("X-WR-CALNAME:%s", icsEscape(name))
+	}
+	for _, event := range events {
+		status := "CONFIRMED"
+		if event.Cancelled {
+			status = "CANCELLED"
+		}
+		line("BEGIN:VEVENT")
+		line("UID:%s", event.UID)
+		line("DTSTAMP:%s", icsTime(event.Updated))
+		line("DTSTART:%s", icsTime(event.Start))
+		line("DTEND:%s", icsTime(event.End))
+		line("SEQUENCE:%d", event.Sequence)
+		line("STATUS:%s", status)
+		line("SUMMARY:%s", icsEscape(event.Summary))
+		if event.Description != "" {
+			line("DESCRIPTION:%s", icsEscape(event.Description))
+		}
+		if event.Location != "" {
+			line("LOCATION:%s", icsEscape(event.Location))
+		}
+		if event.Organizer != "" {
+			line("ORGANIZER:mailto:%s", event.Organizer)
+		}
+		for _, attendee := range event.Attendees {
+			line("ATTENDEE;ROLE=REQ-PARTICIPANT:mailto:%s", attendee)
+		}
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return b.Bytes()
+}
+
+func icsTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+func icsEscape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(text)
+}
+
+// writeICSLine folds content lines at 75 octets without splitting a UTF-8
+// character, as RFC 5545 requires.
+func writeICSLine(b *bytes.Buffer, text string) {
+	limit := 75
+	for len(text) > limit {
+		cut := limit
+		for cut > 0 && text[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(text[:cut])
+		b.WriteString("\r\n ")
+		text = text[cut:]
+		limit = 74 // the leading space counts towards the next line
+	}
+	b.WriteString(text)
+	b.WriteString("\r\n")
+}
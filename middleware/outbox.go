@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"pethub_api/models"
+
+	"gorm.io/gorm"
+)
+
+// OutboxHandler delivers one outbox message. Returning an error schedules a
+// retry.
+type OutboxHandler func(db *gorm.DB, payload []byte) error
+
+var outboxHandlers = struct {
+	sync.RWMutex
+	byTopic map[string]OutboxHandler
+}{byTopic: make(map[string]OutboxHandler)}
+
+// RegisterOutboxHandler sets the handler for a topic.
+func RegisterOutboxHandler(topic string, handler OutboxHandler) {
+	outboxHandlers.Lock()
+	defer outboxHandlers.Unlock()
+	outboxHandlers.byTopic[topic] = handler
+}
+
+// OutboxEntry describes a message to enqueue.
+type OutboxEntry struct {
+	Topic     string
+	Payload   interface{}
+	GroupKey  string
+	SendAfter time.Time // zero for now
+	Held      bool      // parked until something releases it
+}
+
+// EnqueueOutbox writes a message to the outbox. Pass the transaction that
+// makes the change the message is about, so both commit or neither does.
+func EnqueueOutbox(db *gorm.DB, entry OutboxEntry) error {
+	payload, err := json.Marshal(entry.Payload)
+	if err != nil {
+		return err
+	}
+	msg := models.OutboxMessage{
+		Topic:         entry.Topic,
+		Payload:       string(payload),
+		GroupKey:      entry.GroupKey,
+		Status:        "pending",
+		MaxAttempts:   8,
+		NextAttemptAt: entry.SendAfter,
+		CreatedAt:     time.Now(),
+	}
+	if msg.NextAttemptAt.IsZero() {
+		msg.NextAttemptAt = msg.CreatedAt
+	}
+	if entry.Held {
+		msg.Status = "held"
+	}
+	return db.Create(&msg).Error
+}
+
+// outboxBackoff is the wait before retry number attempts+1: 30s doubling
+// each time, capped at six hours.
+func outboxBackoff(attempts int) time.Duration {
+	delay := 30 * time.Second
+	for i := 1; i < attempts && delay < 6*time.Hour; i++ {
+		delay *= 2
+	}
+	if delay > 6*time.Hour {
+		delay = 6 * time.Hour
+	}
+	return delay
+}
+
+// outboxClaimTimeout is how long a message may stay in sending before it is
+// assumed its instance died and it is tried again.
+const outboxClaimTimeout = 15 * time.Minute
+
+// DispatchOutbox delivers the messages that are due. Rows are claimed with
+// SKIP LOCKED, so several instances can dispatch side by side.
+func DispatchOutbox(db *gorm.DB) error {
+	now := time.Now()
+	if err := db.Model(&models.OutboxMessage{}).
+		Where("status = ? AND claimed_at < ?", "sending", now.Add(-outboxClaimTimeout)).
+		Update("status", "pending").Error; err != nil {
+		return err
+	}
+
+	var due []models.OutboxMessage
+	if err := db.Raw(`UPDATE outbox_messages SET status = 'sending', attempts = attempts + 1, claimed_at = ?
+		WHERE message_id IN (
+			SELECT message_id FROM outbox_messages
+			WHERE status = 'pending' AND next_attempt_at <= ?
+			ORDER BY next_attempt_at LIMIT 100
+			FOR UPDATE SKIP LOCKED)
+		RETURNING *`, now, now).Scan(&due).Error; err != nil {
+		return err
+	}
+
+	for _, msg := range due {
+		outboxHandlers.RLock()
+		handler, ok := outboxHandlers.byTopic[msg.Topic]
+		outboxHandlers.RUnlock()
+
+		var err error
+		if ok {
+			err = handler(db, []byte(msg.Payload))
+		} else {
+			err = fmt.Errorf("no handler for topic %q", msg.Topic)
+		}
+
+		updates := map[string]interface{}{"claimed_at": nil}
+		switch {
+		case err == nil:
+			updates["status"] = "sent"
+			updates["sent_at"] = time.Now()
+			updates["last_error"] = ""
+		case !ok || msg.Attempts >= msg.MaxAttempts:
+			log.Printf("Outbox: message %d (%s) is dead after %d attempts: %v", msg.MessageID, msg.Topic, msg.Attempts, err)
+			updates["status"] = "dead"
+			updates["last_error"] = err.Error()
+		default:
+			log.Printf("Outbox: message %d (%s) failed, retrying: %v", msg.MessageID, msg.Topic, err)
+			updates["status"] = "pending"
+			updates["last_error"] = err.Error()
+			updates["next_attempt_at"] = time.Now().Add(outboxBackoff(msg.Attempts))
+		}
+		if err := db.Model(&models.OutboxMessage{}).
+			Where("message_id = ?", msg.MessageID).
+			Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplayOutboxMessage puts a dead (or held) message back in the queue with
+// a fresh set of attempts.
+func ReplayOutboxMessage(db *gorm.DB, messageID uint) (int64, error) {
+	result := db.Model(&models.OutboxMessage{}).
+		Where("message_id = ? AND status IN ?", messageID, []string{"dead", "held"}).
+		Updates(map[string]interface{}{
+			"status":          "pending",
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,232 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg" // register JPEG decoder for PDFDocument.Image
+	_ "image/png"  // register PNG decoder for PDFDocument.Image
+	"strings"
+)
+
+// A4 page size in PDF points
+const (
+	PDFPageWidth  = 595.0
+	PDFPageHeight = 842.0
+)
+
+type pdfImage struct {
+	name   string
+	width  int
+	height int
+	data   []byte // zlib-compressed RGB samples
+}
+
+// PDFDocument is a minimal single-font PDF writer covering what the API needs
+// for generated letters: text, lines and raster images. Coordinates are in
+// points measured from the top-left corner of the page.
+type PDFDocument struct {
+	pages  []*bytes.Buffer
+	images []pdfImage
+}
+
+func NewPDF() *PDFDocument {
+	doc := &PDFDocument{}
+	doc.AddPage()
+	return doc
+}
+
+func (d *PDFDocument) AddPage() {
+	d.pages = append(d.pages, new(bytes.Buffer))
+}
+
+func (d *PDFDocument) page() *bytes.Buffer {
+	return d.pages[len(d.pages)-1]
+}
+
+// Text draws a single line of text with its baseline at y.
+func (d *PDFDocument) Text(x, y, size float64, bold bool, text string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.page(), "BT /%s %.2f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, PDFPageHeight-y, pdfEscape(text))
+}
+
+// TextWidth estimates the rendered width of text in Helvetica.
+func TextWidth(text string, size float64) float64 {
+	return float64(len([]rune(text))) * size * 0.5
+}
+
+// WrapText draws text wrapped to width starting at y and returns the y
+// position below the last line. Blank lines in text are kept as paragraph
+// breaks.
+func (d *PDFDocument) WrapText(x, y, width, size float64, bold bool, text string) float64 {
+	lineHeight := size * 1.4
+	for _, paragraph := range strings.Split(text, "\n") {
+		words := strings.Fields(paragraph)
+		if len(words) == 0 {
+			y += lineHeight
+			continue
+		}
+		line := ""
+		for _, word := range words {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if line != "" && TextWidth(candidate, size) > width {
+				y = d.ensureSpace(y, lineHeight)
+				d.Text(x, y, size, bold, line)
+				y += lineHeight
+				line = word
+				continue
+			}
+			line = candidate
+		}
+		y = d.ensureSpace(y, lineHeight)
+		d.Text(x, y, size, bold, line)
+		y += lineHeight
+	}
+	return y
+}
+
+// ensureSpace starts a new page when the next line would run off the bottom
+// margin and returns the y position to draw at.
+func (d *PDFDocument) ensureSpace(y, needed float64) float64 {
+	if y+needed > PDFPageHeight-50 {
+		d.AddPage()
+		return 60
+	}
+	return y
+}
+
+// Line draws a straight line between two points.
+func (d *PDFDocument) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(d.page(), "%.2f w %.2f %.2f m %.2f %.2f l S\n", width, x1, PDFPageHeight-y1, x2, PDFPageHeight-y2)
+}
+
+// Image decodes a PNG or JPEG and draws it with its top-left corner at x, y.
+// Transparent pixels are flattened onto white.
+func (d *PDFDocument) Image(raw []byte, x, y, w, h float64) error {
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %v", err)
+	}
+
+	bounds := img.Bounds()
+	samples := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+	for py := bounds.Min.Y; py < bounds.Max.Y; py++ {
+		for px := bounds.Min.X; px < bounds.Max.X; px++ {
+			c := color.NRGBAModel.Convert(img.At(px, py)).(color.NRGBA)
+			a := int(c.A)
+			samples = append(samples,
+				byte((int(c.R)*a+255*(255-a))/255),
+				byte((int(c.G)*a+255*(255-a))/255),
+				byte((int(c.B)*a+255*(255-a))/255),
+			)
+		}
+	}
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(samples)
+	zw.Close()
+
+	name := fmt.Sprintf("Im%d", len(d.images)+1)
+	d.images = append(d.images, pdfImage{
+		name:   name,
+		width:  bounds.Dx(),
+		height: bounds.Dy(),
+		data:   compressed.Bytes(),
+	})
+
+	fmt.Fprintf(d.page(), "q %.2f 0 0 %.2f %.2f %.2f cm /%s Do Q\n", w, h, x, PDFPageHeight-y-h, name)
+	return nil
+}
+
+// Bytes serialises the document.
+func (d *PDFDocument) Bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+
+	writeObj := func(body string, stream []byte) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\n", len(offsets), body)
+		if stream != nil {
+			out.WriteString("stream\n")
+			out.Write(stream)
+			out.WriteString("\nendstream\n")
+		}
+		out.WriteString("endobj\n")
+	}
+
+	// Fixed objects: 1 catalog, 2 page tree, 3 regular font, 4 bold font,
+	// then images, then a page and content stream pair per page.
+	imageStart := 5
+	pageStart := imageStart + len(d.images)
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	writeObj("<< /Type /Catalog /Pages 2 0 R >>", nil)
+
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", pageStart+i*2)
+	}
+	writeObj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)), nil)
+
+	writeObj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>", nil)
+	writeObj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>", nil)
+
+	xobjects := make([]string, len(d.images))
+	for i, img := range d.images {
+		writeObj(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode /Length %d >>",
+			img.width, img.height, len(img.data)), img.data)
+		xobjects[i] = fmt.Sprintf("/%s %d 0 R", img.name, imageStart+i)
+	}
+
+	resources := "<< /Font << /F1 3 0 R /F2 4 0 R >>"
+	if len(xobjects) > 0 {
+		resources += " /XObject << " + strings.Join(xobjects, " ") + " >>"
+	}
+	resources += " >>"
+
+	for i, content := range d.pages {
+		writeObj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources %s /Contents %d 0 R >>",
+			PDFPageWidth, PDFPageHeight, resources, pageStart+i*2+1), nil)
+		writeObj(fmt.Sprintf("<< /Length %d >>", content.Len()), content.Bytes())
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// pdfEscape converts text to a WinAnsi string literal body, replacing
+// characters the standard fonts cannot show.
+func pdfEscape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\t':
+			b.WriteString("    ")
+		case r >= 32 && r < 127:
+			b.WriteRune(r)
+		case r >= 160 && r <= 255:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// RealtimeMessage is what connected clients receive on their stream.
+type RealtimeMessage struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// RealtimeBroker carries messages between API instances. Every instance
+// subscribes once and receives everything published by any instance,
+// including itself.
+type RealtimeBroker interface {
+	Publish(channel string, payload []byte) error
+	Subscribe(handler func(channel string, payload []byte)) error
+}
+
+// MemoryBroker delivers messages within the current process only. It is
+// enough for a single instance.
+type MemoryBroker struct {
+	mu       sync.RWMutex
+	handlers []func(channel string, payload []byte)
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{}
+}
+
+func (b *MemoryBroker) Publish(channel string, payload []byte) error {
+	b.mu.RLock()
+	handlers := append([]func(string, []byte){}, b.handlers...)
+	b.mu.RUnlock()
+	for _, handler := range handlers {
+		handler(channel, payload)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(handler func(channel string, payload []byte)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+	return nil
+}
+
+// realtimeBuffer is how many messages a slow client may fall behind before
+// further messages to it are dropped.
+const realtimeBuffer = 32
+
+// RealtimeHub fans messages out to the clients connected to this instance.
+type RealtimeHub struct {
+	broker  RealtimeBroker
+	mu      sync.RWMutex
+	clients map[string]map[chan []byte]struct{}
+}
+
+// NewRealtimeHub creates a hub that sends and receives through broker.
+func NewRealtimeHub(broker RealtimeBroker) (*RealtimeHub, error) {
+	hub := &RealtimeHub{
+		broker:  broker,
+		clients: make(map[string]map[chan []byte]struct{}),
+	}
+	if err := broker.Subscribe(hub.deliver); err != nil {
+		return nil, err
+	}
+	return hub, nil
+}
+
+// Realtime is the hub used by the API. It starts in-memory; SetupRealtime
+// swaps in the broker selected by REALTIME_BROKER.
+var Realtime, _ = NewRealtimeHub(NewMemoryBroker())
+
+// RealtimeChannel names the stream of one recipient, e.g. "adopter:12".
+func RealtimeChannel(recipientType string, recipientID uint) string {
+	return fmt.Sprintf("%s:%d", recipientType, recipientID)
+}
+
+// Publish sends a message of the given type to everyone listening on
+// channel, on any instance.
+func (h *RealtimeHub) Publish(channel, msgType string, data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(RealtimeMessage{Type: msgType, Data: encoded})
+	if err != nil {
+		return err
+	}
+	if err := h.broker.Publish(channel, payload); err != nil {
+		log.Printf("Realtime: failed to publish %s to %s: %v", msgType, channel, err)
+		return err
+	}
+	return nil
+}
+
+// PublishAfterCommit publishes once db's transaction commits, so clients are
+// never told about a change that was rolled back.
+func (h *RealtimeHub) PublishAfterCommit(db *gorm.DB, channel, msgType string, data interface{}) {
+	AfterCommit(db, func() { h.Publish(channel, msgType, data) })
+}
+
+// Subscribe registers a local client on channel. The returned function
+// unregisters it and must be called when the client goes away.
+func (h *RealtimeHub) Subscribe(channel string) (<-chan []byte, func()) {
+	messages := make(chan []byte, realtimeBuffer)
+
+	h.mu.Lock()
+	if h.clients[channel] == nil {
+		h.clients[channel] = make(map[chan []byte]struct{})
+	}
+	h.clients[channel][messages] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return messages, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.clients[channel], messages)
+			if len(h.clients[channel]) == 0 {
+				delete(h.clients, channel)
+			}
+			h.mu.Unlock()
+		})
+	}
+}
+
+// deliver hands a message from the broker to the local clients of channel.
+// A client whose buffer is full misses the message rather than holding up
+// everyone else.
+func (h *RealtimeHub) deliver(channel string, payload []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for messages := range h.clients[channel] {
+		select {
+		case messages <- payload:
+		default:
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// realtimePGChannel is the Postgres NOTIFY channel shared by all instances.
+const realtimePGChannel = "pethub_realtime"
+
+// PostgresBroker relays messages between API instances with Postgres
+// LISTEN/NOTIFY, so no extra infrastructure is needed. NOTIFY payloads are
+// limited to 8000 bytes, which is plenty for notifications and counters.
+type PostgresBroker struct {
+	dsn string
+}
+
+type pgEnvelope struct {
+	Channel string          `json:"channel"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+func NewPostgresBroker(dsn string) *PostgresBroker {
+	return &PostgresBroker{dsn: dsn}
+}
+
+func (b *PostgresBroker) Publish(channel string, payload []byte) error {
+	envelope, err := json.Marshal(pgEnvelope{Channel: channel, Payload: payload})
+	if err != nil {
+		return err
+	}
+	return DBConn.Exec("SELECT pg_notify(?, ?)", realtimePGChannel, string(envelope)).Error
+}
+
+// Subscribe listens on a dedicated connection in the background and
+// reconnects if it drops.
+func (b *PostgresBroker) Subscribe(handler func(channel string, payload []byte)) error {
+	go func() {
+		for {
+			if err := b.listen(handler); err != nil {
+				log.Println("Realtime: postgres listener stopped, reconnecting:", err)
+			}
+			time.Sleep(5 * time.Second)
+		}
+	}()
+	return nil
+}
+
+func (b *PostgresBroker) listen(handler func(channel string, payload []byte)) error {
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, b.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	if _, err := conn.Exec(ctx, "LISTEN "+realtimePGChannel); err != nil {
+		return err
+	}
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var envelope pgEnvelope
+		if err := json.Unmarshal([]byte(notification.Payload), &envelope); err != nil {
+			log.Println("Realtime: ignoring malformed notification:", err)
+			continue
+		}
+		handler(envelope.Channel, envelope.Payload)
+	}
+}
+
+// SetupRealtime picks the broker named by REALTIME_BROKER: "memory" (the
+// default, single instance) or "postgres" (several instances behind a load
+// balancer). Call it after ConnectDB.
+func SetupRealtime() {
+	if GetEnv("REALTIME_BROKER") != "postgres" {
+		return
+	}
+	hub, err := NewRealtimeHub(NewPostgresBroker(databaseDSN()))
+	if err != nil {
+		log.Println("Realtime: falling back to in-memory broker:", err)
+		return
+	}
+	Realtime = hub
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ScheduledJob is background work that runs at a fixed interval.
+type ScheduledJob struct {
+	Name  string
+	Every time.Duration
+	Run   func(db *gorm.DB) error
+}
+
+var scheduledJobs = struct {
+	sync.Mutex
+	jobs    []ScheduledJob
+	started bool
+}{}
+
+// ScheduleJob registers a job. Jobs registered after StartScheduler are
+// started straight away.
+func ScheduleJob(name string, every time.Duration, run func(db *gorm.DB) error) {
+	job := ScheduledJob{Name: name, Every: every, Run: run}
+
+	scheduledJobs.Lock()
+	defer scheduledJobs.Unlock()
+	scheduledJobs.jobs = append(scheduledJobs.jobs, job)
+	if scheduledJobs.started {
+		go runScheduledJob(job)
+	}
+}
+
+// StartScheduler starts every registered job in the background.
+func StartScheduler() {
+	scheduledJobs.Lock()
+	defer scheduledJobs.Unlock()
+	if scheduledJobs.started {
+		return
+	}
+	scheduledJobs.started = true
+	for _, job := range scheduledJobs.jobs {
+		go runScheduledJob(job)
+	}
+}
+
+func runScheduledJob(job ScheduledJob) {
+	ticker := time.NewTicker(job.Every)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := runJobOnce(DBConn, job); err != nil {
+			log.Printf("Scheduler: job %s failed: %v", job.Name, err)
+		}
+	}
+}
+
+// jobLockKey maps a job name onto a Postgres advisory lock key.
+func jobLockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("pethub_job:" + name))
+	return int64(h.Sum64())
+}
+
+// runJobOnce runs job if no other instance is running it right now. The
+// advisory lock belongs to the database session, so the lock and unlock
+// happen on one pinned connection while the job itself uses the pool.
+func runJobOnce(db *gorm.DB, job ScheduledJob) error {
+	return db.Connection(func(conn *gorm.DB) error {
+		var locked bool
+		if err := conn.Raw("SELECT pg_try_advisory_lock(?)", jobLockKey(job.Name)).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+		defer conn.Exec("SELECT pg_advisory_unlock(?)", jobLockKey(job.Name))
+		return job.Run(db)
+	})
+}
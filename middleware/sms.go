@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// SMSSender sends a text message to a phone number in E.164 format.
+type SMSSender interface {
+	SendSMS(to, message string) error
+}
+
+// LogSMSSender writes messages to the log instead of sending them. It is the
+// default, for development.
+type LogSMSSender struct{}
+
+func (LogSMSSender) SendSMS(to, message string) error {
+	log.Printf("SMS to %s: %s", to, message)
+	return nil
+}
+
+// HTTPSMSSender posts messages to an SMS gateway. URL and Body are
+// text/template strings given .To and .Message; use {{json .Message}} in a
+// JSON body and {{urlquery .Message}} in a query string.
+type HTTPSMSSender struct {
+	Method        string
+	URL           *template.Template
+	Body          *template.Template
+	ContentType   string
+	Authorization string
+	Client        *http.Client
+}
+
+var smsTemplateFuncs = template.FuncMap{
+	"json": func(s string) (string, error) {
+		encoded, err := json.Marshal(s)
+		return string(encoded), err
+	},
+}
+
+// NewHTTPSMSSender parses the gateway templates.
+func NewHTTPSMSSender(method, url, body, contentType, authorization string) (*HTTPSMSSender, error) {
+	if url == "" {
+		return nil, errors.New("SMS gateway URL is not set")
+	}
+	urlTemplate, err := template.New("url").Funcs(smsTemplateFuncs).Parse(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMS gateway URL template: %w", err)
+	}
+	bodyTemplate, err := template.New("body").Funcs(smsTemplateFuncs).Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMS gateway body template: %w", err)
+	}
+	if method == "" {
+		method = http.MethodPost
+	}
+	return &HTTPSMSSender{
+		Method:        strings.ToUpper(method),
+		URL:           urlTemplate,
+		Body:          bodyTemplate,
+		ContentType:   contentType,
+		Authorization: authorization,
+		Client:        &http.Client{Timeout: 15 * time.Second},
+	}, nil
+}
+
+func (s *HTTPSMSSender) SendSMS(to, message string) error {
+	data := struct{ To, Message string }{to, message}
+
+	var url, body bytes.Buffer
+	if err := s.URL.Execute(&url, data); err != nil {
+		return err
+	}
+	if err := s.Body.Execute(&body, data); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(s.Method, url.String(), &body)
+	if err != nil {
+		return err
+	}
+	if s.ContentType != "" {
+		req.Header.Set("Content-Type", s.ContentType)
+	}
+	if s.Authorization != "" {
+		req.Header.Set("Authorization", s.Authorization)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("SMS gateway answered %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// SMS is the sender used by the API. SetupSMS replaces it with the driver
+// named by SMS_DRIVER.
+var SMS SMSSender = LogSMSSender{}
+
+// SetupSMS configures the SMS driver from the environment:
+//
+//	SMS_DRIVER                log (default) or http
+//	SMS_GATEWAY_URL           URL template, e.g. https://sms.example.com/send
+//	SMS_GATEWAY_METHOD        defaults to POST
+//	SMS_GATEWAY_BODY          body template, defaults to {"to":..,"message":..}
+//	SMS_GATEWAY_CONTENT_TYPE  defaults to application/json
+//	SMS_GATEWAY_AUTHORIZATION sent as the Authorization header, if set
+func SetupSMS() {
+	if GetEnv("SMS_DRIVER") != "http" {
+		return
+	}
+	body := GetEnv("SMS_GATEWAY_BODY")
+	if body == "" {
+		body = `{"to":{{json .To}},"message":{{json .Message}}}`
+	}
+	contentType := GetEnv("SMS_GATEWAY_CONTENT_TYPE")
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	sender, err := NewHTTPSMSSender(GetEnv("SMS_GATEWAY_METHOD"), GetEnv("SMS_GATEWAY_URL"), body,
+		contentType, GetEnv("SMS_GATEWAY_AUTHORIZATION"))
+	if err != nil {
+		log.Println("SMS: falling back to the log driver:", err)
+		return
+	}
+	SMS = sender
+}
+
+var e164Pattern = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+// NormalizePhoneE164 turns a phone number as people type it into E.164,
+// e.g. "0917 123 4567" becomes "+639171234567" with country code "63".
+// Numbers starting with + or 00 keep their own country code.
+func NormalizePhoneE164(raw, countryCode string) (string, error) {
+	number := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '(', ')', '.', '\t':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(raw))
+
+	switch {
+	case strings.HasPrefix(number, "+"):
+	case strings.HasPrefix(number, "00"):
+		number = "+" + number[2:]
+	case strings.HasPrefix(number, "0"):
+		number = "+" + countryCode + number[1:]
+	case strings.HasPrefix(number, countryCode) && len(number) > len(countryCode)+7:
+		number = "+" + number
+	default:
+		number = "+" + countryCode + number
+	}
+
+	if !e164Pattern.MatchString(number) {
+		return "", fmt.Errorf("%q is not a valid phone number", raw)
+	}
+	return number, nil
+}
+
+// NormalizePhone normalizes with the default country code from
+// SMS_DEFAULT_COUNTRY_CODE (63, the Philippines, if unset).
+func NormalizePhone(raw string) (string, error) {
+	countryCode := strings.TrimPrefix(GetEnv("SMS_DEFAULT_COUNTRY_CODE"), "+")
+	if countryCode == "" {
+		countryCode = "63"
+	}
+	return NormalizePhoneE164(raw, countryCode)
+}
@@ -0,0 +1,278 @@
+package middleware
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Limits on what ReadXLSX inflates, since a small upload can unpack into
+// far more XML than it looks
+const (
+	xlsxMaxPartSize = 32 * 1024 * 1024
+	xlsxMaxColumns  = 256
+)
+
+// ReadXLSX returns the cells of the first worksheet of an XLSX workbook as
+// rows of strings. It covers what spreadsheet imports need: shared, inline
+// and plain values. Numbers, including dates, come back as stored, so a
+// date cell reads as its Excel serial number. Sheets with more than maxRows
+// rows or 256 columns are rejected.
+func ReadXLSX(data []byte, maxRows int) ([][]string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errors.New("not an XLSX file")
+	}
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	sheetPath, err := firstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var shared []string
+	if file, ok := files["xl/sharedStrings.xml"]; ok {
+		var sst struct {
+			Items []xlsxRichText `xml:"si"`
+		}
+		if err := readZipXML(file, &sst); err != nil {
+			return nil, fmt.Errorf("reading shared strings: %w", err)
+		}
+		for _, item := range sst.Items {
+			shared = append(shared, item.String())
+		}
+	}
+
+	file, ok := files[sheetPath]
+	if !ok {
+		return nil, errors.New("workbook has no worksheet")
+	}
+	var sheet struct {
+		Rows []struct {
+			Index int `xml:"r,attr"`
+			Cells []struct {
+				Ref    string       `xml:"r,attr"`
+				Type   string       `xml:"t,attr"`
+				Value  string       `xml:"v"`
+				Inline xlsxRichText `xml:"is"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := readZipXML(file, &sheet); err != nil {
+		return nil, fmt.Errorf("reading worksheet: %w", err)
+	}
+
+	var rows [][]string
+	for i, row := range sheet.Rows {
+		// Rows and cells can be sparse; place them by their references
+		index := row.Index - 1
+		if index < 0 {
+			index = i
+		}
+		if index >= maxRows {
+			return nil, fmt.Errorf("worksheet has more than %d rows", maxRows)
+		}
+		for len(rows) <= index {
+			rows = append(rows, nil)
+		}
+		var values []string
+		for j, cell := range row.Cells {
+			column := xlsxColumnIndex(cell.Ref)
+			if column < 0 {
+				column = j
+			}
+			if column >= xlsxMaxColumns {
+				return nil, fmt.Errorf("worksheet has more than %d columns", xlsxMaxColumns)
+			}
+			for len(values) <= column {
+				values = append(values, "")
+			}
+			switch cell.Type {
+			case "s":
+				n, err := strconv.Atoi(cell.Value)
+				if err != nil || n < 0 || n >= len(shared) {
+					return nil, fmt.Errorf("cell %s refers to a missing shared string", cell.Ref)
+				}
+				values[column] = shared[n]
+			case "inlineStr":
+				values[column] = cell.Inline.String()
+			case "b":
+				values[column] = map[string]string{"1": "true", "0": "false"}[cell.Value]
+			default:
+				values[column] = cell.Value
+			}
+		}
+		rows[index] = values
+	}
+	return rows, nil
+}
+
+// WriteXLSX builds a single-sheet XLSX workbook with rows as text cells.
+func WriteXLSX(sheetName string, rows [][]string) ([]byte, error) {
+	var sheet bytes.Buffer
+	sheet.WriteString(xml.Header)
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for i, row := range rows {
+		fmt.Fprintf(&sheet, `<row r="%d">`, i+1)
+		for j, value := range row {
+			if value == "" {
+				continue
+			}
+			fmt.Fprintf(&sheet, `<c r="%s%d" t="inlineStr"><is><t xml:space="preserve">`, xlsxColumnName(j), i+1)
+			if err := xml.EscapeText(&sheet, []byte(value)); err != nil {
+				return nil, err
+			}
+			sheet.WriteString(`</t></is></c>`)
+		}
+		sheet.WriteString(`</row>`)
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+
+	var name bytes.Buffer
+	if err := xml.EscapeText(&name, []byte(sheetName)); err != nil {
+		return nil, err
+	}
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="` + name.String() + `" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`},
+		{"xl/worksheets/sheet1.xml", sheet.String()},
+	}
+
+	var out bytes.Buffer
+	archive := zip.NewWriter(&out)
+	for _, part := range parts {
+		w, err := archive.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(w, part.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// xlsxRichText is a string item that is either plain or split into runs.
+type xlsxRichText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxRichText) String() string {
+	if len(t.Runs) == 0 {
+		return t.Text
+	}
+	var b strings.Builder
+	for _, run := range t.Runs {
+		b.WriteString(run.Text)
+	}
+	return b.String()
+}
+
+// firstSheetPath finds the part holding the workbook's first worksheet.
+func firstSheetPath(files map[string]*zip.File) (string, error) {
+	workbook, ok := files["xl/workbook.xml"]
+	if !ok {
+		return "", errors.New("not an XLSX file")
+	}
+	var wb struct {
+		Sheets []struct {
+			RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := readZipXML(workbook, &wb); err != nil {
+		return "", fmt.Errorf("reading workbook: %w", err)
+	}
+	if len(wb.Sheets) == 0 {
+		return "", errors.New("workbook has no worksheet")
+	}
+
+	if rels, ok := files["xl/_rels/workbook.xml.rels"]; ok {
+		var r struct {
+			Items []struct {
+				ID     string `xml:"Id,attr"`
+				Target string `xml:"Target,attr"`
+			} `xml:"Relationship"`
+		}
+		if err := readZipXML(rels, &r); err != nil {
+			return "", fmt.Errorf("reading workbook relationships: %w", err)
+		}
+		for _, item := range r.Items {
+			if item.ID != wb.Sheets[0].RelID {
+				continue
+			}
+			if strings.HasPrefix(item.Target, "/") {
+				return strings.TrimPrefix(item.Target, "/"), nil
+			}
+			return path.Join("xl", item.Target), nil
+		}
+	}
+	return "xl/worksheets/sheet1.xml", nil
+}
+
+func readZipXML(file *zip.File, v interface{}) error {
+	r, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return xml.NewDecoder(io.LimitReader(r, xlsxMaxPartSize)).Decode(v)
+}
+
+// xlsxColumnIndex turns the letters of a cell reference such as "AB12" into
+// a zero-based column index, or -1 if there are none. Indexes past any width
+// ReadXLSX accepts stop growing, so long references cannot overflow.
+func xlsxColumnIndex(ref string) int {
+	index := 0
+	letters := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' || index > xlsxMaxColumns {
+			break
+		}
+		index = index*26 + int(r-'A') + 1
+		letters++
+	}
+	if letters == 0 {
+		return -1
+	}
+	return index - 1
+}
+
+// xlsxColumnName turns a zero-based column index into letters: A, B, ... AA.
+func xlsxColumnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
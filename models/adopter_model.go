@@ -10,7 +10,9 @@ type AdopterAccount struct {
 	Username  string `gorm:"unique;not null" json:"username"`
 	Password  string `json:"password"`
 	Status    string `gorm:"default:'active'" json:"status"` // Add this line
-	CreatedAt time.Time
+	// Set once the adopter proves ownership of their email with a verification code
+	EmailVerified bool `gorm:"default:false" json:"email_verified"`
+	CreatedAt     time.Time
 }
 
 // TableName overrides default table name
@@ -31,6 +33,8 @@ type AdopterInfo struct {
 	Occupation    string       `json:"occupation"`
 	CivilStatus   string       `json:"civil_status"`
 	SocialMedia   string       `json:"social_media"`
+	// IANA timezone interview times are shown in
+	Timezone string `json:"timezone"`
 	
 	AdopterMedia  AdopterMedia `gorm:"foreignKey:AdopterID;references:AdopterID" json:"adoptermedia"`
 }
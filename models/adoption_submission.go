@@ -6,33 +6,38 @@ import (
 
 type AdoptionSubmission struct {
 	// Application Info
-	ApplicationID       uint      `json:"application_id" gorm:"primaryKey;autoIncrement"`
-	ShelterID           uint      `json:"shelter_id"`
-	PetID               uint      `json:"pet_id"`
-	AdopterID           uint      `json:"adopter_id"`
-	AltFName            string    `json:"alt_f_name" gorm:"not null"`
-	AltLName            string    `json:"alt_l_name" gorm:"not null"`
-	Relationship        string    `json:"relationship" gorm:"not null"`
-	AltContactNumber    string    `json:"alt_contact_number" gorm:"not null"`
-	AltEmail            string    `json:"alt_email" gorm:"not null"`
-	ReasonForAdoption   string    `json:"reason_for_adoption" gorm:"not null"`
-	IdealPetDescription string    `json:"ideal_pet_description"`
-	HousingSituation    string    `json:"housing_situation" gorm:"not null"`
-	PetsAtHome          string    `json:"pets_at_home"`
-	Allergies           string    `json:"allergies"`
-	FamilySupport       string    `json:"family_support"`
-	PastPets            string    `json:"past_pets"`
-	InterviewSetting    string    `json:"interview_setting"`
-	ImageID             uint      `json:"image_id"`
-	Status              string    `json:"status" gorm:"type:varchar(20);default:'pending'"`
-	ReasonForRejection  string    `json:"reason_for_rejection"`
-	CreatedAt           time.Time `json:"created_at"`
-	UpdatedAt           time.Time `gorm:"autoUpdateTime" json:"updated_at"`
-
-	Shelter           ShelterInfo       `json:"shelter"`
-	Adopter           AdopterInfo       `json:"adopter"`
-	Pet               PetInfo           `json:"pet"`
-	ScheduleInterview ScheduleInterview `gorm:"foreignKey:ApplicationID;references:ApplicationID"  json:"scheduleinterview"`
+	ApplicationID       uint       `json:"application_id" gorm:"primaryKey;autoIncrement"`
+	ShelterID           uint       `json:"shelter_id"`
+	PetID               uint       `json:"pet_id" gorm:"index"`
+	AdopterID           uint       `json:"adopter_id" gorm:"index"`
+	AltFName            string     `json:"alt_f_name" gorm:"not null"`
+	AltLName            string     `json:"alt_l_name" gorm:"not null"`
+	Relationship        string     `json:"relationship" gorm:"not null"`
+	AltContactNumber    string     `json:"alt_contact_number" gorm:"not null"`
+	AltEmail            string     `json:"alt_email" gorm:"not null"`
+	ReasonForAdoption   string     `json:"reason_for_adoption" gorm:"not null"`
+	IdealPetDescription string     `json:"ideal_pet_description"`
+	HousingSituation    string     `json:"housing_situation" gorm:"not null"`
+	PetsAtHome          string     `json:"pets_at_home"`
+	Allergies           string     `json:"allergies"`
+	FamilySupport       string     `json:"family_support"`
+	PastPets            string     `json:"past_pets"`
+	InterviewSetting    string     `json:"interview_setting"`
+	ImageID             uint       `json:"image_id"`
+	QuestionnaireID     uint       `json:"questionnaire_id"`
+	Status              string     `json:"status" gorm:"type:varchar(20);default:'pending'"`
+	ReasonForRejection  string     `json:"reason_for_rejection"`
+	ApprovedAt          *time.Time `json:"approved_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	// Last time the shelter was nudged about this application waiting
+	SLANudgedAt *time.Time `json:"-"`
+
+	Shelter           ShelterInfo         `json:"shelter"`
+	Adopter           AdopterInfo         `json:"adopter"`
+	Pet               PetInfo             `json:"pet"`
+	ScheduleInterview ScheduleInterview   `gorm:"foreignKey:ApplicationID;references:ApplicationID"  json:"scheduleinterview"`
+	Answers           []ApplicationAnswer `gorm:"foreignKey:ApplicationID;references:ApplicationID" json:"answers,omitempty"`
 }
 
 // TableName overrides default table name
@@ -63,17 +68,88 @@ func (ApplicationPhotos) TableName() string {
 }
 
 type ScheduleInterview struct {
-	InterviewID     uint      `json:"id" gorm:"primaryKey;autoIncrement"`
-	ApplicationID   uint      `json:"application_id"`
-	ShelterID       uint      `json:"shelter_id"`
-	AdopterID       uint      `json:"adopter_id"`
-	InterviewDate   time.Time `json:"interview_date"`
-	InterviewTime   string    `json:"interview_time"`
+	InterviewID   uint `json:"id" gorm:"primaryKey;autoIncrement"`
+	ApplicationID uint `json:"application_id"`
+	ShelterID     uint `json:"shelter_id"`
+	AdopterID     uint `json:"adopter_id"`
+	// The interview instant; Timezone is the IANA zone it was booked in
+	InterviewAt     time.Time `json:"interview_at" gorm:"type:timestamptz"`
+	Timezone        string    `json:"timezone"`
 	InterviewNotes  string    `json:"interview_notes"`
-	InterviewStatus string    `json:"interview_status" gorm:"type:varchar(20);default:'scheduled'"`
-	CreatedAt       time.Time `json:"created_at"`
+	InterviewStatus string    `json:"interview_status" gorm:"type:varchar(20);default:'scheduled'"` // scheduled, rescheduled, cancelled, no_show, completed, approved, rejected
+	CancelReason    string    `json:"cancel_reason"`
+	// Structured result recorded by the shelter after the interview
+	OutcomeRecommendation string     `json:"outcome_recommendation"` // recommend, do_not_recommend, needs_follow_up
+	OutcomeNotes          string     `json:"outcome_notes"`
+	OutcomeRecordedAt     *time.Time `json:"outcome_recorded_at"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Filled in for responses: the interview time as each party sees it
+	ShelterLocal InterviewLocalTime `gorm:"-" json:"shelter_local"`
+	AdopterLocal InterviewLocalTime `gorm:"-" json:"adopter_local"`
+}
+
+// InterviewLocalTime is an interview instant rendered in one party's timezone.
+type InterviewLocalTime struct {
+	Timezone string `json:"timezone"`
+	DateTime string `json:"date_time"` // RFC 3339 with the zone's offset
+	Date     string `json:"date"`      // YYYY-MM-DD
+	Time     string `json:"time"`      // HH:MM
+	Display  string `json:"display"`
 }
 
 func (ScheduleInterview) TableName() string {
 	return "schedule_interview"
 }
+
+// InterviewHistory is an append-only log of everything that happened to an
+// interview, so moved and cancelled interviews stay traceable.
+type InterviewHistory struct {
+	HistoryID     uint       `json:"history_id" gorm:"primaryKey;autoIncrement"`
+	InterviewID   uint       `json:"interview_id" gorm:"index"`
+	ApplicationID uint       `json:"application_id" gorm:"index"`
+	Action        string     `json:"action"`     // scheduled, rescheduled, cancelled, no_show, outcome_recorded, reschedule_requested, reschedule_accepted, reschedule_declined
+	ActorRole     string     `json:"actor_role"` // shelter or adopter
+	OldAt         *time.Time `json:"old_at,omitempty" gorm:"type:timestamptz"`
+	NewAt         *time.Time `json:"new_at,omitempty" gorm:"type:timestamptz"`
+	Reason        string     `json:"reason"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (InterviewHistory) TableName() string {
+	return "interview_history"
+}
+
+// InterviewRescheduleRequest is an adopter asking the shelter to move an
+// interview; the shelter accepts or declines it.
+type InterviewRescheduleRequest struct {
+	RequestID     uint       `json:"request_id" gorm:"primaryKey;autoIncrement"`
+	InterviewID   uint       `json:"interview_id" gorm:"index"`
+	ApplicationID uint       `json:"application_id" gorm:"index"`
+	ProposedAt    time.Time  `json:"proposed_at" gorm:"type:timestamptz"`
+	Timezone      string     `json:"timezone"` // zone the adopter proposed the time in
+	Reason        string     `json:"reason"`
+	Status        string     `json:"status" gorm:"type:varchar(20);default:'pending'"` // pending, accepted, declined
+	ResponseNote  string     `json:"response_note"`
+	CreatedAt     time.Time  `json:"created_at"`
+	RespondedAt   *time.Time `json:"responded_at"`
+}
+
+func (InterviewRescheduleRequest) TableName() string {
+	return "interview_reschedule_requests"
+}
+
+// InterviewReminder records a reminder sent before an interview. The
+// interview time is part of the key so a moved interview is reminded again.
+type InterviewReminder struct {
+	ReminderID  uint      `gorm:"primaryKey;autoIncrement" json:"reminder_id"`
+	InterviewID uint      `gorm:"uniqueIndex:idx_interview_reminder;not null" json:"interview_id"`
+	Kind        string    `gorm:"uniqueIndex:idx_interview_reminder;not null" json:"kind"` // 24h or 1h
+	InterviewAt time.Time `gorm:"uniqueIndex:idx_interview_reminder;type:timestamptz" json:"interview_at"`
+	SentAt      time.Time `json:"sent_at"`
+}
+
+func (InterviewReminder) TableName() string {
+	return "interview_reminders"
+}
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// ShelterAvailability is one recurring weekly window in which a shelter takes
+// interviews. The window is cut into SlotMinutes-long slots, each of which can
+// hold Capacity interviews.
+type ShelterAvailability struct {
+	AvailabilityID uint      `json:"availability_id" gorm:"primaryKey;autoIncrement"`
+	ShelterID      uint      `json:"shelter_id" gorm:"index"`
+	Weekday        int       `json:"weekday"`    // 0 = Sunday ... 6 = Saturday
+	StartTime      string    `json:"start_time"` // HH:MM
+	EndTime        string    `json:"end_time"`   // HH:MM
+	SlotMinutes    int       `json:"slot_minutes"`
+	Capacity       int       `json:"capacity" gorm:"default:1"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func (ShelterAvailability) TableName() string {
+	return "shelter_availability"
+}
+
+// ShelterBlackoutDate is a day on which no interview slots are offered.
+type ShelterBlackoutDate struct {
+	BlackoutID uint      `json:"blackout_id" gorm:"primaryKey;autoIncrement"`
+	ShelterID  uint      `json:"shelter_id" gorm:"uniqueIndex:idx_shelter_blackout_date"`
+	Date       time.Time `json:"date" gorm:"type:date;uniqueIndex:idx_shelter_blackout_date"`
+	Reason     string    `json:"reason"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (ShelterBlackoutDate) TableName() string {
+	return "shelter_blackout_dates"
+}
+
+// InterviewSlotBooking holds one seat of a published slot. The unique index
+// on (shelter_id, slot_start, seat) is what stops a slot being booked past its
+// capacity, even when two bookings race.
+type InterviewSlotBooking struct {
+	BookingID     uint      `json:"booking_id" gorm:"primaryKey;autoIncrement"`
+	ShelterID     uint      `json:"shelter_id" gorm:"uniqueIndex:idx_interview_slot_seat"`
+	SlotStart     time.Time `json:"slot_start" gorm:"uniqueIndex:idx_interview_slot_seat"`
+	Seat          int       `json:"seat" gorm:"uniqueIndex:idx_interview_slot_seat"`
+	ApplicationID uint      `json:"application_id" gorm:"uniqueIndex"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (InterviewSlotBooking) TableName() string {
+	return "interview_slot_bookings"
+}
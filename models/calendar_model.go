@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// CalendarFeedToken is the secret in a calendar subscription URL. Anyone with
+// the URL can read the owner's upcoming interviews, so owners can rotate it.
+type CalendarFeedToken struct {
+	TokenID   uint      `json:"token_id" gorm:"primaryKey;autoIncrement"`
+	OwnerType string    `json:"owner_type" gorm:"uniqueIndex:idx_calendar_feed_owner"` // shelter or adopter
+	OwnerID   uint      `json:"owner_id" gorm:"uniqueIndex:idx_calendar_feed_owner"`
+	Token     string    `json:"-" gorm:"uniqueIndex"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (CalendarFeedToken) TableName() string {
+	return "calendar_feed_tokens"
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// ContractSignature records one party signing an adoption contract.
+// ReviewedHash is the SHA-256 of the unsigned contract the signer was shown.
+type ContractSignature struct {
+	SignatureID    uint      `json:"signature_id" gorm:"primaryKey;autoIncrement"`
+	ApplicationID  uint      `json:"application_id" gorm:"uniqueIndex:idx_contract_signatures_application_role"`
+	SignerRole     string    `json:"signer_role" gorm:"type:varchar(10);uniqueIndex:idx_contract_signatures_application_role"` // adopter or shelter
+	SignerID       uint      `json:"signer_id"`
+	TypedName      string    `json:"typed_name"`
+	SignatureImage string    `json:"signature_image"` // Base64-encoded image
+	ReviewedHash   string    `json:"reviewed_hash"`
+	IPAddress      string    `json:"ip_address"`
+	SignedAt       time.Time `json:"signed_at"`
+}
+
+func (ContractSignature) TableName() string {
+	return "contract_signatures"
+}
+
+// SignedContract is the final, countersigned document. Rows are write-once;
+// a database trigger rejects updates and deletes.
+type SignedContract struct {
+	ApplicationID  uint      `json:"application_id" gorm:"primaryKey;autoIncrement:false"`
+	DocumentNumber string    `json:"document_number"`
+	Document       string    `json:"-" gorm:"type:text"` // Base64-encoded PDF
+	SHA256         string    `json:"sha256"`
+	FinalizedAt    time.Time `json:"finalized_at"`
+}
+
+func (SignedContract) TableName() string {
+	return "signed_contracts"
+}
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// HomeVisit is an inspection of the adopter's home between the interview and
+// approval. Shelters that set ShelterInfo.RequireHomeVisit cannot approve an
+// application until one of its visits has passed.
+type HomeVisit struct {
+	VisitID       uint       `json:"visit_id" gorm:"primaryKey;autoIncrement"`
+	ApplicationID uint       `json:"application_id" gorm:"index"`
+	ShelterID     uint       `json:"shelter_id"`
+	AdopterID     uint       `json:"adopter_id"`
+	VisitAt       time.Time  `json:"visit_at" gorm:"type:timestamptz"`
+	Timezone      string     `json:"timezone"`
+	Address       string     `json:"address"`
+	Notes         string     `json:"notes"`
+	Status        string     `json:"status" gorm:"type:varchar(20);default:'scheduled'"` // scheduled, completed, cancelled
+	Outcome       string     `json:"outcome"`                                            // pass or fail once completed
+	OutcomeNotes  string     `json:"outcome_notes"`
+	CompletedAt   *time.Time `json:"completed_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Items  []HomeVisitItem  `gorm:"foreignKey:VisitID;references:VisitID" json:"items"`
+	Photos []HomeVisitPhoto `gorm:"foreignKey:VisitID;references:VisitID" json:"photos"`
+}
+
+func (HomeVisit) TableName() string {
+	return "home_visits"
+}
+
+// HomeVisitItem is one line of the inspection checklist.
+type HomeVisitItem struct {
+	ItemID   uint   `json:"item_id" gorm:"primaryKey;autoIncrement"`
+	VisitID  uint   `json:"visit_id" gorm:"uniqueIndex:idx_home_visit_item_key"`
+	Key      string `json:"key" gorm:"uniqueIndex:idx_home_visit_item_key"`
+	Label    string `json:"label"`
+	Position int    `json:"position"`
+	Result   string `json:"result"` // pass, fail, not_applicable; empty until inspected
+	Notes    string `json:"notes"`
+}
+
+func (HomeVisitItem) TableName() string {
+	return "home_visit_items"
+}
+
+// HomeVisitPhoto is a picture taken during the visit, optionally tied to a
+// checklist item.
+type HomeVisitPhoto struct {
+	PhotoID   uint      `json:"photo_id" gorm:"primaryKey;autoIncrement"`
+	VisitID   uint      `json:"visit_id" gorm:"index"`
+	ItemKey   string    `json:"item_key"`
+	Image     string    `json:"image" gorm:"type:text"` // base64
+	Caption   string    `json:"caption"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (HomeVisitPhoto) TableName() string {
+	return "home_visit_photos"
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// LetterTemplate holds a shelter's wording for the adoption certificate and
+// contract. Body and Terms may contain placeholders such as {{adopter_name}},
+// {{pet_name}} or {{approval_date}}.
+type LetterTemplate struct {
+	ShelterID  uint      `json:"shelter_id" gorm:"primaryKey;autoIncrement:false"`
+	Title      string    `json:"title"`
+	Body       string    `json:"body" gorm:"type:text"`
+	Terms      string    `json:"terms" gorm:"type:text"`
+	FooterNote string    `json:"footer_note"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (LetterTemplate) TableName() string {
+	return "letter_templates"
+}
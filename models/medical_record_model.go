@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// PetMedicalRecord is one entry in a pet's medical history: a vaccination,
+// deworming, treatment or vet visit. NextDueOn is when a vaccination or
+// deworming has to be repeated.
+type PetMedicalRecord struct {
+	RecordID       uint       `json:"record_id" gorm:"primaryKey;autoIncrement"`
+	PetID          uint       `json:"pet_id" gorm:"index;not null"`
+	RecordType     string     `json:"record_type" gorm:"type:varchar(20);not null"` // vaccination, deworming, treatment or vet_visit
+	Name           string     `json:"name"`                                         // vaccine, product, treatment or reason for the visit
+	AdministeredOn time.Time  `json:"administered_on" gorm:"type:date"`
+	BatchNumber    string     `json:"batch_number"`
+	NextDueOn      *time.Time `json:"next_due_on" gorm:"type:date"`
+	Veterinarian   string     `json:"veterinarian"`
+	Clinic         string     `json:"clinic"`
+	Notes          string     `json:"notes" gorm:"type:text"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Documents []MedicalDocument `gorm:"foreignKey:RecordID;references:RecordID" json:"documents"`
+}
+
+func (PetMedicalRecord) TableName() string {
+	return "pet_medical_records"
+}
+
+// MedicalDocument is a scan or file attached to a medical record, such as a
+// vaccination card or lab result.
+type MedicalDocument struct {
+	DocumentID  uint      `json:"document_id" gorm:"primaryKey;autoIncrement"`
+	RecordID    uint      `json:"record_id" gorm:"index;not null"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Data        string    `json:"data" gorm:"type:text"` // base64
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (MedicalDocument) TableName() string {
+	return "medical_documents"
+}
+
+// MedicalOverdueAlert records that the shelter was told a record's next
+// dose is overdue, so it is told once per due date.
+type MedicalOverdueAlert struct {
+	AlertID   uint      `json:"alert_id" gorm:"primaryKey;autoIncrement"`
+	RecordID  uint      `json:"record_id" gorm:"uniqueIndex:idx_medical_overdue_alert"`
+	NextDueOn time.Time `json:"next_due_on" gorm:"type:date;uniqueIndex:idx_medical_overdue_alert"`
+	SentAt    time.Time `json:"sent_at"`
+}
+
+func (MedicalOverdueAlert) TableName() string {
+	return "medical_overdue_alerts"
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// ApplicationMessage is one message in the thread between the adopter and
+// the shelter on an application. ReadAt is set when the other party reads
+// it.
+type ApplicationMessage struct {
+	MessageID     uint       `json:"message_id" gorm:"primaryKey;autoIncrement"`
+	ApplicationID uint       `json:"application_id" gorm:"index;not null"`
+	SenderType    string     `json:"sender_type" gorm:"type:varchar(20);not null"` // adopter or shelter
+	SenderID      uint       `json:"sender_id"`
+	Body          string     `json:"body" gorm:"type:text"`
+	ReadAt        *time.Time `json:"read_at"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"index"`
+
+	Attachments []MessageAttachment `gorm:"foreignKey:MessageID;references:MessageID" json:"attachments"`
+}
+
+func (ApplicationMessage) TableName() string {
+	return "application_messages"
+}
+
+// MessageAttachment is an image sent with a message.
+type MessageAttachment struct {
+	AttachmentID uint      `json:"attachment_id" gorm:"primaryKey;autoIncrement"`
+	MessageID    uint      `json:"message_id" gorm:"index"`
+	FileName     string    `json:"file_name"`
+	ContentType  string    `json:"content_type"`
+	Image        string    `json:"image" gorm:"type:text"` // base64
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (MessageAttachment) TableName() string {
+	return "message_attachments"
+}
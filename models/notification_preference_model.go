@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// NotificationPreference chooses the channels one kind of notification
+// (its Type, e.g. "interview") goes out on for one recipient. Types without
+// a row use the defaults.
+type NotificationPreference struct {
+	PreferenceID  uint   `gorm:"primaryKey;autoIncrement" json:"preference_id"`
+	RecipientType string `gorm:"uniqueIndex:idx_notification_preference;not null" json:"recipient_type"`
+	RecipientID   uint   `gorm:"uniqueIndex:idx_notification_preference;not null" json:"recipient_id"`
+	EventType     string `gorm:"uniqueIndex:idx_notification_preference;not null" json:"event_type"`
+	InApp         bool   `json:"in_app"`
+	Email         bool   `json:"email"`
+	SMS           bool   `json:"sms"`
+	Push          bool   `json:"push"`
+}
+
+func (NotificationPreference) TableName() string {
+	return "notification_preferences"
+}
+
+// NotificationSettings holds a recipient's quiet hours and digest choice.
+// Quiet hours are read in the recipient's own timezone and may wrap past
+// midnight, e.g. 22:00 to 07:00.
+type NotificationSettings struct {
+	SettingsID      uint       `gorm:"primaryKey;autoIncrement" json:"settings_id"`
+	RecipientType   string     `gorm:"uniqueIndex:idx_notification_settings;not null" json:"recipient_type"`
+	RecipientID     uint       `gorm:"uniqueIndex:idx_notification_settings;not null" json:"recipient_id"`
+	QuietHoursStart string     `json:"quiet_hours_start"` // HH:MM, empty for none
+	QuietHoursEnd   string     `json:"quiet_hours_end"`
+	DailyDigest     bool       `json:"daily_digest"`
+	DigestHour      int        `json:"digest_hour"` // local hour the digest is sent
+	LastDigestAt    *time.Time `json:"last_digest_at"`
+}
+
+func (NotificationSettings) TableName() string {
+	return "notification_settings"
+}
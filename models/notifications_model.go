@@ -3,14 +3,22 @@ package models
 import "time"
 
 type Notification struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	AdopterID uint      `json:"adopter_id"`
-	PetID     uint      `json:"pet_id"`
-	Title     string    `json:"title"`
-	Message   string    `json:"message"`
-	Type      string    `json:"type"`
-	Status    string    `json:"status"`
-	Category  string    `json:"category"`
-	IsRead    bool      `json:"is_read" gorm:"default:false"`
+	ID uint `json:"id" gorm:"primaryKey"`
+	// Who the notification is for: "adopter" or "shelter", and their ID
+	RecipientType string `json:"recipient_type" gorm:"index:idx_notification_recipient;default:'adopter'"`
+	RecipientID   uint   `json:"recipient_id" gorm:"index:idx_notification_recipient"`
+	PetID         uint   `json:"pet_id"`
+	// The application the notification is about, so two applications for the
+	// same pet each get their own notifications
+	ApplicationID uint   `json:"application_id"`
+	Title         string `json:"title"`
+	Message       string `json:"message"`
+	Type          string `json:"type"`
+	Status        string `json:"status"`
+	Category      string `json:"category"`
+	IsRead        bool   `json:"is_read" gorm:"default:false"`
+	// Set when the recipient turned in-app off for this type; the row is only
+	// kept as the source for the other channels
+	Hidden    bool      `json:"-" gorm:"default:false"`
 	CreatedAt time.Time `json:"created_at"`
 }
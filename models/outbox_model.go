@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// OutboxMessage is a side effect of a committed change, such as an email to
+// send. It is written in the same transaction as the change and delivered
+// afterwards, with retries, by the outbox dispatcher. Status is pending,
+// held (waiting to be picked up by something else, e.g. the daily digest),
+// sending, sent or dead (gave up after MaxAttempts).
+type OutboxMessage struct {
+	MessageID uint   `gorm:"primaryKey;autoIncrement" json:"message_id"`
+	Topic     string `gorm:"index;not null" json:"topic"`
+	Payload   string `gorm:"type:text" json:"payload"`
+	// Groups related messages, e.g. "adopter:12" for one recipient's digest
+	GroupKey      string     `gorm:"index" json:"group_key"`
+	Status        string     `gorm:"index;default:'pending'" json:"status"`
+	Attempts      int        `gorm:"default:0" json:"attempts"`
+	MaxAttempts   int        `gorm:"default:8" json:"max_attempts"`
+	NextAttemptAt time.Time  `gorm:"index" json:"next_attempt_at"`
+	LastError     string     `json:"last_error"`
+	ClaimedAt     *time.Time `json:"claimed_at"`
+	SentAt        *time.Time `json:"sent_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (OutboxMessage) TableName() string {
+	return "outbox_messages"
+}
@@ -0,0 +1,82 @@
+package models
+
+import "time"
+
+// PetStatus is where a pet is in the adoption process. The allowed moves
+// between statuses are listed in controllers/pet_status.go.
+type PetStatus string
+
+const (
+	PetAvailable   PetStatus = "available"   // listed and open to applications
+	PetUnavailable PetStatus = "unavailable" // listed, but has as many applications as it takes
+	PetPending     PetStatus = "pending"     // held for an application in the interview stage
+	PetAdopted     PetStatus = "adopted"
+	PetArchived    PetStatus = "archived" // taken off the listings by the shelter
+)
+
+// PetIntake records how and when a pet came into the shelter's care.
+// LeftShelterAt is set while the pet is adopted or archived, and is where
+// its length of stay stops counting.
+type PetIntake struct {
+	IntakeDate    *time.Time `gorm:"type:date" json:"intake_date"`
+	IntakeSource  string     `json:"intake_source"` // stray, surrender, transfer, born_in_shelter or returned
+	IntakeNotes   string     `gorm:"type:text" json:"intake_notes"`
+	LeftShelterAt *time.Time `json:"left_shelter_at"`
+
+	// Whole days from intake until the pet left, or until now
+	LengthOfStayDays int `gorm:"-" json:"length_of_stay_days"`
+}
+
+// LengthOfStay returns the whole days between intake and leaving the
+// shelter, or now while the pet is still in care.
+func (i PetIntake) LengthOfStay(now time.Time) int {
+	if i.IntakeDate == nil {
+		return 0
+	}
+	end := now
+	if i.LeftShelterAt != nil {
+		end = *i.LeftShelterAt
+	}
+	start := time.Date(i.IntakeDate.Year(), i.IntakeDate.Month(), i.IntakeDate.Day(), 0, 0, 0, 0, end.Location())
+	days := int(end.Sub(start).Hours() / 24)
+	if days < 0 {
+		return 0
+	}
+	return days
+}
+
+// PetStatusHistory is an append-only log of a pet's status changes, with
+// who made each one and why.
+type PetStatusHistory struct {
+	HistoryID     uint      `json:"history_id" gorm:"primaryKey;autoIncrement"`
+	PetID         uint      `json:"pet_id" gorm:"index"`
+	OldStatus     PetStatus `json:"old_status"` // empty for the intake entry
+	NewStatus     PetStatus `json:"new_status"`
+	ActorRole     string    `json:"actor_role"` // shelter, adopter or system
+	ActorID       *uint     `json:"actor_id"`
+	ApplicationID *uint     `json:"application_id,omitempty"`
+	Reason        string    `json:"reason"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (PetStatusHistory) TableName() string {
+	return "pet_status_history"
+}
+
+// PetReturn records an adopted pet coming back to the shelter. A pet can
+// only be made available again after it was adopted once a return is
+// recorded.
+type PetReturn struct {
+	ReturnID      uint      `json:"return_id" gorm:"primaryKey;autoIncrement"`
+	PetID         uint      `json:"pet_id" gorm:"index"`
+	ShelterID     uint      `json:"shelter_id" gorm:"index"`
+	ApplicationID *uint     `json:"application_id"` // the adoption being undone
+	AdopterID     *uint     `json:"adopter_id"`
+	ReturnedOn    time.Time `json:"returned_on" gorm:"type:date"`
+	Reason        string    `json:"reason" gorm:"type:text"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (PetReturn) TableName() string {
+	return "pet_returns"
+}
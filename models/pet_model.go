@@ -12,13 +12,20 @@ type PetInfo struct {
 	AgeType         string    `json:"age_type"`
 	PetSex          string    `json:"pet_sex"`
 	PetDescriptions string    `json:"pet_descriptions"`
-	Status          string    `gorm:"default:'available'" json:"status"`
+	Status          PetStatus `gorm:"default:'available'" json:"status"`
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 	PetSize         string    `json:"pet_size"`
 	PriorityStatus  bool      `json:"priority_status"`
 
 	PetMedia *PetMedia `gorm:"foreignKey:PetID;references:PetID" json:"petmedia"`
+
+	// Structured profile; PetAge and AgeType follow from its BirthDate
+	PetProfile `gorm:"embedded"`
+	Breeds     []Breed `gorm:"many2many:pet_breeds;joinForeignKey:PetID;joinReferences:BreedID" json:"breeds"`
+
+	// How the pet came in, and for how long it has been in care
+	PetIntake `gorm:"embedded"`
 }
 
 func (PetInfo) TableName() string {
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PetProfile holds the structured details adopters can filter on. The
+// yes/no attributes are pointers because "not known yet" is common for new
+// intakes and is not the same as "no".
+type PetProfile struct {
+	Color              string     `json:"color"`
+	Coat               string     `json:"coat"` // hairless, short, medium, long, wire or curly
+	WeightKg           float64    `json:"weight_kg"`
+	BirthDate          *time.Time `gorm:"type:date" json:"birth_date"`
+	BirthDateEstimated bool       `json:"birth_date_estimated"`
+	SpayedNeutered     *bool      `json:"spayed_neutered"`
+	MicrochipNumber    string     `json:"microchip_number"`
+	HouseTrained       *bool      `json:"house_trained"`
+	GoodWithKids       *bool      `json:"good_with_kids"`
+	GoodWithDogs       *bool      `json:"good_with_dogs"`
+	GoodWithCats       *bool      `json:"good_with_cats"`
+	EnergyLevel        string     `json:"energy_level"` // low, medium or high
+	// Empty when the pet has no special needs
+	SpecialNeeds string `gorm:"type:text" json:"special_needs"`
+}
+
+// Breed is an entry in the per-species breed reference list.
+type Breed struct {
+	BreedID uint   `gorm:"primaryKey;autoIncrement" json:"breed_id"`
+	Species string `gorm:"uniqueIndex:idx_breed_species_name;not null" json:"species"`
+	Name    string `gorm:"uniqueIndex:idx_breed_species_name;not null" json:"name"`
+}
+
+func (Breed) TableName() string {
+	return "breeds"
+}
+
+// PetAgeAt returns how old a pet born on birthDate is at now, in whole
+// years from its first birthday, months before that, and weeks for the
+// first month.
+func PetAgeAt(birthDate, now time.Time) (int, string) {
+	months := (now.Year()-birthDate.Year())*12 + int(now.Month()-birthDate.Month())
+	if now.Day() < birthDate.Day() {
+		months--
+	}
+	switch {
+	case months >= 12:
+		return months / 12, pluralAgeUnit(months/12, "year")
+	case months >= 1:
+		return months, pluralAgeUnit(months, "month")
+	}
+	weeks := int(now.Sub(birthDate).Hours() / (24 * 7))
+	if weeks < 0 {
+		weeks = 0
+	}
+	return weeks, pluralAgeUnit(weeks, "week")
+}
+
+func pluralAgeUnit(n int, unit string) string {
+	if n == 1 {
+		return unit
+	}
+	return unit + "s"
+}
+
+// refreshAge keeps PetAge and AgeType in step with the birthdate, so they
+// read correctly however long ago the pet was saved.
+func (p *PetInfo) refreshAge() {
+	if p.BirthDate != nil {
+		p.PetAge, p.AgeType = PetAgeAt(*p.BirthDate, time.Now())
+	}
+}
+
+func (p *PetInfo) BeforeSave(tx *gorm.DB) error {
+	p.refreshAge()
+	return nil
+}
+
+func (p *PetInfo) AfterFind(tx *gorm.DB) error {
+	p.refreshAge()
+	p.LengthOfStayDays = p.LengthOfStay(time.Now())
+	return nil
+}
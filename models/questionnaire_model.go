@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Questionnaire is one published version of a shelter's adoption form.
+// Editing a form publishes a new version and deactivates the previous one,
+// so answers always point at the exact questions the adopter saw.
+type Questionnaire struct {
+	QuestionnaireID uint      `json:"questionnaire_id" gorm:"primaryKey;autoIncrement"`
+	ShelterID       uint      `json:"shelter_id" gorm:"index"`
+	PetType         string    `json:"pet_type"` // empty applies to every pet type
+	Title           string    `json:"title"`
+	Version         int       `json:"version"`
+	IsActive        bool      `json:"is_active" gorm:"default:true"`
+	CreatedAt       time.Time `json:"created_at"`
+
+	Questions []QuestionnaireQuestion `gorm:"foreignKey:QuestionnaireID;references:QuestionnaireID" json:"questions"`
+}
+
+func (Questionnaire) TableName() string {
+	return "questionnaires"
+}
+
+type QuestionnaireQuestion struct {
+	QuestionID      uint     `json:"question_id" gorm:"primaryKey;autoIncrement"`
+	QuestionnaireID uint     `json:"questionnaire_id" gorm:"index"`
+	Position        int      `json:"position"`
+	Label           string   `json:"label"`
+	QuestionType    string   `json:"question_type"` // text, choice, yes_no, number, file
+	Options         []string `json:"options" gorm:"serializer:json"`
+	Required        bool     `json:"required"`
+}
+
+func (QuestionnaireQuestion) TableName() string {
+	return "questionnaire_questions"
+}
+
+type ApplicationAnswer struct {
+	AnswerID      uint   `json:"answer_id" gorm:"primaryKey;autoIncrement"`
+	ApplicationID uint   `json:"application_id" gorm:"index"`
+	QuestionID    uint   `json:"question_id"`
+	Value         string `json:"value"` // Base64-encoded for file questions
+
+	Question QuestionnaireQuestion `gorm:"foreignKey:QuestionID;references:QuestionID" json:"question"`
+}
+
+func (ApplicationAnswer) TableName() string {
+	return "application_answers"
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// ScreeningRule awards Weight points to an application when Field compares
+// to Value using Operator. Field is either an adopter/application fact
+// (housing_type, other_pets, allergies, experience, family_support,
+// verified_email, prior_completed_adoptions) or "question:<question_id>" for
+// an answer to the shelter's questionnaire.
+type ScreeningRule struct {
+	RuleID    uint      `json:"rule_id" gorm:"primaryKey;autoIncrement"`
+	ShelterID uint      `json:"shelter_id" gorm:"index"`
+	Name      string    `json:"name"`
+	Field     string    `json:"field"`
+	Operator  string    `json:"operator"` // equals, not_equals, contains, not_contains, gte, lte, is_true, is_false, not_empty
+	Value     string    `json:"value"`
+	Weight    float64   `json:"weight"`
+	IsActive  bool      `json:"is_active" gorm:"default:true"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (ScreeningRule) TableName() string {
+	return "screening_rules"
+}
@@ -30,6 +30,15 @@ type ShelterInfo struct {
 	ShelterOwner       string `json:"shelter_owner"`
 	ShelterDescription string `json:"shelter_description"`
 	ShelterSocial      string `json:"shelter_social"`
+	// IANA timezone used for the shelter's hours and interviews
+	Timezone string `json:"timezone"`
+	// When set, applications need a passing home visit before approval
+	RequireHomeVisit bool `gorm:"default:false" json:"require_home_visit"`
+	// Hours a pending application may wait before the shelter is nudged;
+	// 0 uses APPLICATION_SLA_HOURS
+	PendingSLAHours int `json:"pending_sla_hours"`
+	// Days without progress after which an application expires; 0 never
+	ExpireApplicationsAfterDays int `json:"expire_applications_after_days"`
 
 	ShelterMedia ShelterMedia `gorm:"foreignKey:ShelterID;references:ShelterID" json:"sheltermedia"`
 }
@@ -0,0 +1,73 @@
+package models
+
+import "time"
+
+// ShelterStaff is a person working under a shelter account. Staff sign in
+// through the shelter's login; they are listed here so notes can be
+// attributed to them and they can be mentioned as @handle.
+type ShelterStaff struct {
+	StaffID   uint      `json:"staff_id" gorm:"primaryKey;autoIncrement"`
+	ShelterID uint      `json:"shelter_id" gorm:"uniqueIndex:idx_shelter_staff_handle;not null"`
+	Handle    string    `json:"handle" gorm:"uniqueIndex:idx_shelter_staff_handle;not null"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (ShelterStaff) TableName() string {
+	return "shelter_staff"
+}
+
+// StaffNote is a private note a shelter keeps on one of its applications or
+// pets. Adopters never see these. Staff share the shelter's login, so a
+// staff author is whoever the client named; AuthorSelfDeclared marks those
+// notes. Notes without one are attributed to the signed-in shelter.
+type StaffNote struct {
+	NoteID             uint      `json:"note_id" gorm:"primaryKey;autoIncrement"`
+	ShelterID          uint      `json:"shelter_id" gorm:"index;not null"`
+	SubjectType        string    `json:"subject_type" gorm:"type:varchar(20);index:idx_staff_note_subject;not null"` // application or pet
+	SubjectID          uint      `json:"subject_id" gorm:"index:idx_staff_note_subject;not null"`
+	AuthorStaffID      *uint     `json:"author_staff_id"`
+	AuthorName         string    `json:"author_name"`
+	AuthorSelfDeclared bool      `json:"author_self_declared"`
+	Body               string    `json:"body" gorm:"type:text"`
+	Edited             bool      `json:"edited"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Mentions []StaffNoteMention `gorm:"foreignKey:NoteID;references:NoteID" json:"mentions"`
+}
+
+func (StaffNote) TableName() string {
+	return "staff_notes"
+}
+
+// StaffNoteRevision keeps the text a note had before an edit, and who made
+// the edit that replaced it, self-declared like a note's author.
+type StaffNoteRevision struct {
+	RevisionID         uint      `json:"revision_id" gorm:"primaryKey;autoIncrement"`
+	NoteID             uint      `json:"note_id" gorm:"index;not null"`
+	Body               string    `json:"body" gorm:"type:text"`
+	EditedByStaffID    *uint     `json:"edited_by_staff_id"`
+	EditedByName       string    `json:"edited_by_name"`
+	EditorSelfDeclared bool      `json:"editor_self_declared"`
+	ReplacedAt         time.Time `json:"replaced_at"`
+}
+
+func (StaffNoteRevision) TableName() string {
+	return "staff_note_revisions"
+}
+
+// StaffNoteMention is a staff member mentioned as @handle in a note.
+type StaffNoteMention struct {
+	MentionID uint      `json:"mention_id" gorm:"primaryKey;autoIncrement"`
+	NoteID    uint      `json:"note_id" gorm:"uniqueIndex:idx_staff_note_mention;not null"`
+	StaffID   uint      `json:"staff_id" gorm:"uniqueIndex:idx_staff_note_mention;not null"`
+	Handle    string    `json:"handle"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (StaffNoteMention) TableName() string {
+	return "staff_note_mentions"
+}
@@ -11,19 +11,28 @@ func AppRoutes(app *fiber.App) {
 
 	pethubRoutes := app.Group("/api", middleware.JWTMiddleware())
 	// ---------------- Admin Routes ----------------
-	app.Post("/admin/register", controllers.RegisterAdmin)
-	//app.Post("/admin/login", controllers.LoginAdmin)
+	requireAdmin := middleware.RequireRole(middleware.RoleAdmin)
+	app.Post("/admin/register", middleware.OptionalJWT(), controllers.RegisterAdmin)
+	app.Post("/admin/login", controllers.LoginAdmin)
 	app.Get("/admin/getallpendingrequest", controllers.GetAllPendingRequests)
 	app.Get("/admin/getalladopters", controllers.GetAllAdopters)
 	app.Get("/admin/getallshelters", controllers.GetAllShelters)
 	app.Post("/admin/updateregstatus", controllers.UpdateRegistrationStatus)
 	app.Post("/admin/updateshelterstatus", controllers.UpdateShelterStatus)
 	app.Post("/admin/updateadopterstatus", controllers.UpdateAdopterStatus)
+	app.Get("/admin/outbox", middleware.JWTMiddleware(), requireAdmin, controllers.GetOutboxMessages)
+	app.Get("/admin/outbox/:message_id", middleware.JWTMiddleware(), requireAdmin, controllers.GetOutboxMessage)
+	app.Post("/admin/outbox/:message_id/replay", middleware.JWTMiddleware(), requireAdmin, controllers.ReplayOutboxMessage)
+	app.Post("/admin/breeds", middleware.JWTMiddleware(), requireAdmin, controllers.AddBreed)
 
 	// =====================
 	// Public Routes (No Auth Required)
 	app.Post("/user/register", controllers.RegisterAdopter)
 	app.Post("/user/login", controllers.LoginAdopter)
+	// Calendar subscriptions authenticate with the secret token in the URL
+	app.Get("/calendar/feed/:token", controllers.ServeCalendarFeed)
+	// Live notifications; outside /api so the token may come from the query string
+	app.Get("/events/stream", middleware.TokenFromQuery(), middleware.JWTMiddleware(), controllers.StreamEvents)
 	// =====================
 	// Protected Routes Group (Auth Required)// Adopter routes
 	// =====================
@@ -35,6 +44,8 @@ func AppRoutes(app *fiber.App) {
 	pethubRoutes.Post("/users/:id/upload-media", controllers.UploadAdopterMedia)
 	pethubRoutes.Get("/adopter/:id", controllers.GetAdopterProfile)
 	pethubRoutes.Post("/adopter/:adopter_id/edit", controllers.EditAdopterProfile)
+	pethubRoutes.Post("/adopter/:adopter_id/email-verification", controllers.RequestEmailVerification)
+	pethubRoutes.Post("/adopter/:adopter_id/email-verification/confirm", controllers.ConfirmEmailVerification)
 	pethubRoutes.Post("/adopter/:shelter_id/:pet_id/:adopter_id/adoption", controllers.CreateAdoption)
 	pethubRoutes.Get("/adopter/profile/:adopter_id", controllers.GetAdopterInfoByID)
 	pethubRoutes.Get("/adopter/get/:shelter_id/other-pets", controllers.GetOtherPetsByAdopterID)
@@ -47,9 +58,26 @@ func AppRoutes(app *fiber.App) {
 	pethubRoutes.Get("/users/priority/", controllers.GetPetsWithTrueStatus)
 	pethubRoutes.Get("/users/allpets", controllers.GetAllPets)
 	pethubRoutes.Get("/users/pets/search/all", controllers.FetchAllPets)
+	pethubRoutes.Get("/breeds", controllers.GetBreeds)
 	pethubRoutes.Get("/applications/adopter/:application_id", controllers.GetApplicationByAdopterID)
 	pethubRoutes.Get("/applications/pet/:pet_id", controllers.GetAdoptionApplicationsByPetID2)
 	pethubRoutes.Get("/applications/status/:application_id", controllers.GetAdoptionSubmissionStatusByApplicationID)
+	pethubRoutes.Get("/applications/:application_id/contract", controllers.ReviewAdoptionContract)
+	pethubRoutes.Post("/applications/:application_id/contract/sign", controllers.SignAdoptionContract)
+	pethubRoutes.Get("/applications/:application_id/contract/signed", controllers.GetSignedContract)
+	pethubRoutes.Get("/applications/:application_id/interview", controllers.GetInterviewDetails)
+	pethubRoutes.Post("/applications/:application_id/interview/reschedule-request", controllers.RequestInterviewReschedule)
+	pethubRoutes.Get("/applications/:application_id/interview/slots", controllers.GetApplicationInterviewSlots)
+	pethubRoutes.Post("/applications/:application_id/interview/book", controllers.BookInterviewSlot)
+	pethubRoutes.Get("/applications/:application_id/interview/ics", controllers.DownloadInterviewICS)
+	pethubRoutes.Get("/applications/:application_id/home-visits", controllers.GetHomeVisits)
+	pethubRoutes.Get("/applications/:application_id/messages", controllers.GetAdopterMessages)
+	pethubRoutes.Post("/applications/:application_id/messages", controllers.SendAdopterMessage)
+	pethubRoutes.Put("/applications/:application_id/messages/read", controllers.MarkAdopterMessagesRead)
+	pethubRoutes.Get("/adopter/:adopter_id/messages/unread_count", controllers.CountUnreadAdopterMessages)
+	pethubRoutes.Get("/adopter/:adopter_id/pets/:pet_id/medical-records", controllers.GetAdoptedPetMedicalRecords)
+	pethubRoutes.Get("/users/:adopter_id/calendar-feed", controllers.GetAdopterCalendarFeed)
+	pethubRoutes.Post("/users/:adopter_id/calendar-feed/rotate", controllers.RotateAdopterCalendarFeed)
 	pethubRoutes.Post("/reports/shelter/:shelter_id/adopter/:adopter_id", controllers.SubmitReport)
 	pethubRoutes.Get("/applications/allpets/:adopter_id", controllers.ShowPetsByAdopterID)
 	pethubRoutes.Get("/adopter/:adopter_id/notifications", controllers.GetAdoptionNotifications)
@@ -58,6 +86,15 @@ func AppRoutes(app *fiber.App) {
 	pethubRoutes.Patch("/adopter/notifications/:id/read-status", controllers.SetNotificationReadStatus)
 	pethubRoutes.Get("/adopter/notifications/:id", controllers.GetNotificationByID)
 	pethubRoutes.Delete("/adopter/:adopter_id/notifications/remove_all", controllers.DeleteAllNotifications)
+	pethubRoutes.Get("/shelter/:shelter_id/notifications", controllers.GetShelterNotifications)
+	pethubRoutes.Get("/shelter/:shelter_id/notifications/unread_count", controllers.CountUnreadShelterNotifications)
+	pethubRoutes.Patch("/shelter/notifications/:id/read-status", controllers.SetShelterNotificationReadStatus)
+	pethubRoutes.Get("/shelter/notifications/:id", controllers.GetShelterNotificationByID)
+	pethubRoutes.Delete("/shelter/:shelter_id/notifications/remove_all", controllers.DeleteAllShelterNotifications)
+	pethubRoutes.Get("/adopter/:adopter_id/notification-preferences", controllers.GetAdopterNotificationPreferences)
+	pethubRoutes.Put("/adopter/:adopter_id/notification-preferences", controllers.UpdateAdopterNotificationPreferences)
+	pethubRoutes.Get("/shelter/:shelter_id/notification-preferences", controllers.GetShelterNotificationPreferences)
+	pethubRoutes.Put("/shelter/:shelter_id/notification-preferences", controllers.UpdateShelterNotificationPreferences)
 	// ---------------- Shelter Routes ----------------
 	app.Post("/shelter/register", controllers.RegisterShelter)
 	app.Post("/shelter/login", controllers.LoginShelter)
@@ -72,6 +109,9 @@ func AppRoutes(app *fiber.App) {
 	pethubRoutes.Put("/shelter/:id/archive-pet", controllers.SetPetStatusToArchive)
 	pethubRoutes.Put("/shelter/:id/unarchive-pet", controllers.SetPetStatusToUnarchive)
 	pethubRoutes.Get("/shelter/:id/petcount", controllers.CountPetsByShelter)
+	pethubRoutes.Get("/shelter/:shelter_id/length-of-stay", controllers.GetShelterLengthOfStay)
+	pethubRoutes.Get("/shelter/pets/:pet_id/status-history", controllers.GetPetStatusHistory)
+	pethubRoutes.Post("/shelter/pets/:pet_id/return", controllers.RecordPetReturn)
 	pethubRoutes.Get("/filter/:id/pets/search", controllers.FetchAndSearchPets)
 	pethubRoutes.Get("/shelter/archive/pets/:id/search", controllers.FetchAndSearchArchivedPets)
 	pethubRoutes.Get("/shelter/:id/get/donationinfo", controllers.GetShelterDonationInfo)
@@ -81,15 +121,74 @@ func AppRoutes(app *fiber.App) {
 	pethubRoutes.Get("/shelter/:application_id/application-details", controllers.GetApplicationByApplicationID)
 	pethubRoutes.Get("/shelterinfo/:shelter_id", controllers.GetShelterInfo)
 	pethubRoutes.Post("/shelter/:shelter_id/add-pet", controllers.AddPetInfo)
+	pethubRoutes.Get("/shelter/pets/import/columns", controllers.GetPetImportColumns)
+	pethubRoutes.Post("/shelter/:shelter_id/pets/import/preview", controllers.PreviewPetImport)
+	pethubRoutes.Post("/shelter/:shelter_id/pets/import", controllers.ImportPets)
+	pethubRoutes.Get("/shelter/:shelter_id/pets/export", controllers.ExportPets)
 	pethubRoutes.Get("/shelter/count/:pet_id/applied", controllers.CountApplicantsByPetId)
 	pethubRoutes.Get("/shelter/:shelter_id/adoption", controllers.GetPetsWithAdoptionRequestsByShelter)
 	pethubRoutes.Get("/shelter/:pet_id/get/applications", controllers.GetAdoptionApplicationsByPetID)
 	pethubRoutes.Post("/shelter/application/:application_id/set-interview-date", controllers.SetInterviewSchedule)
 	pethubRoutes.Put("/shelter/application/:application_id/interview/reject", controllers.RejectApplication)
+	pethubRoutes.Put("/shelter/application/:application_id/interview/invite", controllers.InviteToInterview)
+	pethubRoutes.Put("/shelter/application/:application_id/interview/reschedule", controllers.RescheduleInterview)
+	pethubRoutes.Put("/shelter/application/:application_id/interview/cancel", controllers.CancelInterview)
+	pethubRoutes.Put("/shelter/application/:application_id/interview/no-show", controllers.MarkInterviewNoShow)
+	pethubRoutes.Put("/shelter/application/:application_id/interview/outcome", controllers.RecordInterviewOutcome)
+	pethubRoutes.Put("/shelter/interview/reschedule-requests/:request_id", controllers.RespondToRescheduleRequest)
+	pethubRoutes.Get("/shelter/:shelter_id/availability", controllers.GetShelterAvailability)
+	pethubRoutes.Put("/shelter/:shelter_id/availability", controllers.SetShelterAvailability)
+	pethubRoutes.Post("/shelter/:shelter_id/blackout-dates", controllers.AddBlackoutDate)
+	pethubRoutes.Delete("/shelter/:shelter_id/blackout-dates/:blackout_id", controllers.DeleteBlackoutDate)
+	pethubRoutes.Get("/shelter/:shelter_id/interview-slots", controllers.GetShelterInterviewSlots)
+	pethubRoutes.Put("/shelter/:shelter_id/home-visit-settings", controllers.UpdateHomeVisitSettings)
+	pethubRoutes.Put("/shelter/:shelter_id/application-policy", controllers.UpdateApplicationPolicy)
+	pethubRoutes.Post("/shelter/application/:application_id/home-visits", controllers.ScheduleHomeVisit)
+	pethubRoutes.Put("/shelter/application/:application_id/home-visits/:visit_id/checklist", controllers.UpdateHomeVisitChecklist)
+	pethubRoutes.Post("/shelter/application/:application_id/home-visits/:visit_id/photos", controllers.UploadHomeVisitPhoto)
+	pethubRoutes.Put("/shelter/application/:application_id/home-visits/:visit_id/outcome", controllers.RecordHomeVisitOutcome)
+	pethubRoutes.Put("/shelter/application/:application_id/home-visits/:visit_id/cancel", controllers.CancelHomeVisit)
+	pethubRoutes.Get("/shelter/application/:application_id/messages", controllers.GetShelterMessages)
+	pethubRoutes.Post("/shelter/application/:application_id/messages", controllers.SendShelterMessage)
+	pethubRoutes.Put("/shelter/application/:application_id/messages/read", controllers.MarkShelterMessagesRead)
+	pethubRoutes.Get("/shelter/:shelter_id/messages/unread_count", controllers.CountUnreadShelterMessages)
+	pethubRoutes.Get("/shelter/:shelter_id/staff", controllers.GetShelterStaff)
+	pethubRoutes.Post("/shelter/:shelter_id/staff", controllers.AddShelterStaff)
+	pethubRoutes.Put("/shelter/:shelter_id/staff/:staff_id", controllers.UpdateShelterStaff)
+	pethubRoutes.Get("/shelter/:shelter_id/staff/:staff_id/mentions", controllers.GetStaffMentions)
+	pethubRoutes.Get("/shelter/application/:application_id/notes", controllers.GetApplicationStaffNotes)
+	pethubRoutes.Post("/shelter/application/:application_id/notes", controllers.AddApplicationStaffNote)
+	pethubRoutes.Get("/shelter/pets/:pet_id/notes", controllers.GetPetStaffNotes)
+	pethubRoutes.Post("/shelter/pets/:pet_id/notes", controllers.AddPetStaffNote)
+	pethubRoutes.Put("/shelter/notes/:note_id", controllers.EditStaffNote)
+	pethubRoutes.Get("/shelter/notes/:note_id/history", controllers.GetStaffNoteHistory)
+	pethubRoutes.Get("/shelter/pets/:pet_id/medical-records", controllers.GetPetMedicalRecords)
+	pethubRoutes.Post("/shelter/pets/:pet_id/medical-records", controllers.AddPetMedicalRecord)
+	pethubRoutes.Put("/shelter/medical-records/:record_id", controllers.UpdatePetMedicalRecord)
+	pethubRoutes.Delete("/shelter/medical-records/:record_id", controllers.DeletePetMedicalRecord)
+	pethubRoutes.Post("/shelter/medical-records/:record_id/documents", controllers.UploadMedicalDocument)
+	pethubRoutes.Get("/shelter/:shelter_id/calendar-feed", controllers.GetShelterCalendarFeed)
+	pethubRoutes.Post("/shelter/:shelter_id/calendar-feed/rotate", controllers.RotateShelterCalendarFeed)
 	pethubRoutes.Get("/shelter/:shelter_id/adoption-applications", controllers.GetAdoptionSubmissionsByShelterAndStatus)
 	pethubRoutes.Post("/shelter/reject-application/:application_id", controllers.RejectApplication)
 	pethubRoutes.Put("/shelter/approve-application/:application_id", controllers.ApproveApplication)
 	pethubRoutes.Get("/shelter/export/:shelter_id/:application_id/letter", controllers.GetInfosForDownloadLetter)
+	pethubRoutes.Get("/shelter/export/:shelter_id/:application_id/letter/pdf", controllers.DownloadAdoptionContract)
+	pethubRoutes.Get("/shelter/:shelter_id/letter-template", controllers.GetLetterTemplate)
+	pethubRoutes.Put("/shelter/:shelter_id/letter-template", controllers.UpdateLetterTemplate)
+	pethubRoutes.Post("/shelter/application/:application_id/contract/countersign", controllers.CountersignAdoptionContract)
+
+	// Shelter - Questionnaires
+	pethubRoutes.Post("/shelter/:shelter_id/questionnaires", controllers.PublishQuestionnaire)
+	pethubRoutes.Get("/shelter/:shelter_id/questionnaires", controllers.GetShelterQuestionnaires)
+	pethubRoutes.Delete("/shelter/:shelter_id/questionnaires/:questionnaire_id", controllers.DeactivateQuestionnaire)
+	pethubRoutes.Get("/users/pets/:pet_id/questionnaire", controllers.GetActiveQuestionnaire)
+
+	// Shelter - Applicant Screening
+	pethubRoutes.Post("/shelter/:shelter_id/screening-rules", controllers.CreateScreeningRule)
+	pethubRoutes.Get("/shelter/:shelter_id/screening-rules", controllers.GetScreeningRules)
+	pethubRoutes.Put("/shelter/:shelter_id/screening-rules/:rule_id", controllers.UpdateScreeningRule)
+	pethubRoutes.Delete("/shelter/:shelter_id/screening-rules/:rule_id", controllers.DeleteScreeningRule)
 
 	// ---------------- General Shared Routes ----------------s
 	pethubRoutes.Get("/allshelter", controllers.GetShelters)